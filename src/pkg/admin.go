@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminAuth 校验管理接口 token，支持 Authorization: Bearer <token> 与 X-Admin-Token 头
+func adminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Global.AdminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin api is disabled"})
+			return
+		}
+
+		token := c.GetHeader("X-Admin-Token")
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(Global.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// registerAdminRoutes 注册 /admin 下的管理接口
+func registerAdminRoutes(r *gin.Engine) {
+	admin := r.Group("/admin", adminAuth())
+
+	admin.GET("/subscriptions", listSubscriptions)
+	admin.POST("/subscriptions", createSubscription)
+	admin.GET("/subscriptions/:name", getSubscription)
+	admin.PUT("/subscriptions/:name", updateSubscription)
+	admin.DELETE("/subscriptions/:name", deleteSubscription)
+
+	admin.GET("/tokens", listTokens)
+	admin.POST("/tokens", createToken)
+	admin.GET("/tokens/:token", getToken)
+	admin.POST("/tokens/:token/revoke", revokeToken)
+	admin.POST("/tokens/:token/expire", expireToken)
+	admin.DELETE("/tokens/:token", deleteToken)
+	admin.GET("/usage", listUsage)
+	admin.GET("/audit", listAudit)
+}
+
+func listSubscriptions(c *gin.Context) {
+	c.JSON(http.StatusOK, Subscriptions.List())
+}
+
+func getSubscription(c *gin.Context) {
+	sub, err := Subscriptions.Get(c.Param("name"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func createSubscription(c *gin.Context) {
+	var sub Subscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+
+	created, err := Subscriptions.Create(sub)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func updateSubscription(c *gin.Context) {
+	var sub Subscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+	// 以路径中的名称为准
+	sub.Name = c.Param("name")
+
+	updated, err := Subscriptions.Update(sub)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteSubscription(c *gin.Context) {
+	if err := Subscriptions.Delete(c.Param("name")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// listTokens 返回全部 token，?status=active 等只返回该状态的 token
+func listTokens(c *gin.Context) {
+	list := Tokens.List()
+	if status := c.Query("status"); status != "" {
+		filtered := make([]TokenBinding, 0, len(list))
+		for _, b := range list {
+			if b.Status == status {
+				filtered = append(filtered, b)
+			}
+		}
+		list = filtered
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// tokenRequest 为签发 token 的请求，ttl（如 720h）是从现在起设置 expires_at 的简写
+type tokenRequest struct {
+	TokenBinding
+	TTL string `json:"ttl,omitempty"`
+}
+
+func createToken(c *gin.Context) {
+	var req tokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+	b := req.TokenBinding
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || b.ExpiresAt != nil {
+			c.Error(badRequest(fmt.Errorf("invalid ttl: %q (want a positive duration, without expires_at)", req.TTL)))
+			return
+		}
+		at := time.Now().Add(ttl)
+		b.ExpiresAt = &at
+	}
+
+	created, err := Tokens.Create(b)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func getToken(c *gin.Context) {
+	b, err := Tokens.Get(c.Param("token"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// revokeToken 吊销 token 并保留记录；DELETE 则删除记录
+func revokeToken(c *gin.Context) {
+	b, err := Tokens.Revoke(c.Param("token"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// expireToken 设置 token 的过期时间：请求体 {"at": "2026-01-01T00:00:00Z"}，为空时立即过期
+func expireToken(c *gin.Context) {
+	var req struct {
+		At *time.Time `json:"at"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(badRequest(err))
+			return
+		}
+	}
+	at := time.Now()
+	if req.At != nil {
+		at = *req.At
+	}
+	b, err := Tokens.Expire(c.Param("token"), at)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+func deleteToken(c *gin.Context) {
+	if err := Tokens.Delete(c.Param("token")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
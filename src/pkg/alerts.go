@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 定时刷新时根据流量信息触发的提醒事件
+const (
+	EventExpiring   = "subscription_expiring" // 距到期不足 expire-days 天（或已到期）
+	EventTrafficLow = "traffic_low"           // 剩余流量低于 traffic-remaining
+)
+
+const defaultTelegramAPI = "https://api.telegram.org"
+
+// AlertConfig 为到期与流量提醒设置，提醒通过 webhooks 与 telegram 发送
+type AlertConfig struct {
+	ExpireDays       int            `mapstructure:"expire-days"`       // 距到期不足该天数时提醒，0 表示不提醒
+	TrafficRemaining string         `mapstructure:"traffic-remaining"` // 剩余流量低于该值时提醒，如 10GB，为空表示不提醒
+	Telegram         TelegramConfig `mapstructure:"telegram"`
+
+	trafficRemaining int64 // 由 TrafficRemaining 解析出的字节数
+}
+
+// TelegramConfig 通过 Telegram 机器人发送事件
+type TelegramConfig struct {
+	BotToken string   `mapstructure:"bot-token"`
+	ChatID   string   `mapstructure:"chat-id"`
+	APIURL   string   `mapstructure:"api-url"` // Bot API 地址，默认 https://api.telegram.org，可换为自建或反代的地址
+	Events   []string `mapstructure:"events"`  // 为空时接收全部事件
+}
+
+// alertStates 记录各订阅已触发的提醒，条件持续满足时不重复提醒，恢复后清除
+var alertStates sync.Map
+
+// validate 检查提醒设置并解析流量阈值
+func (a *AlertConfig) validate() error {
+	if a.ExpireDays < 0 {
+		return fmt.Errorf("invalid expire-days: %d", a.ExpireDays)
+	}
+	if a.TrafficRemaining != "" {
+		sizes := trafficSizes(a.TrafficRemaining)
+		if len(sizes) != 1 {
+			return fmt.Errorf("invalid traffic-remaining: %q (want a size like 10GB)", a.TrafficRemaining)
+		}
+		a.trafficRemaining = sizes[0]
+	}
+	if (a.Telegram.BotToken == "") != (a.Telegram.ChatID == "") {
+		return fmt.Errorf("telegram needs both bot-token and chat-id")
+	}
+	return nil
+}
+
+// checkAlerts 根据订阅最近记录的流量信息判断是否需要提醒
+func checkAlerts(subURL string) {
+	alerts := Global.Alerts
+	info, ok := subscriptionTraffic(subURL)
+	if !ok {
+		return
+	}
+
+	if alerts.ExpireDays > 0 && !info.Expire.IsZero() {
+		days := int(math.Ceil(time.Until(info.Expire).Hours() / 24))
+		message := fmt.Sprintf("expires in %d days (%s)", days, info.Expire.Format(time.DateOnly))
+		if days <= 0 {
+			message = fmt.Sprintf("expired on %s", info.Expire.Format(time.DateOnly))
+		}
+		raiseAlert(EventExpiring, subURL, days <= alerts.ExpireDays, message)
+	}
+	if alerts.trafficRemaining > 0 && info.Total > 0 {
+		remaining := max(info.Total-info.Upload-info.Download, 0)
+		message := fmt.Sprintf("remaining traffic %.1fGB is below %s", float64(remaining)/(1<<30), alerts.TrafficRemaining)
+		raiseAlert(EventTrafficLow, subURL, remaining < alerts.trafficRemaining, message)
+	}
+}
+
+// raiseAlert 在条件由不满足变为满足时发送一次提醒
+func raiseAlert(event, subURL string, active bool, message string) {
+	key := event + "\x00" + subURL
+	if !active {
+		alertStates.Delete(key)
+		return
+	}
+	if _, loaded := alertStates.LoadOrStore(key, true); loaded {
+		return
+	}
+	warnf("Subscription %s %s", subscriptionLabel(subURL), message)
+	notify(event, subURL, message)
+}
+
+func (t TelegramConfig) enabled() bool {
+	return t.BotToken != "" && t.ChatID != ""
+}
+
+func (t TelegramConfig) accepts(event string) bool {
+	return WebhookConfig{Events: t.Events}.accepts(event)
+}
+
+// send 以纯文本消息发送事件
+func (t TelegramConfig) send(ev WebhookEvent) {
+	api := strings.TrimSuffix(t.APIURL, "/")
+	if api == "" {
+		api = defaultTelegramAPI
+	}
+	payload, err := json.Marshal(map[string]string{
+		"chat_id": t.ChatID,
+		"text":    fmt.Sprintf("[%s] %s\n%s", ev.Event, ev.Subscription, ev.Message),
+	})
+	if err != nil {
+		warnf("Warning: Failed to marshal telegram message: %v", err)
+		return
+	}
+
+	resp, err := webhookClient.Post(api+"/bot"+t.BotToken+"/sendMessage", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		// 错误信息中的地址含有 bot token，只记录底层错误
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		warnf("Warning: Failed to deliver telegram message: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		warnf("Warning: Telegram returned status %d", resp.StatusCode)
+	}
+}
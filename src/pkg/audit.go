@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditKind = "audit"
+	// auditFlushInterval 为下载记录写回存储的间隔
+	auditFlushInterval = 10 * time.Second
+
+	defaultAuditMaxEntries = 10000
+	defaultAuditQueryLimit = 100
+)
+
+// AuditEntry 为一次成功的配置下载，用于排查泄露的订阅链接
+type AuditEntry struct {
+	Time         time.Time `json:"time"`
+	Token        string    `json:"token,omitempty"`
+	Name         string    `json:"name,omitempty"`   // token 或用户的备注
+	Client       string    `json:"client,omitempty"` // 双向 TLS 时客户端证书的 CN
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	Path         string    `json:"path"`         // 如 /config、/config/<name>、/s/<id>、/provider
+	Subscription string    `json:"subscription"` // 订阅名称或去掉查询参数的地址
+	Nodes        int       `json:"nodes"`        // 配置中内联的节点数（proxy-provider 模式为 0），/provider 为节点列表中的节点数
+}
+
+// AuditLog 在内存中保留最近的下载记录，定期写回存储后端
+type AuditLog struct {
+	mu      sync.Mutex
+	backend Backend
+	max     int
+	entries []AuditEntry // 按时间顺序
+	pending int          // 尚未写回的新记录数（entries 末尾）
+}
+
+// Audit 是全局下载记录，在 setup 中初始化
+var Audit *AuditLog
+
+// NewAuditLog 加载之前的下载记录，max 为保留的条数，0 表示不记录
+func NewAuditLog(backend Backend, max int) (*AuditLog, error) {
+	l := &AuditLog{backend: backend, max: max}
+	if err := backend.Load(auditKind, &l.entries); err != nil {
+		return nil, err
+	}
+	l.trimLocked()
+	return l, nil
+}
+
+// Record 记录一次成功的 /config 或 /provider 下载，nodes 为转换得到的节点数
+func (l *AuditLog) Record(c *gin.Context, token, subscription string, nodes int) {
+	if l.max <= 0 {
+		return
+	}
+	e := AuditEntry{
+		Time:         time.Now(),
+		Token:        token,
+		IP:           c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		Path:         c.Request.URL.Path,
+		Subscription: subscription,
+		Nodes:        nodes,
+	}
+	if token != "" {
+		e.Name, _ = tokenName(token)
+	}
+	if state := c.Request.TLS; state != nil && len(state.PeerCertificates) > 0 {
+		e.Client = state.PeerCertificates[0].Subject.CommonName
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	l.pending++
+	l.trimLocked()
+}
+
+// trimLocked 只保留最近 max 条记录
+func (l *AuditLog) trimLocked() {
+	if n := len(l.entries) - l.max; n > 0 {
+		l.entries = append([]AuditEntry(nil), l.entries[n:]...)
+	}
+	l.pending = min(l.pending, len(l.entries))
+}
+
+// AuditQuery 为查询条件，零值表示不限制
+type AuditQuery struct {
+	Token        string
+	IP           string
+	Subscription string // 子串匹配
+	Since, Until time.Time
+	Limit        int
+}
+
+// Query 按时间倒序返回符合条件的记录，最多 Limit 条
+func (l *AuditLog) Query(q AuditQuery) []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list := []AuditEntry{}
+	for i := len(l.entries) - 1; i >= 0 && len(list) < q.Limit; i-- {
+		e := l.entries[i]
+		switch {
+		case q.Token != "" && e.Token != q.Token,
+			q.IP != "" && e.IP != q.IP,
+			q.Subscription != "" && !strings.Contains(e.Subscription, q.Subscription),
+			!q.Since.IsZero() && e.Time.Before(q.Since),
+			!q.Until.IsZero() && !e.Time.Before(q.Until):
+			continue
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+// Flush 将有变化的记录写回存储后端，后端支持追加时只写入新记录
+func (l *AuditLog) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == 0 {
+		return nil
+	}
+	var err error
+	if a, ok := l.backend.(Appender); ok {
+		err = a.Append(auditKind, l.entries[len(l.entries)-l.pending:], l.max)
+	} else {
+		err = l.backend.Save(auditKind, l.entries)
+	}
+	if err != nil {
+		return err
+	}
+	l.pending = 0
+	return nil
+}
+
+// startAuditFlusher 按 auditFlushInterval 定时写回下载记录
+func startAuditFlusher() {
+	go func() {
+		ticker := time.NewTicker(auditFlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := Audit.Flush(); err != nil {
+				warnf("Failed to save audit log: %v", err)
+			}
+		}
+	}()
+}
+
+// auditSubscription 返回记录中的订阅：命名订阅为其名称，否则为去掉查询参数的地址（查询参数中常含机场 token）
+func auditSubscription(params url.Values, subURL string) string {
+	if name := params.Get("sub"); name != "" {
+		return name
+	}
+	return subscriptionLabel(subURL)
+}
+
+// listAudit 查询下载记录：?token=、?ip=、?sub=（子串）、?since= 与 ?until=（RFC 3339）、?limit=（默认 100）
+func listAudit(c *gin.Context) {
+	q := AuditQuery{
+		Token:        c.Query("token"),
+		IP:           c.Query("ip"),
+		Subscription: c.Query("sub"),
+		Limit:        defaultAuditQueryLimit,
+	}
+	var err error
+	for key, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if raw := c.Query(key); raw != "" {
+			if *t, err = time.Parse(time.RFC3339, raw); err != nil {
+				c.Error(badRequest(err))
+				return
+			}
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		if q.Limit, err = strconv.Atoi(raw); err != nil || q.Limit <= 0 {
+			c.Error(badRequest(errors.New("invalid limit")))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, Audit.Query(q))
+}
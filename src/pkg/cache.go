@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// staleHeader 标记响应来自缓存的上游内容，值为缓存时间
+	staleHeader = "X-Subscription-Stale"
+
+	maxMemoryCacheEntries = 1024
+)
+
+// Cache 是缓存后端的统一接口。缓存只是优化，后端出错时按未命中处理并记录日志
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	// Set 写入缓存，ttl <= 0 表示不过期
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// conversionCache 缓存转换结果，在 main 中按配置初始化，为 nil 时不缓存
+var conversionCache Cache
+
+// newCache 按配置创建缓存后端：memory（默认）或 redis
+func newCache(cfg *Config) (Cache, error) {
+	switch cfg.Cache {
+	case "", "memory":
+		return newMemoryCache(), nil
+	case "redis":
+		return newRedisCache(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Cache)
+	}
+}
+
+// cacheKey 将任意字符串映射为定长的缓存键
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// memoryCache 是进程内缓存，多副本部署时各自独立
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: make(map[string]memoryEntry)}
+}
+
+func (mc *memoryCache) Get(key string) ([]byte, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	e, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(mc.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (mc *memoryCache) Set(key string, value []byte, ttl time.Duration) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if _, ok := mc.entries[key]; !ok && len(mc.entries) >= maxMemoryCacheEntries {
+		mc.evictLocked()
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	mc.entries[key] = memoryEntry{value: value, expires: expires}
+}
+
+// evictLocked 清理过期条目，仍然已满时随机淘汰一条
+func (mc *memoryCache) evictLocked() {
+	now := time.Now()
+	for k, e := range mc.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(mc.entries, k)
+		}
+	}
+	for k := range mc.entries {
+		if len(mc.entries) < maxMemoryCacheEntries {
+			break
+		}
+		delete(mc.entries, k)
+	}
+}
+
+// fileCache 将缓存保存在目录下的文件中，重启后仍可用；不支持过期
+type fileCache struct {
+	dir string
+}
+
+func (fc fileCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(fc.dir, key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errorf("Error reading cache %s: %v", key, err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+func (fc fileCache) Set(key string, value []byte, _ time.Duration) {
+	if err := saveFile(filepath.Join(fc.dir, key), value); err != nil {
+		errorf("Error writing cache %s: %v", key, err)
+	}
+}
+
+// UpstreamCache 保存最近一次成功解析的上游订阅内容，上游不可用时以此继续提供配置
+type UpstreamCache struct {
+	store Cache
+
+	mu    sync.Mutex
+	stale map[string]time.Time // 最近一次拉取失败、正在使用缓存的订阅
+}
+
+// upstreamCache 在 main 中按配置初始化，为 nil 时不缓存
+var upstreamCache *UpstreamCache
+
+func NewUpstreamCache(store Cache) *UpstreamCache {
+	return &UpstreamCache{store: store, stale: make(map[string]time.Time)}
+}
+
+// Store 保存上游内容及拉取时间，并清除该订阅的过期标记
+func (uc *UpstreamCache) Store(subURL string, body []byte) {
+	if uc == nil {
+		return
+	}
+	uc.mu.Lock()
+	delete(uc.stale, subURL)
+	uc.mu.Unlock()
+
+	// 第一行为拉取时间，其后为原始内容
+	value := append([]byte(time.Now().UTC().Format(time.RFC3339Nano)+"\n"), body...)
+	uc.store.Set(cacheKey("upstream", subURL), value, 0)
+}
+
+// Load 读取缓存的上游内容及其拉取时间，并将该订阅标记为过期
+func (uc *UpstreamCache) Load(subURL string) ([]byte, time.Time, bool) {
+	if uc == nil {
+		return nil, time.Time{}, false
+	}
+	value, ok := uc.store.Get(cacheKey("upstream", subURL))
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	head, body, ok := bytes.Cut(value, []byte("\n"))
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, string(head))
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	uc.mu.Lock()
+	uc.stale[subURL] = at
+	uc.mu.Unlock()
+	return body, at, true
+}
+
+// StaleSince 返回订阅当前是否在使用缓存，以及缓存时间
+func (uc *UpstreamCache) StaleSince(subURL string) (time.Time, bool) {
+	if uc == nil {
+		return time.Time{}, false
+	}
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	at, ok := uc.stale[subURL]
+	return at, ok
+}
+
+// setStaleHeader 在使用缓存内容时为响应加上 X-Subscription-Stale 头
+func setStaleHeader(c *gin.Context, subURL string) {
+	if at, ok := staleSince(subURL); ok {
+		c.Header(staleHeader, at.UTC().Format(http.TimeFormat))
+	}
+}
+
+// conversionCacheEnabled 判断是否缓存转换结果：设置了 cache-ttl 或启用了定时刷新
+func conversionCacheEnabled() bool {
+	return conversionCache != nil && (Global.CacheTTL > 0 || Global.RefreshInterval > 0)
+}
+
+// conversionKey 返回订阅与参数对应的缓存键。url.Values.Encode 按键排序，参数顺序不同的请求共用缓存
+// proxy-provider 模式下按请求推断的 /provider 地址随访问域名变化，一并计入
+func conversionKey(subURL string, params url.Values, opts ConvertOptions) string {
+	return cacheKey("config", subURL, params.Encode(), opts.ProviderURL)
+}
+
+// conversionTTL 返回转换结果的缓存时间。定时刷新时至少保留到下一次刷新之后
+func conversionTTL() time.Duration {
+	ttl := Global.CacheTTL
+	if refresh := Global.RefreshInterval; refresh > 0 && ttl < 2*refresh {
+		ttl = 2 * refresh
+	}
+	return ttl
+}
+
+// convertCached 复用相同订阅与参数的转换结果，返回配置与其中内联的节点数
+func convertCached(ctx context.Context, subURL string, params url.Values, opts ConvertOptions) ([]byte, int, error) {
+	if !conversionCacheEnabled() {
+		return processConvert(ctx, subURL, opts)
+	}
+	key := conversionKey(subURL, params, opts)
+	if data, nodes, ok := loadConversion(key); ok {
+		return data, nodes, nil
+	}
+	data, nodes, err := processConvert(ctx, subURL, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	storeConversion(key, data, nodes)
+	return data, nodes, nil
+}
+
+// storeConversion 缓存转换结果，节点数写在配置之前的一行，命中缓存时无需重新解析配置
+func storeConversion(key string, data []byte, nodes int) {
+	entry := make([]byte, 0, len(data)+8)
+	entry = strconv.AppendInt(entry, int64(nodes), 10)
+	entry = append(entry, '\n')
+	conversionCache.Set(key, append(entry, data...), conversionTTL())
+}
+
+// loadConversion 读取 storeConversion 缓存的转换结果，格式不符（如升级前写入的 redis 缓存）时按未命中处理
+func loadConversion(key string) ([]byte, int, bool) {
+	entry, ok := conversionCache.Get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	head, data, ok := bytes.Cut(entry, []byte("\n"))
+	if !ok {
+		return nil, 0, false
+	}
+	nodes, err := strconv.Atoi(string(head))
+	if err != nil || nodes < 0 {
+		return nil, 0, false
+	}
+	return data, nodes, true
+}
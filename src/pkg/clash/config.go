@@ -0,0 +1,245 @@
+package clash
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RulesProvider defines the structure for rule providers
+type RulesProvider struct {
+	Type     string `yaml:"type"`
+	Behavior string `yaml:"behavior"`
+	URL      string `yaml:"url"`
+	Path     string `yaml:"path"`
+	Interval int    `yaml:"interval"`
+	Format   string `yaml:"format,omitempty"` // text 表示每行一条规则的列表
+}
+
+// ProxyProvider 为 proxy-providers 中的条目，客户端定时从 URL 下载节点列表
+type ProxyProvider struct {
+	Type          string      `yaml:"type"`
+	URL           string      `yaml:"url"`
+	Path          string      `yaml:"path"`
+	Interval      int         `yaml:"interval"`
+	Filter        string      `yaml:"filter,omitempty"`         // 只引入名称匹配的节点
+	ExcludeFilter string      `yaml:"exclude-filter,omitempty"` // 排除名称匹配的节点（mihomo）
+	HealthCheck   HealthCheck `yaml:"health-check"`
+}
+
+// HealthCheck 为 proxy-provider 的节点健康检查设置
+type HealthCheck struct {
+	Enable   bool   `yaml:"enable"`
+	URL      string `yaml:"url"`
+	Interval int    `yaml:"interval"`
+}
+
+// Config 代表完整的 Clash 配置文件结构
+type Config struct {
+	Port           int                      `yaml:"port"`
+	SocksPort      int                      `yaml:"socks-port"`
+	AllowLan       bool                     `yaml:"allow-lan"`
+	Mode           string                   `yaml:"mode"`
+	LogLevel       string                   `yaml:"log-level"`
+	ExternalCtrl   string                   `yaml:"external-controller"`
+	Proxies        []Proxy                  `yaml:"proxies"`
+	ProxyProviders map[string]ProxyProvider `yaml:"proxy-providers,omitempty"`
+	ProxyGroups    []ProxyGroup             `yaml:"proxy-groups"`
+	RulesProviders map[string]RulesProvider `yaml:"rule-providers"`
+	Rules          []string                 `yaml:"rules"`
+
+	// ExtraProxies 为原样输出在生成节点之前的节点，如 ?base= 配置中原有的节点
+	ExtraProxies []*yaml.Node `yaml:"-"`
+
+	// Template 为渲染后的模板文档的顶层映射，输出时其中未生成的键（dns、tun、hosts、sniffer 等）原样保留
+	Template *yaml.Node `yaml:"-"`
+}
+
+// ProxyGroup 代表 Clash 配置中的代理组
+type ProxyGroup struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`
+	Proxies   []string `yaml:"proxies"`
+	URL       string   `yaml:"url,omitempty"`       // url-test/fallback/load-balance 测速地址
+	Interval  int      `yaml:"interval,omitempty"`  // 测速间隔（秒）
+	Tolerance int      `yaml:"tolerance,omitempty"` // url-test 切换节点的延迟容差（毫秒）
+	Lazy      *bool    `yaml:"lazy,omitempty"`      // 未被使用时是否跳过测速
+	Strategy  string   `yaml:"strategy,omitempty"`  // load-balance 策略
+
+	// mihomo 的分组扩展字段
+	Icon          string `yaml:"icon,omitempty"`           // 客户端面板中显示的图标地址
+	Hidden        bool   `yaml:"hidden,omitempty"`         // 在客户端面板中隐藏该分组
+	IncludeAll    bool   `yaml:"include-all,omitempty"`    // 由客户端引入全部节点与 proxy-provider
+	ExcludeFilter string `yaml:"exclude-filter,omitempty"` // 引入节点时排除名称匹配的节点
+
+	// Extra 保留模板中其余的分组字段（如 use、filter、disable-udp），原样输出
+	Extra map[string]interface{} `yaml:",inline"`
+
+	// NodeFilters 为模板中展开为节点的占位符对应的名称正则（${proxies:<regex>} 的正则、地区分组的地区正则，
+	// ${proxies} 为空串），不输出；proxy-provider 模式据此生成 provider 的筛选，节点增减或改名后分组仍能匹配
+	NodeFilters []string `yaml:"-"`
+}
+
+// Dynamic 判断分组成员是否由客户端通过 use 或 include-all 等字段确定，此类分组的 proxies 可以为空
+func (g ProxyGroup) Dynamic() bool {
+	return g.IncludeAll || g.Extra["use"] != nil || g.Extra["include-all-proxies"] == true || g.Extra["include-all-providers"] == true
+}
+
+// DefaultConfig 返回内置的最小配置，在模板不可用时使用
+func DefaultConfig(proxies []Proxy, proxyNames []string) Config {
+	return Config{
+		Port:         7890,
+		SocksPort:    7891,
+		AllowLan:     true,
+		Mode:         "Rule",
+		LogLevel:     "info",
+		ExternalCtrl: "127.0.0.1:9090",
+		Proxies:      proxies,
+		ProxyGroups: []ProxyGroup{
+			{
+				Name:    "PROXY",
+				Type:    "select",
+				Proxies: append([]string{"DIRECT", "REJECT"}, proxyNames...),
+			},
+		},
+		Rules: []string{
+			"MATCH,DIRECT",
+		},
+	}
+}
+
+// MarshalYAML 以模板文档为底输出配置：模板中已有的键替换为生成的值，其余模板键原样保留，
+// 模板中没有的非零生成键一并输出，最后按 orderTopLevelKeys 排列
+func (c Config) MarshalYAML() (interface{}, error) {
+	type plain Config
+	var generated yaml.Node
+	if err := generated.Encode(plain(c)); err != nil {
+		return nil, err
+	}
+	if len(c.ExtraProxies) > 0 {
+		for i := 0; i+1 < len(generated.Content); i += 2 {
+			if generated.Content[i].Value == "proxies" {
+				seq := generated.Content[i+1]
+				seq.Content = append(append([]*yaml.Node(nil), c.ExtraProxies...), seq.Content...)
+				seq.Style = 0
+			}
+		}
+	}
+	if c.Template == nil {
+		return orderTopLevelKeys(&generated), nil
+	}
+
+	values := make(map[string]*yaml.Node, len(generated.Content)/2)
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		values[generated.Content[i].Value] = generated.Content[i+1]
+	}
+
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	used := make(map[string]bool, len(values))
+	for i := 0; i+1 < len(c.Template.Content); i += 2 {
+		key, value := c.Template.Content[i], c.Template.Content[i+1]
+		if v, ok := values[key.Value]; ok {
+			if v.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+				if key.Value == "proxy-providers" {
+					// 模板中自带的 proxy-provider 保留，与生成的同名时使用生成的
+					v = mergeMapping(value, v)
+				} else {
+					// 如 rule-providers，沿用模板中的条目顺序
+					orderLike(v, value)
+				}
+			}
+			// 被替换的键去掉模板中的注释，避免输出模板说明
+			key = &yaml.Node{Kind: key.Kind, Tag: key.Tag, Value: key.Value}
+			value = v
+			used[key.Value] = true
+		}
+		out.Content = append(out.Content, key, value)
+	}
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		key, value := generated.Content[i], generated.Content[i+1]
+		if !used[key.Value] && !isEmptyNode(value) {
+			out.Content = append(out.Content, key, value)
+		}
+	}
+	return orderTopLevelKeys(out), nil
+}
+
+var (
+	// leadingKeys 为 Clash 配置惯用的开头顺序，trailingKeys 固定在末尾，其余键保持模板中的顺序位于两者之间
+	leadingKeys  = []string{"port", "socks-port", "mixed-port", "redir-port", "tproxy-port", "allow-lan", "bind-address", "mode", "log-level", "ipv6", "external-controller", "secret"}
+	trailingKeys = []string{"proxies", "proxy-providers", "proxy-groups", "rule-providers", "rules"}
+)
+
+// orderTopLevelKeys 按 Clash 惯例排列顶层键，使每次生成的配置顺序一致、便于比对
+func orderTopLevelKeys(n *yaml.Node) *yaml.Node {
+	rank := make(map[string]int, len(leadingKeys)+len(trailingKeys))
+	for i, k := range leadingKeys {
+		rank[k] = i - len(leadingKeys)
+	}
+	for i, k := range trailingKeys {
+		rank[k] = i + 1
+	}
+	sortMapping(n, rank)
+	return n
+}
+
+// orderLike 将映射 n 的条目按 ref 中同名键的顺序排列，ref 中没有的键保持原顺序排在后面
+func orderLike(n, ref *yaml.Node) {
+	rank := make(map[string]int, len(ref.Content)/2)
+	for i := 0; i+1 < len(ref.Content); i += 2 {
+		rank[ref.Content[i].Value] = i/2 - len(ref.Content)
+	}
+	sortMapping(n, rank)
+}
+
+// mergeMapping 返回 base 中不在 over 里的条目加上 over 的全部条目
+func mergeMapping(base, over *yaml.Node) *yaml.Node {
+	keys := make(map[string]bool, len(over.Content)/2)
+	for i := 0; i+1 < len(over.Content); i += 2 {
+		keys[over.Content[i].Value] = true
+	}
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		if !keys[base.Content[i].Value] {
+			out.Content = append(out.Content, base.Content[i], base.Content[i+1])
+		}
+	}
+	out.Content = append(out.Content, over.Content...)
+	return out
+}
+
+// sortMapping 按 rank 稳定排序映射节点的键值对，未出现在 rank 中的键视为 0
+func sortMapping(n *yaml.Node, rank map[string]int) {
+	pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return rank[pairs[i][0].Value] < rank[pairs[j][0].Value]
+	})
+	n.Content = n.Content[:0]
+	for _, p := range pairs {
+		n.Content = append(n.Content, p[0], p[1])
+	}
+}
+
+// NodeName 返回 YAML 映射节点中 name 键的值，用于读取原样保留的节点名称
+func NodeName(n *yaml.Node) string {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "name" {
+			return n.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// isEmptyNode 判断生成值是否为零值（模板未设置的键不再输出 socks-port: 0 之类的默认值）
+func isEmptyNode(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return n.Value == "" || n.Value == "0" || n.Value == "false"
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
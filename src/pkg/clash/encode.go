@@ -0,0 +1,128 @@
+package clash
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// encodeBatch 为 WriteYAML 每次编码的节点数。yaml.v3 的编码器在整个文档编码完之前不会释放事件队列，
+// 分批编码使其占用的内存与节点总数无关
+const encodeBatch = 256
+
+// WriteYAML 将配置写入 w，输出与 yaml.Marshal(c) 相同。proxies 与 proxy-groups 分批编码后直接写出，
+// 不为整份配置构建 YAML 节点树，转换数千个节点时内存占用明显低于 yaml.Marshal
+func (c Config) WriteYAML(w io.Writer) error {
+	proxies, extra, groups := c.Proxies, c.ExtraProxies, c.ProxyGroups
+	// 用单个元素占位，使顶层键的取舍与顺序和完整配置一致，再在占位处写出实际内容
+	if len(proxies) > 0 || len(extra) > 0 {
+		c.Proxies, c.ExtraProxies = []Proxy{{}}, nil
+	}
+	if len(groups) > 0 {
+		c.ProxyGroups = []ProxyGroup{{}}
+	}
+	v, err := c.MarshalYAML()
+	if err != nil {
+		return err
+	}
+	top := v.(*yaml.Node)
+
+	bw := bufio.NewWriter(w)
+	pending := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	flush := func() error {
+		if len(pending.Content) == 0 {
+			return nil
+		}
+		err := writeYAML(bw, pending, "")
+		pending.Content = pending.Content[:0]
+		return err
+	}
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		key, value := top.Content[i], top.Content[i+1]
+		switch {
+		case key.Value == "proxies" && (len(proxies) > 0 || len(extra) > 0):
+			if err := startKey(bw, key, flush); err != nil {
+				return err
+			}
+			if len(extra) > 0 {
+				if err := writeYAML(bw, extra, "    "); err != nil {
+					return err
+				}
+			}
+			for lo := 0; lo < len(proxies); lo += encodeBatch {
+				if err := writeYAML(bw, proxies[lo:min(lo+encodeBatch, len(proxies))], "    "); err != nil {
+					return err
+				}
+			}
+		case key.Value == "proxy-groups" && len(groups) > 0:
+			if err := startKey(bw, key, flush); err != nil {
+				return err
+			}
+			// 每个分组可能包含全部节点名称，逐个编码
+			for i := range groups {
+				if err := writeYAML(bw, groups[i:i+1], "    "); err != nil {
+					return err
+				}
+			}
+		default:
+			pending.Content = append(pending.Content, key, value)
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// startKey 写出之前累积的键后写出分批编码的键名
+func startKey(w *bufio.Writer, key *yaml.Node, flush func() error) error {
+	if err := flush(); err != nil {
+		return err
+	}
+	_, err := w.WriteString(key.Value + ":\n")
+	return err
+}
+
+// writeYAML 编码 v 并为每个非空行加上 indent 前缀后写入 w
+func writeYAML(w *bufio.Writer, v interface{}, indent string) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if indent == "" {
+		_, err = w.Write(data)
+		return err
+	}
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		if len(line) > 1 {
+			w.WriteString(indent)
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteProxies 只写出 proxies 列表，即 proxy-provider 下载的节点列表格式
+func WriteProxies(w io.Writer, proxies []Proxy) error {
+	bw := bufio.NewWriter(w)
+	if len(proxies) == 0 {
+		bw.WriteString("proxies: []\n")
+		return bw.Flush()
+	}
+	bw.WriteString("proxies:\n")
+	for lo := 0; lo < len(proxies); lo += encodeBatch {
+		if err := writeYAML(bw, proxies[lo:min(lo+encodeBatch, len(proxies))], "    "); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
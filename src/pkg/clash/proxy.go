@@ -0,0 +1,168 @@
+// Package clash 定义 Clash（Meta 兼容）配置中的代理、分组与完整配置结构，以及其 YAML 输出方式
+package clash
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Proxy 代表 Clash 配置中的一个代理项
+type Proxy struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	UUID     string `yaml:"uuid"`
+	AlterID  int    `yaml:"alterId"`
+	Cipher   string `yaml:"cipher"`
+	TLS      bool   `yaml:"tls"`
+	Network  string `yaml:"network,omitempty"`
+	SkipCert bool   `yaml:"skip-cert-verify"`
+
+	WSOpts   *WSOptions   `yaml:"ws-opts,omitempty"`
+	HTTPOpts *HTTPOptions `yaml:"http-opts,omitempty"`
+	H2Opts   *H2Options   `yaml:"h2-opts,omitempty"`
+	GRPCOpts *GRPCOptions `yaml:"grpc-opts,omitempty"`
+
+	ServerName string `yaml:"servername,omitempty"` // TLS SNI
+
+	// 出站绑定，供 Linux 路由器上的策略路由使用
+	InterfaceName string `yaml:"interface-name,omitempty"`
+	RoutingMark   int    `yaml:"routing-mark,omitempty"`
+
+	// 以下为 mihomo（Meta 内核）支持的连接选项
+	TFO   bool `yaml:"tfo,omitempty"`   // TCP Fast Open
+	MPTCP bool `yaml:"mptcp,omitempty"` // Multipath TCP
+
+	IPVersion string `yaml:"ip-version,omitempty"` // 解析服务器域名时使用的地址族：dual / ipv4 / ipv6 / ipv4-prefer / ipv6-prefer
+
+	// vmess 的 UDP 与填充选项，部分机场的 UDP 需要 packet-encoding 才能使用
+	PacketEncoding      string `yaml:"packet-encoding,omitempty"` // packetaddr / xudp
+	GlobalPadding       bool   `yaml:"global-padding,omitempty"`
+	AuthenticatedLength bool   `yaml:"authenticated-length,omitempty"`
+
+	// 以下为转换过程中附加的元数据，不输出到配置
+	Region   string        `yaml:"-"` // 按名称识别的地区代码，如 HK
+	Country  string        `yaml:"-"` // GeoIP 查询到的服务器所在国家代码
+	Latency  time.Duration `yaml:"-"` // TCP 握手耗时，0 表示未测或不可达
+	Response time.Duration `yaml:"-"` // 入口响应时间（连接、TLS 握手到收到首个响应字节），0 表示未测或失败
+}
+
+// MarshalYAML 将 IPv6 地址的 server 输出为带引号的字符串，避免客户端的 YAML 解析器误解冒号
+func (p Proxy) MarshalYAML() (interface{}, error) {
+	type plain Proxy
+	ip := net.ParseIP(p.Server)
+	if ip == nil || ip.To4() != nil {
+		// 只有 IPv6 地址需要改写节点树，其余节点直接编码，省去一次 YAML 编码与解析
+		return plain(p), nil
+	}
+	var n yaml.Node
+	if err := n.Encode(plain(p)); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "server" {
+			n.Content[i+1].Style = yaml.DoubleQuotedStyle
+		}
+	}
+	return &n, nil
+}
+
+// RegionCode 返回节点地区：优先按名称识别，识别不到时使用 GeoIP 结果
+func (p Proxy) RegionCode() string {
+	if p.Region != "" {
+		return p.Region
+	}
+	return p.Country
+}
+
+// reservedNameSuffix 附加在与内置策略同名的节点名称后
+const reservedNameSuffix = " (node)"
+
+// SanitizeNames 将节点名称改写为可安全用于配置与规则的形式：去除控制字符、不可见的格式字符与首尾空白，
+// 逗号（规则的分隔符）替换为全角逗号，与内置策略（DIRECT、REJECT 等）同名的节点追加 " (node)"，空名称改为 "node"。
+// 引号、冒号等 YAML 特殊字符由编码时加引号处理，无需改写。同一名称总是得到相同的结果，分组引用的名称随节点一起改变
+func SanitizeNames(proxies []Proxy) {
+	for i := range proxies {
+		proxies[i].Name = SanitizeName(proxies[i].Name)
+	}
+}
+
+// SanitizeName 返回单个名称的安全形式，规则同 SanitizeNames
+func SanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToValidUTF8(name, "") {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			b.WriteRune(' ')
+		case unicode.IsControl(r):
+		case unicode.Is(unicode.Cf, r) && r != '\u200d':
+			// 零宽空格、BOM、方向控制符等；保留组合 emoji 使用的零宽连接符
+		case r == ',':
+			b.WriteRune('，')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name = strings.TrimSpace(b.String())
+	switch {
+	case name == "":
+		return "node"
+	case builtinTargets[strings.ToUpper(name)]:
+		return name + reservedNameSuffix
+	}
+	return name
+}
+
+// AvoidNames 为与 taken 中名称（如 ?base= 配置中已有的节点与分组）重名的节点追加 " 2"、" 3"……
+func AvoidNames(proxies []Proxy, taken map[string]bool) {
+	used := make(map[string]bool, len(proxies)+len(taken))
+	for name := range taken {
+		used[name] = true
+	}
+	for _, p := range proxies {
+		used[p.Name] = true
+	}
+	for i := range proxies {
+		name := proxies[i].Name
+		if !taken[name] {
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s %d", name, n)
+			if !used[candidate] {
+				used[candidate] = true
+				proxies[i].Name = candidate
+				break
+			}
+		}
+	}
+}
+
+// DedupeNames 为重名节点依次追加 " 2"、" 3"……，Clash 不接受重复的代理名称
+func DedupeNames(proxies []Proxy) {
+	used := make(map[string]bool, len(proxies))
+	for _, p := range proxies {
+		used[p.Name] = false
+	}
+	for i := range proxies {
+		name := proxies[i].Name
+		if !used[name] {
+			used[name] = true
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s %d", name, n)
+			if _, taken := used[candidate]; !taken {
+				used[candidate] = true
+				proxies[i].Name = candidate
+				break
+			}
+		}
+	}
+}
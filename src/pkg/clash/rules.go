@@ -0,0 +1,218 @@
+package clash
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ruleTypes 为 Clash（含 Meta 内核）支持的带匹配内容的规则类型
+var ruleTypes = map[string]bool{
+	"DOMAIN": true, "DOMAIN-SUFFIX": true, "DOMAIN-KEYWORD": true, "DOMAIN-REGEX": true, "GEOSITE": true,
+	"GEOIP": true, "IP-CIDR": true, "IP-CIDR6": true, "IP-SUFFIX": true, "IP-ASN": true,
+	"SRC-GEOIP": true, "SRC-IP-ASN": true, "SRC-IP-CIDR": true, "SRC-IP-SUFFIX": true,
+	"DST-PORT": true, "SRC-PORT": true, "IN-PORT": true, "IN-TYPE": true, "IN-USER": true, "IN-NAME": true,
+	"PROCESS-NAME": true, "PROCESS-PATH": true, "PROCESS-NAME-REGEX": true, "PROCESS-PATH-REGEX": true,
+	"UID": true, "NETWORK": true, "DSCP": true, "RULE-SET": true,
+}
+
+// logicRuleTypes 的匹配内容为括号包裹的子规则，如 AND,((DOMAIN,a.com),(NETWORK,UDP)),DIRECT
+var logicRuleTypes = map[string]bool{"AND": true, "OR": true, "NOT": true}
+
+// builtinTargets 为无需定义即可使用的策略
+var builtinTargets = map[string]bool{
+	"DIRECT": true, "REJECT": true, "REJECT-DROP": true, "PASS": true, "COMPATIBLE": true, "GLOBAL": true,
+}
+
+// ruleOptions 为规则末尾可附加的参数
+var ruleOptions = map[string]bool{"no-resolve": true, "src": true}
+
+// RuleError 描述一条 Clash 内核无法加载的规则
+type RuleError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("invalid rule %q: %s", e.Rule, e.Reason)
+}
+
+// CheckRules 去除重复的规则（保留第一条），并检查其余规则的类型、参数个数、匹配内容
+// 以及引用的策略与 rule-provider 是否存在。返回保留的有效规则、重复规则数与无效规则
+func (c Config) CheckRules() ([]string, int, []*RuleError) {
+	targets := make(map[string]bool, len(c.ProxyGroups)+len(c.Proxies))
+	for _, g := range c.ProxyGroups {
+		targets[g.Name] = true
+	}
+	for _, p := range c.Proxies {
+		targets[p.Name] = true
+	}
+	for _, n := range c.ExtraProxies {
+		targets[NodeName(n)] = true
+	}
+
+	rules := make([]string, 0, len(c.Rules))
+	seen := make(map[string]bool, len(c.Rules))
+	duplicates := 0
+	var invalid []*RuleError
+	for _, rule := range c.Rules {
+		parts := splitRule(rule)
+		key := strings.Join(parts, ",")
+		if seen[key] {
+			duplicates++
+			continue
+		}
+		seen[key] = true
+		if reason := c.checkRule(parts, targets); reason != "" {
+			invalid = append(invalid, &RuleError{Rule: rule, Reason: reason})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, duplicates, invalid
+}
+
+// checkRule 返回规则无效的原因，有效时返回空字符串
+func (c Config) checkRule(parts []string, targets map[string]bool) string {
+	typ := strings.ToUpper(parts[0])
+	if typ == "MATCH" {
+		if len(parts) != 2 {
+			return "MATCH takes only a target"
+		}
+		return checkTarget(parts[1], targets)
+	}
+	if typ == "SUB-RULE" {
+		// 目标为 sub-rules 中的名称，不在生成的配置中检查
+		if len(parts) != 3 {
+			return "want SUB-RULE,(rule),name"
+		}
+		return checkLogicPayload("SUB-RULE", parts[1])
+	}
+	if !ruleTypes[typ] && !logicRuleTypes[typ] {
+		return fmt.Sprintf("unknown rule type %s", parts[0])
+	}
+	if len(parts) < 3 {
+		return "missing target"
+	}
+	for _, opt := range parts[3:] {
+		if !ruleOptions[opt] {
+			return fmt.Sprintf("unknown option %q", opt)
+		}
+	}
+	if reason := c.checkPayload(typ, parts[1]); reason != "" {
+		return reason
+	}
+	return checkTarget(parts[2], targets)
+}
+
+// checkPayload 检查规则的匹配内容
+func (c Config) checkPayload(typ, payload string) string {
+	if payload == "" {
+		return "empty payload"
+	}
+	switch typ {
+	case "AND", "OR", "NOT":
+		return checkLogicPayload(typ, payload)
+	case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR":
+		if _, err := netip.ParsePrefix(payload); err != nil {
+			return fmt.Sprintf("bad cidr %q", payload)
+		}
+	case "DST-PORT", "SRC-PORT", "IN-PORT":
+		if !validPorts(payload) {
+			return fmt.Sprintf("bad port %q", payload)
+		}
+	case "NETWORK":
+		if p := strings.ToLower(payload); p != "tcp" && p != "udp" {
+			return fmt.Sprintf("bad network %q (want tcp or udp)", payload)
+		}
+	case "RULE-SET":
+		if _, ok := c.RulesProviders[payload]; !ok {
+			return fmt.Sprintf("rule-provider %q not found", payload)
+		}
+	}
+	return ""
+}
+
+// checkLogicPayload 检查逻辑规则的子规则，如 ((DOMAIN,a.com),(NETWORK,UDP))
+func checkLogicPayload(typ, payload string) string {
+	if !strings.HasPrefix(payload, "(") || !strings.HasSuffix(payload, ")") {
+		return fmt.Sprintf("%s payload must be parenthesized", typ)
+	}
+	inner := payload
+	if typ != "SUB-RULE" {
+		inner = strings.TrimSpace(payload[1 : len(payload)-1])
+	}
+	subs := splitRule(inner)
+	if typ == "NOT" && len(subs) != 1 {
+		return "NOT takes exactly one rule"
+	}
+	for _, sub := range subs {
+		if !strings.HasPrefix(sub, "(") || !strings.HasSuffix(sub, ")") {
+			return fmt.Sprintf("bad sub-rule %q", sub)
+		}
+		parts := splitRule(sub[1 : len(sub)-1])
+		subType := strings.ToUpper(parts[0])
+		switch {
+		case logicRuleTypes[subType]:
+			if len(parts) != 2 {
+				return fmt.Sprintf("bad sub-rule %q", sub)
+			}
+			if reason := checkLogicPayload(subType, parts[1]); reason != "" {
+				return reason
+			}
+		case !ruleTypes[subType] || subType == "RULE-SET":
+			return fmt.Sprintf("unknown sub-rule type %s", parts[0])
+		case len(parts) < 2 || parts[1] == "":
+			return fmt.Sprintf("bad sub-rule %q", sub)
+		}
+	}
+	return ""
+}
+
+// checkTarget 检查规则的策略是否为已定义的代理组、节点或内置策略
+func checkTarget(target string, targets map[string]bool) string {
+	if target == "" {
+		return "missing target"
+	}
+	if !targets[target] && !builtinTargets[strings.ToUpper(target)] {
+		return fmt.Sprintf("unknown proxy or group %q", target)
+	}
+	return ""
+}
+
+// validPorts 检查端口表达式，如 443、8000-9000、80/443
+func validPorts(expr string) bool {
+	for _, item := range strings.Split(expr, "/") {
+		lo, hi, isRange := strings.Cut(item, "-")
+		if !isRange {
+			hi = lo
+		}
+		a, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		b, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || a < 0 || b > 65535 || a > b {
+			return false
+		}
+	}
+	return true
+}
+
+// splitRule 按不在括号内的逗号拆分规则并去掉各部分两端的空白
+func splitRule(rule string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range rule {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(rule[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(rule[start:]))
+}
@@ -0,0 +1,97 @@
+package clash
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// 规则集（rule-provider）的 behavior
+const (
+	BehaviorDomain    = "domain"    // 每行一个域名，+.example.com 匹配域名及其子域名
+	BehaviorIPCIDR    = "ipcidr"    // 每行一个 IP 段
+	BehaviorClassical = "classical" // 每行一条不含策略的经典规则，如 DOMAIN-SUFFIX,example.com
+)
+
+// RuleEntry 为规则集中的一条，以经典规则的类型与内容表示
+type RuleEntry struct {
+	Type    string   // DOMAIN、DOMAIN-SUFFIX、IP-CIDR 等
+	Payload string   // 匹配内容
+	Options []string // 如 no-resolve
+}
+
+// ParseRuleEntry 按 behavior 解析规则集中的一行，空行与注释返回 false。
+// 内容与 behavior 不符时按实际语法解析，如 domain 规则集中的 DOMAIN-SUFFIX,example.com
+func ParseRuleEntry(behavior, line string) (RuleEntry, bool) {
+	line = strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- ")), `'"`)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";") {
+		return RuleEntry{}, false
+	}
+
+	if typ, rest, ok := strings.Cut(line, ","); ok && isRuleType(typ) {
+		parts := splitRule(rest)
+		return RuleEntry{Type: strings.ToUpper(strings.TrimSpace(typ)), Payload: parts[0], Options: parts[1:]}, true
+	}
+	if behavior == BehaviorIPCIDR || behavior == BehaviorClassical {
+		if e, ok := parseIPEntry(line); ok {
+			return e, true
+		}
+		if behavior == BehaviorIPCIDR {
+			return RuleEntry{}, false
+		}
+	}
+	switch {
+	case strings.HasPrefix(line, "+."):
+		return RuleEntry{Type: "DOMAIN-SUFFIX", Payload: line[2:]}, true
+	case strings.HasPrefix(line, "*."):
+		// 旧内核不支持通配，近似为后缀匹配
+		return RuleEntry{Type: "DOMAIN-SUFFIX", Payload: line[2:]}, true
+	case strings.HasPrefix(line, "."):
+		return RuleEntry{Type: "DOMAIN-SUFFIX", Payload: line[1:]}, true
+	default:
+		return RuleEntry{Type: "DOMAIN", Payload: line}, true
+	}
+}
+
+// parseIPEntry 解析 IP 段或单个 IP
+func parseIPEntry(s string) (RuleEntry, bool) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return RuleEntry{}, false
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if prefix.Addr().Is6() {
+		return RuleEntry{Type: "IP-CIDR6", Payload: prefix.String()}, true
+	}
+	return RuleEntry{Type: "IP-CIDR", Payload: prefix.String()}, true
+}
+
+// isRuleType 判断 s 是否形如规则类型（字母、数字与 -，不含域名中的 .）
+func isRuleType(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z') && !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Rule 返回指向 target 的经典规则，条目的参数（如 no-resolve）排在策略之后
+func (e RuleEntry) Rule(target string) string {
+	return strings.Join(append([]string{e.Type, e.Payload, target}, e.Options...), ",")
+}
+
+// IsIP 判断条目是否按 IP 匹配，此类规则可附加 no-resolve
+func (e RuleEntry) IsIP() bool {
+	switch e.Type {
+	case "GEOIP", "IP-CIDR", "IP-CIDR6", "IP-SUFFIX", "IP-ASN":
+		return true
+	}
+	return false
+}
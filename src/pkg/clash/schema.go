@@ -0,0 +1,219 @@
+package clash
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaError 描述生成的配置中客户端无法加载的一处问题
+type SchemaError struct {
+	Path   string // 问题所在位置，如 proxies["香港 01"]、proxy-groups["🇭🇰 HK"]、rules
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+// proxyRequired 为各节点类型除 name、type、server、port 外必须设置的字段（YAML 键）
+var proxyRequired = map[string][]string{
+	"ss":        {"cipher", "password"},
+	"ssr":       {"cipher", "password", "obfs", "protocol"},
+	"vmess":     {"uuid", "cipher"},
+	"vless":     {"uuid"},
+	"trojan":    {"password"},
+	"snell":     {"psk"},
+	"hysteria2": {"password"},
+	"tuic":      {"uuid"},
+	"anytls":    {"password"},
+	"wireguard": {"private-key"},
+	"ssh":       {"username"},
+}
+
+// groupTypes 为代理组支持的类型
+var groupTypes = map[string]bool{"select": true, "url-test": true, "fallback": true, "load-balance": true, "relay": true}
+
+// Validate 按 Clash / mihomo 的加载规则检查配置：节点的必需字段、名称唯一、分组类型与成员、
+// use 引用的 proxy-provider、分组之间的循环引用，以及规则引用的策略与 rule-provider。返回发现的全部问题
+func (c Config) Validate() []*SchemaError {
+	var errs []*SchemaError
+	names := make(map[string]string, len(c.Proxies)+len(c.ExtraProxies)+len(c.ProxyGroups))
+	addName := func(name, path string) {
+		if prev, ok := names[name]; ok {
+			errs = append(errs, &SchemaError{Path: path, Reason: fmt.Sprintf("name already used by %s", prev)})
+			return
+		}
+		names[name] = path
+	}
+
+	for i, n := range c.ExtraProxies {
+		path := proxyPath(NodeName(n), i)
+		errs = append(errs, checkProxy(n, path)...)
+		addName(NodeName(n), path)
+	}
+	for i, p := range c.Proxies {
+		path := proxyPath(p.Name, len(c.ExtraProxies)+i)
+		var n yaml.Node
+		if err := n.Encode(p); err != nil {
+			errs = append(errs, &SchemaError{Path: path, Reason: err.Error()})
+			continue
+		}
+		errs = append(errs, checkProxy(&n, path)...)
+		addName(p.Name, path)
+	}
+
+	groups := make(map[string]ProxyGroup, len(c.ProxyGroups))
+	for i, g := range c.ProxyGroups {
+		path := fmt.Sprintf("proxy-groups[%d]", i)
+		if g.Name != "" {
+			path = fmt.Sprintf("proxy-groups[%q]", g.Name)
+		} else {
+			errs = append(errs, &SchemaError{Path: path, Reason: "missing name"})
+		}
+		addName(g.Name, path)
+		groups[g.Name] = g
+	}
+	providers := c.proxyProviderNames()
+	for _, g := range c.ProxyGroups {
+		path := fmt.Sprintf("proxy-groups[%q]", g.Name)
+		if !groupTypes[g.Type] {
+			errs = append(errs, &SchemaError{Path: path, Reason: fmt.Sprintf("unknown type %q", g.Type)})
+		}
+		if len(g.Proxies) == 0 && !g.Dynamic() {
+			errs = append(errs, &SchemaError{Path: path, Reason: "no proxies"})
+		}
+		for _, member := range g.Proxies {
+			if _, ok := names[member]; !ok && !builtinTargets[member] {
+				errs = append(errs, &SchemaError{Path: path, Reason: fmt.Sprintf("unknown proxy or group %q", member)})
+			}
+		}
+		use, _ := g.Extra["use"].([]interface{})
+		for _, u := range use {
+			if name, _ := u.(string); !providers[name] {
+				errs = append(errs, &SchemaError{Path: path, Reason: fmt.Sprintf("proxy-provider %v not found", u)})
+			}
+		}
+	}
+	if loop := groupLoop(c.ProxyGroups, groups); loop != "" {
+		errs = append(errs, &SchemaError{Path: "proxy-groups", Reason: "loop " + loop})
+	}
+
+	for name, p := range c.ProxyProviders {
+		if p.Type == "http" && p.URL == "" {
+			errs = append(errs, &SchemaError{Path: fmt.Sprintf("proxy-providers[%q]", name), Reason: "missing url"})
+		}
+	}
+	_, _, invalid := c.CheckRules()
+	for _, e := range invalid {
+		errs = append(errs, &SchemaError{Path: "rules", Reason: e.Error()})
+	}
+	return errs
+}
+
+// proxyPath 返回节点在问题中的位置，名称为空时使用序号
+func proxyPath(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("proxies[%d]", i)
+	}
+	return fmt.Sprintf("proxies[%q]", name)
+}
+
+// checkProxy 检查节点映射的通用字段与该类型的必需字段
+func checkProxy(n *yaml.Node, path string) []*SchemaError {
+	if n.Kind != yaml.MappingNode {
+		return []*SchemaError{{Path: path, Reason: "not a mapping"}}
+	}
+	fields := make(map[string]string, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		fields[n.Content[i].Value] = n.Content[i+1].Value
+	}
+
+	var errs []*SchemaError
+	missing := func(key string) {
+		errs = append(errs, &SchemaError{Path: path, Reason: "missing " + key})
+	}
+	for _, key := range []string{"name", "type"} {
+		if fields[key] == "" {
+			missing(key)
+		}
+	}
+	typ := fields["type"]
+	if _, peers := fields["peers"]; !(typ == "wireguard" && peers) {
+		// wireguard 可以只在 peers 中设置服务器
+		if fields["server"] == "" {
+			missing("server")
+		}
+		if port, err := strconv.Atoi(fields["port"]); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, &SchemaError{Path: path, Reason: fmt.Sprintf("bad port %q", fields["port"])})
+		}
+	}
+	for _, key := range proxyRequired[typ] {
+		if fields[key] == "" {
+			missing(key)
+		}
+	}
+	return errs
+}
+
+// proxyProviderNames 返回生成的与模板中原有的 proxy-provider 名称
+func (c Config) proxyProviderNames() map[string]bool {
+	names := make(map[string]bool, len(c.ProxyProviders))
+	for name := range c.ProxyProviders {
+		names[name] = true
+	}
+	if c.Template != nil {
+		for i := 0; i+1 < len(c.Template.Content); i += 2 {
+			if c.Template.Content[i].Value != "proxy-providers" {
+				continue
+			}
+			m := c.Template.Content[i+1]
+			for j := 0; j+1 < len(m.Content); j += 2 {
+				names[m.Content[j].Value] = true
+			}
+		}
+	}
+	return names
+}
+
+// groupLoop 查找分组之间的循环引用（Clash 拒绝加载），返回如 "A -> B -> A" 的路径，没有循环时返回空字符串
+func groupLoop(order []ProxyGroup, groups map[string]ProxyGroup) string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(groups))
+	var stack []string
+	var visit func(name string) string
+	visit = func(name string) string {
+		switch state[name] {
+		case visiting:
+			loop := name
+			for i := len(stack) - 1; i >= 0 && stack[i] != name; i-- {
+				loop = stack[i] + " -> " + loop
+			}
+			return name + " -> " + loop
+		case done:
+			return ""
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, member := range groups[name].Proxies {
+			if _, ok := groups[member]; ok {
+				if loop := visit(member); loop != "" {
+					return loop
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return ""
+	}
+	for _, g := range order {
+		if loop := visit(g.Name); loop != "" {
+			return loop
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package clash
+
+// WSOptions 对应 Clash 的 ws-opts
+type WSOptions struct {
+	Path                string            `yaml:"path,omitempty"`
+	Headers             map[string]string `yaml:"headers,omitempty"`
+	MaxEarlyData        int               `yaml:"max-early-data,omitempty"`
+	EarlyDataHeaderName string            `yaml:"early-data-header-name,omitempty"`
+}
+
+// HTTPOptions 对应 Clash 的 http-opts（network: http，即 TCP + HTTP 伪装）
+type HTTPOptions struct {
+	Method  string              `yaml:"method,omitempty"`
+	Path    []string            `yaml:"path,omitempty"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+}
+
+// H2Options 对应 Clash 的 h2-opts
+type H2Options struct {
+	Host []string `yaml:"host,omitempty"`
+	Path string   `yaml:"path,omitempty"`
+}
+
+// GRPCOptions 对应 Clash 的 grpc-opts
+type GRPCOptions struct {
+	ServiceName string `yaml:"grpc-service-name,omitempty"`
+}
+
+// TransportHost 返回传输层伪装使用的 Host，未设置时为空
+func (p *Proxy) TransportHost() string {
+	switch {
+	case p.WSOpts != nil:
+		return p.WSOpts.Headers["Host"]
+	case p.HTTPOpts != nil && len(p.HTTPOpts.Headers["Host"]) > 0:
+		return p.HTTPOpts.Headers["Host"][0]
+	case p.H2Opts != nil && len(p.H2Opts.Host) > 0:
+		return p.H2Opts.Host[0]
+	}
+	return ""
+}
+
+// WithHost 为 ws 与 http 伪装补充 Host 头，已有 Host 时保持不变。设置为副本，不修改其他节点共享的数据
+func (p *Proxy) WithHost(host string) {
+	if p.TransportHost() != "" {
+		return
+	}
+	switch {
+	case p.WSOpts != nil:
+		ws := *p.WSOpts
+		ws.Headers = copyHeaders(ws.Headers)
+		ws.Headers["Host"] = host
+		p.WSOpts = &ws
+	case p.HTTPOpts != nil:
+		h := *p.HTTPOpts
+		headers := make(map[string][]string, len(h.Headers)+1)
+		for k, v := range h.Headers {
+			headers[k] = v
+		}
+		headers["Host"] = []string{host}
+		h.Headers = headers
+		p.HTTPOpts = &h
+	}
+}
+
+func copyHeaders(src map[string]string) map[string]string {
+	dst := make(map[string]string, len(src)+1)
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"pkg/main.go/src/pkg/generator"
+	"pkg/main.go/src/pkg/parser"
+)
+
+// newRootCmd 创建命令行入口；不带子命令时等同于 serve
+func newRootCmd() *cobra.Command {
+	var (
+		cfg        *Config
+		configFile string
+	)
+	root := &cobra.Command{
+		Use:          "clashConvertTool",
+		Short:        "Convert subscriptions into Clash configs",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if cfg, err = Init(configFile); err != nil {
+				return fmt.Errorf("failed to load config: %v", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(cfg)
+		},
+	}
+	// --listen 与 --log-level 绑定到同名配置键，命令行显式指定时优先于配置文件与环境变量
+	flags := root.PersistentFlags()
+	flags.StringVarP(&configFile, "config", "c", "", "config file (default ./config.yaml or ./configs/config.yaml)")
+	flags.String("listen", "", "HTTP listen address (default :8088)")
+	flags.String("log-level", "", "log level: debug, info, warn, error (default info)")
+	viper.BindPFlag("listen", flags.Lookup("listen"))
+	viper.BindPFlag("log-level", flags.Lookup("log-level"))
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Run the HTTP converter service",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return serve(cfg)
+			},
+		},
+		newConvertCmd(&cfg),
+		newValidateCmd(&cfg),
+		newFetchCmd(&cfg),
+	)
+	return root
+}
+
+// subscriptionFlags 为 convert 与 fetch 共用的订阅参数
+type subscriptionFlags struct {
+	url string
+	sub string
+}
+
+func (f *subscriptionFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.url, "url", "u", "", "subscription url (default: url in config.yaml)")
+	cmd.Flags().StringVarP(&f.sub, "sub", "s", "", "named subscription")
+}
+
+// apply 将命令行指定的订阅写入参数表
+func (f *subscriptionFlags) apply(params url.Values) {
+	if f.url != "" {
+		params.Set("url", f.url)
+	}
+	if f.sub != "" {
+		params.Set("sub", f.sub)
+	}
+}
+
+// resolveCLISubscription 解析命令行参数对应的订阅地址
+func resolveCLISubscription(params url.Values) (string, url.Values, error) {
+	subURL, params, err := resolveOperatorSubscription(params)
+	if err != nil {
+		return "", nil, err
+	}
+	if subURL == "" {
+		return "", nil, fmt.Errorf("no subscription url, use -u or -s")
+	}
+	return subURL, params, nil
+}
+
+// newConvertCmd 执行一次转换后退出，供脚本与定时任务使用
+func newConvertCmd(cfg **Config) *cobra.Command {
+	var (
+		sub    subscriptionFlags
+		out    string
+		target string
+		tmpl   string
+		query  string
+	)
+	cmd := &cobra.Command{
+		Use:     "convert",
+		Short:   "Convert a subscription once and exit",
+		Example: `  clashConvertTool convert -u <sub> -o out.yaml -t clashmeta -q "include=HK&sort=name"`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := generator.Lookup(target); !ok {
+				return fmt.Errorf("unsupported target: %q (want %s)", target, strings.Join(generator.Targets(), ", "))
+			}
+			params, err := url.ParseQuery(query)
+			if err != nil {
+				return fmt.Errorf("invalid --query: %v", err)
+			}
+			sub.apply(params)
+			params.Set("target", target)
+			if tmpl != "" {
+				params.Set("template", tmpl)
+			}
+
+			if err := setup(*cfg); err != nil {
+				return err
+			}
+			defer flushTracing()
+			raw := params
+			subURL, params, err := resolveCLISubscription(params)
+			if err != nil {
+				return err
+			}
+			opts, err := parseOptions(params)
+			if err != nil {
+				return err
+			}
+			setProviderURL(&opts, "", raw)
+			data, _, err := processConvert(cmd.Context(), subURL, opts)
+			if err != nil {
+				return err
+			}
+
+			if out == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			return os.WriteFile(out, data, 0o644)
+		},
+	}
+	sub.register(cmd)
+	cmd.Flags().StringVarP(&out, "output", "o", "-", "output file, - for stdout")
+	cmd.Flags().StringVarP(&target, "target", "t", "clash", "target format: "+strings.Join(generator.Targets(), ", "))
+	cmd.Flags().StringVar(&tmpl, "template", "", "template name")
+	cmd.Flags().StringVarP(&query, "query", "q", "", "other converter parameters as a /config query string, e.g. include=HK&sort=name")
+	return cmd
+}
+
+// newValidateCmd 检查配置（存储、缓存、DNS、上游代理）能否初始化，以及所有模板能否正常渲染
+func newValidateCmd(cfg **Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check config.yaml and all templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := setup(*cfg); err != nil {
+				return err
+			}
+			failed := 0
+			for _, name := range templateNames() {
+				label := name
+				if label == "" {
+					label = "(default)"
+				}
+				if err := checkTemplate(name); err != nil {
+					failed++
+					fmt.Fprintf(cmd.OutOrStdout(), "FAIL %s: %v\n", label, err)
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "ok   %s\n", label)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d template(s) failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// newFetchCmd 拉取订阅并输出解码后的原始节点链接，便于排查解析问题
+func newFetchCmd(cfg **Config) *cobra.Command {
+	var sub subscriptionFlags
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch a subscription and print the decoded links",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := url.Values{}
+			sub.apply(params)
+
+			if err := setup(*cfg); err != nil {
+				return err
+			}
+			subURL, params, err := resolveCLISubscription(params)
+			if err != nil {
+				return err
+			}
+			for i, src := range splitSources(subURL) {
+				body, err := fetchSubscription(cmd.Context(), src, subscriptionFetch(params)[src])
+				if err != nil {
+					return err
+				}
+				if i > 0 {
+					fmt.Fprintln(cmd.OutOrStdout())
+				}
+				if _, err := io.Copy(cmd.OutOrStdout(), parser.NewDecoder(bytes.NewReader(body))); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	sub.register(cmd)
+	return cmd
+}
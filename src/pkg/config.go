@@ -2,31 +2,176 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Url string `mapstructure:"url"`
+	Url        string `mapstructure:"url"`
+	Listen     string `mapstructure:"listen"`      // HTTP 监听地址
+	AdminToken string `mapstructure:"admin-token"` // 管理接口鉴权 token，为空时禁用 /admin
+	DataDir    string `mapstructure:"data-dir"`    // 运行时数据（订阅列表等）存放目录
+	LogLevel   string `mapstructure:"log-level"`   // 日志级别：debug / info / warn / error
+	GinMode    string `mapstructure:"gin-mode"`    // gin 运行模式：release / debug / test
+
+	Storage  string `mapstructure:"storage"`  // 持久化后端：json / sqlite
+	Database string `mapstructure:"database"` // sqlite 数据库文件，默认 data-dir/clash-convert.db
+
+	Webhooks []WebhookConfig `mapstructure:"webhooks"` // 转换事件通知
+	Alerts   AlertConfig     `mapstructure:"alerts"`   // 到期与流量提醒，在定时刷新时检查
+
+	ProbeTimeout time.Duration `mapstructure:"probe-timeout"` // 节点延迟探测的默认超时
+	ProbeWorkers int           `mapstructure:"probe-workers"` // 并发探测的 worker 数量
+	Alive        bool          `mapstructure:"alive"`         // 是否默认丢弃不可达节点
+
+	GeoIPDB string `mapstructure:"geoip-db"` // MaxMind 格式的 GeoIP 数据库路径
+	GeoIP   bool   `mapstructure:"geoip"`    // 是否默认为节点标记国家
+
+	StripInfo bool `mapstructure:"strip-info"` // 是否默认移除机场信息伪节点
+	Resolve   bool `mapstructure:"resolve"`    // 是否默认将节点域名预解析为 IP
+
+	IPVersion string `mapstructure:"ip-version"` // 默认地址族：ipv4 / ipv6 / ipv4-prefer / ipv6-prefer / dual
+
+	TFO   bool `mapstructure:"tfo"`   // 是否默认为所有节点开启 TCP Fast Open（mihomo）
+	MPTCP bool `mapstructure:"mptcp"` // 是否默认为所有节点开启 Multipath TCP（mihomo）
+
+	PacketEncoding      string `mapstructure:"packet-encoding"`      // vmess 节点默认的 packet-encoding：packetaddr / xudp
+	GlobalPadding       bool   `mapstructure:"global-padding"`       // 是否默认为 vmess 节点开启 global-padding
+	AuthenticatedLength bool   `mapstructure:"authenticated-length"` // 是否默认为 vmess 节点开启 authenticated-length
+
+	InterfaceName string        `mapstructure:"interface-name"` // 节点默认绑定的出站网卡
+	RoutingMark   int           `mapstructure:"routing-mark"`   // 节点默认的 routing-mark（SO_MARK）
+	NodeRouting   []NodeRouting `mapstructure:"node-routing"`   // 按名称为节点单独设置出站网卡与 routing-mark
+
+	Zh string `mapstructure:"zh"` // 默认繁简体转换方式：simplified / traditional
+
+	Prefix string `mapstructure:"prefix"` // 默认节点名称前缀
+	Suffix string `mapstructure:"suffix"` // 默认节点名称后缀
+
+	Template         string            `mapstructure:"template"`           // 默认模板的文件路径或 URL
+	Templates        map[string]string `mapstructure:"templates"`          // 命名模板：名称 -> 文件路径或 URL
+	TemplateCacheTTL time.Duration     `mapstructure:"template-cache-ttl"` // 远程模板缓存时间
+
+	CustomRules []string          `mapstructure:"custom-rules"` // 插入到模板规则之前的自定义规则
+	StrictRules bool              `mapstructure:"strict-rules"` // 存在 Clash 无法加载的规则时转换失败，默认移除这些规则并记录警告
+	OutputCheck bool              `mapstructure:"output-check"` // 输出前检查配置（节点必需字段、分组引用、规则策略等），未通过时返回 502 与问题列表
+	GeositeURL  string            `mapstructure:"geosite-url"`  // 为旧版目标展开 GEOSITE 规则的域名列表地址，%s 为名称
+	Vars        map[string]string `mapstructure:"vars"`         // 模板变量 ${var:NAME} 的默认值
+
+	Tokens []TokenBinding `mapstructure:"tokens"` // 访问 token 及其绑定的默认订阅与参数
+	Users  []User         `mapstructure:"users"`  // 使用者 token 及其可访问的订阅集
+
+	BasicAuth []BasicAuth `mapstructure:"basic-auth"` // 以 HTTP Basic 认证代替 ?token= 的用户名与密码
+
+	TLS TLSConfig `mapstructure:"tls"` // 以 HTTPS 监听，可要求客户端证书
+
+	AuditMaxEntries int `mapstructure:"audit-max-entries"` // 保留的 /config 下载记录条数（见 /admin/audit），0 表示不记录
+	MaxShortLinks   int `mapstructure:"max-short-links"`   // POST /short 可保存的短链接数，0 表示不限制
+	MaxJobs         int `mapstructure:"max-jobs"`          // 内存中保留的异步任务数（含一小时内完成的任务），0 表示不限制
+
+	Subscriptions []Subscription `mapstructure:"subscriptions"` // 配置文件中的命名订阅，通过 /config/<name> 或 ?sub=<name> 访问
+	LocalNodes    []string       `mapstructure:"local-nodes"`   // 合并到每次转换的本地节点文件：节点链接或含 proxies 的 Clash 配置
+
+	ProxyProvider ProxyProviderConfig `mapstructure:"proxy-provider"` // proxy-provider 模式：配置中引用本服务的 /provider 而不内联节点
+
+	URLGuard URLGuardConfig `mapstructure:"url-guard"` // 限制请求参数中使用者提供的订阅与配置地址
+
+	FetchAllowHosts []string `mapstructure:"fetch-allow-hosts"` // 只拉取这些主机（含子域名）或 IP 段上的订阅，为空时不限制
+	FetchDenyHosts  []string `mapstructure:"fetch-deny-hosts"`  // 不拉取这些主机（含子域名）或 IP 段上的订阅，优先于 fetch-allow-hosts
+
+	FetchProxy string `mapstructure:"fetch-proxy"` // 拉取上游订阅使用的代理，如 socks5://127.0.0.1:1080
+	UserAgent  string `mapstructure:"user-agent"`  // 拉取上游订阅使用的 User-Agent
+	FetchHTTP  string `mapstructure:"fetch-http"`  // 拉取使用的 HTTP 协议：auto（HTTP/2 优先）/ http1 / http3
+
+	DNSServer string `mapstructure:"dns-server"` // 解析订阅与节点域名的加密 DNS，如 https://1.1.1.1/dns-query、tls://1.1.1.1
+
+	FetchRetries int           `mapstructure:"fetch-retries"` // 拉取失败后的重试次数
+	FetchBackoff time.Duration `mapstructure:"fetch-backoff"` // 首次重试前的等待时间，之后每次翻倍
+
+	FetchTimeout        time.Duration `mapstructure:"fetch-timeout"`         // 单次拉取的整体超时
+	FetchConnectTimeout time.Duration `mapstructure:"fetch-connect-timeout"` // 建立连接的超时
+
+	MaxSubscriptionSize string `mapstructure:"max-subscription-size"` // 单个订阅响应的大小上限，如 32MB，超出时按上游错误处理
+
+	FetchHostInterval time.Duration `mapstructure:"fetch-host-interval"` // 对同一上游主机两次请求的最小间隔，0 表示不限制
+	SourceTimeout     time.Duration `mapstructure:"source-timeout"`      // 多源订阅中单个来源（含重试）的超时，0 表示只受 fetch-timeout 限制
+
+	UpstreamCache bool `mapstructure:"upstream-cache"` // 是否保存上游内容，上游不可用时使用
+
+	Cache    string        `mapstructure:"cache"`     // 缓存后端：memory / redis
+	RedisURL string        `mapstructure:"redis-url"` // redis 后端地址，如 redis://127.0.0.1:6379/0
+	CacheTTL time.Duration `mapstructure:"cache-ttl"` // 转换结果缓存时间，0 表示不缓存
+
+	RefreshInterval time.Duration `mapstructure:"refresh-interval"` // 定时刷新命名订阅并预热缓存的间隔，0 表示不刷新
+
+	OTLPEndpoint     string  `mapstructure:"otlp-endpoint"`      // OTLP/HTTP trace 接收地址，如 http://127.0.0.1:4318，为空时不开启追踪
+	TraceSampleRatio float64 `mapstructure:"trace-sample-ratio"` // trace 采样比例，(0, 1]，默认 1
+
+	maxSubscriptionSize int64 // 由 MaxSubscriptionSize 解析出的字节数
 }
 
+// envPrefix 为环境变量前缀，配置键中的 - 替换为 _，如 CCT_ADMIN_TOKEN、CCT_PROBE_TIMEOUT
+const envPrefix = "CCT"
+
 var (
 	Global *Config
 )
 
-func Init() (*Config, error) {
-	viper.SetConfigName("config")
+// Init 读取配置。configFile 为空时依次查找 ./config.yaml 与 ./configs/config.yaml，找不到时仅使用默认值与环境变量；
+// 指定了 configFile 时该文件必须存在
+func Init(configFile string) (*Config, error) {
 	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./configs")
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("./configs")
+	}
+
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	viper.AutomaticEnv()
+	bindEnvs()
 
+	viper.SetDefault("listen", ":8088")
+	viper.SetDefault("log-level", "info")
+	viper.SetDefault("gin-mode", gin.ReleaseMode)
+	viper.SetDefault("data-dir", "data")
+	viper.SetDefault("storage", "json")
+	viper.SetDefault("probe-timeout", defaultProbeTimeout)
+	viper.SetDefault("probe-workers", defaultProbeWorkers)
+	viper.SetDefault("strip-info", true)
+	viper.SetDefault("template-cache-ttl", defaultTemplateCacheTTL)
+	viper.SetDefault("user-agent", defaultUserAgent)
+	viper.SetDefault("fetch-retries", defaultFetchRetries)
+	viper.SetDefault("fetch-backoff", defaultFetchBackoff)
+	viper.SetDefault("fetch-timeout", defaultFetchTimeout)
+	viper.SetDefault("fetch-connect-timeout", defaultFetchConnectTimeout)
+	viper.SetDefault("max-subscription-size", defaultMaxSubscriptionSize)
+	viper.SetDefault("upstream-cache", true)
+	viper.SetDefault("cache", "memory")
+	viper.SetDefault("output-check", true)
+	viper.SetDefault("proxy-provider.interval", defaultProviderInterval)
+	viper.SetDefault("proxy-provider.health-check-url", defaultHealthCheckURL)
+	viper.SetDefault("proxy-provider.health-check-interval", defaultHealthCheckInterval)
+	viper.SetDefault("audit-max-entries", defaultAuditMaxEntries)
+	viper.SetDefault("max-short-links", defaultMaxShortLinks)
+	viper.SetDefault("max-jobs", defaultMaxJobs)
+	viper.SetDefault("url-guard.block-private", true)
+	viper.SetDefault("url-guard.schemes", []string{"http", "https"})
+	viper.SetDefault("url-guard.max-redirects", defaultMaxRedirects)
+
+	found := true
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Printf("Config file not found, using defaults and environment variables")
-		} else {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config file: %v", err)
 		}
+		found = false
 	}
 
 	var config Config
@@ -34,7 +179,85 @@ func Init() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
 	}
+	if err := setLogLevel(config.LogLevel); err != nil {
+		return nil, err
+	}
+	switch config.GinMode {
+	case gin.ReleaseMode, gin.DebugMode, gin.TestMode:
+	default:
+		return nil, fmt.Errorf("invalid gin-mode: %q (want release, debug or test)", config.GinMode)
+	}
+	if !found {
+		infof("Config file not found, using defaults and environment variables")
+	}
+	for i := range config.Subscriptions {
+		if err := config.Subscriptions[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid subscriptions in config: %v", err)
+		}
+	}
+	if err := config.Alerts.validate(); err != nil {
+		return nil, fmt.Errorf("invalid alerts in config: %v", err)
+	}
+	if err := validateRouting(config.InterfaceName, config.RoutingMark, config.NodeRouting); err != nil {
+		return nil, fmt.Errorf("invalid node routing in config: %v", err)
+	}
+	if err := config.ProxyProvider.validate(); err != nil {
+		return nil, fmt.Errorf("invalid proxy-provider in config: %v", err)
+	}
+	sizes := trafficSizes(config.MaxSubscriptionSize)
+	if len(sizes) != 1 || sizes[0] <= 0 {
+		return nil, fmt.Errorf("invalid max-subscription-size: %q (want a size like 32MB)", config.MaxSubscriptionSize)
+	}
+	config.maxSubscriptionSize = sizes[0]
+	if err := normalizeHosts(config.FetchAllowHosts); err != nil {
+		return nil, fmt.Errorf("invalid fetch-allow-hosts in config: %v", err)
+	}
+	if err := normalizeHosts(config.FetchDenyHosts); err != nil {
+		return nil, fmt.Errorf("invalid fetch-deny-hosts in config: %v", err)
+	}
+	if err := config.URLGuard.validate(); err != nil {
+		return nil, fmt.Errorf("invalid url-guard in config: %v", err)
+	}
+	if err := validateUsers(config.Users, config.Tokens); err != nil {
+		return nil, fmt.Errorf("invalid users in config: %v", err)
+	}
+	if err := validateBasicAuth(config.BasicAuth); err != nil {
+		return nil, fmt.Errorf("invalid basic-auth in config: %v", err)
+	}
+	if config.AuditMaxEntries < 0 {
+		return nil, fmt.Errorf("invalid audit-max-entries: %d", config.AuditMaxEntries)
+	}
+	if config.MaxShortLinks < 0 {
+		return nil, fmt.Errorf("invalid max-short-links: %d", config.MaxShortLinks)
+	}
+	if config.MaxJobs < 0 {
+		return nil, fmt.Errorf("invalid max-jobs: %d", config.MaxJobs)
+	}
+	if err := config.TLS.validate(); err != nil {
+		return nil, fmt.Errorf("invalid tls in config: %v", err)
+	}
 	Global = &config
 
 	return Global, nil
 }
+
+// bindEnvs 为 Config 的每个键绑定环境变量。AutomaticEnv 只对 viper 已知的键生效，
+// 未出现在配置文件中的键需显式绑定，Unmarshal 才能读到。嵌套的设置（如 proxy-provider）绑定其中的每个字段，
+// 如 CCT_TLS_CERT；绑定整个嵌套键会使配置文件只写了部分字段时其余字段的默认值丢失
+func bindEnvs() {
+	bindStructEnvs(reflect.TypeOf(Config{}), "")
+}
+
+func bindStructEnvs(t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			bindStructEnvs(t.Field(i).Type, prefix+key+".")
+			continue
+		}
+		viper.BindEnv(prefix + key)
+	}
+}
@@ -0,0 +1,74 @@
+// Package converter 提供将订阅内容一次性转换为 Clash 配置的入口，供其他 Go 程序嵌入使用：
+//
+//	out, err := converter.Convert(body, converter.Options{Template: tmpl})
+//
+// 需要更细的控制时可直接组合 parser、region、template 与 clash 包
+package converter
+
+import (
+	"fmt"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/generator"
+	"pkg/main.go/src/pkg/parser"
+	"pkg/main.go/src/pkg/region"
+	"pkg/main.go/src/pkg/template"
+)
+
+// Options 为 Convert 的转换选项
+type Options struct {
+	// Template 为输出模板（text/template 语法的 Clash YAML），为空时使用内置的最小配置。
+	// 模板中的 !include 不会展开
+	Template []byte
+	Vars     map[string]string // ${var:NAME} 的取值，键为小写名称
+	Target   string            // 输出格式，见 generator.Targets，为空时为 clash
+
+	// Logf 记录解析与渲染中被跳过的内容，为空时丢弃
+	Logf func(format string, args ...interface{})
+}
+
+// Convert 解析订阅内容（Base64 编码、每行一个节点链接）并按 opts.Target 生成配置
+func Convert(input []byte, opts Options) ([]byte, error) {
+	gen, ok := generator.Lookup(opts.Target)
+	if !ok {
+		return nil, fmt.Errorf("unsupported target: %q", opts.Target)
+	}
+	proxies, skipped, err := parser.Parse(input)
+	if opts.Logf != nil {
+		for _, s := range skipped {
+			opts.Logf("skipped link on line %d %s: %s", s.Line, s.Name, s.Reason)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	region.Tag(proxies)
+	proxies, warnings := generator.Adapt(gen, proxies)
+	if opts.Logf != nil {
+		for _, w := range warnings {
+			opts.Logf("target %s: %s", gen.Target(), w)
+		}
+	}
+	if len(proxies) == 0 {
+		return nil, parser.ErrNoNodes
+	}
+	clash.SanitizeNames(proxies)
+	clash.DedupeNames(proxies)
+
+	names := make([]string, 0, len(proxies))
+	for _, p := range proxies {
+		names = append(names, p.Name)
+	}
+
+	cfg := clash.DefaultConfig(proxies, names)
+	if len(opts.Template) > 0 {
+		t, err := template.Parse("template", opts.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parse template: %v", err)
+		}
+		if cfg, err = t.Render(proxies, names, template.Options{Vars: opts.Vars, Logf: opts.Logf}); err != nil {
+			return nil, fmt.Errorf("render template: %v", err)
+		}
+	}
+	return gen.Generate(proxies, cfg)
+}
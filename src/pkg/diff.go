@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+const historyKind = "history"
+
+// proxySnapshot 是某次转换解析出的节点列表
+type proxySnapshot struct {
+	Proxies []clash.Proxy `json:"proxies"`
+	At      time.Time     `json:"at"`
+}
+
+// proxyHistory 保存订阅最近两次不同的节点列表
+type proxyHistory struct {
+	URL      string         `json:"url"`
+	Previous *proxySnapshot `json:"previous,omitempty"`
+	Current  *proxySnapshot `json:"current,omitempty"`
+}
+
+var (
+	historyMu sync.Mutex
+	histories = make(map[string]*proxyHistory)
+)
+
+// ProxyDiff 描述两次转换之间的节点变化
+type ProxyDiff struct {
+	Subscription string       `json:"subscription"`
+	PreviousAt   *time.Time   `json:"previous_at,omitempty"`
+	CurrentAt    *time.Time   `json:"current_at,omitempty"`
+	Added        []string     `json:"added"`
+	Removed      []string     `json:"removed"`
+	Changed      []ProxyDelta `json:"changed"`
+}
+
+// ProxyDelta 描述同名节点的字段变化
+type ProxyDelta struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// recordSnapshot 记录订阅最新的节点列表；仅在节点发生变化时轮换上一版本
+func recordSnapshot(subURL string, proxies []clash.Proxy) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	h, ok := histories[subURL]
+	if !ok {
+		h = &proxyHistory{URL: subURL}
+		histories[subURL] = h
+	}
+	snap := &proxySnapshot{Proxies: proxies, At: time.Now()}
+	if h.Current != nil && sameProxies(h.Current.Proxies, proxies) {
+		return
+	}
+	h.Previous, h.Current = h.Current, snap
+	saveHistoryLocked()
+}
+
+// sameProxies 比较两组节点是否一致。逐个按 JSON 编码比较，从存储恢复的历史（数字类型可能变化）也能正确判断，
+// 且不必一次编码整个节点列表
+func sameProxies(a, b []clash.Proxy) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		ja, errA := json.Marshal(a[i])
+		jb, errB := json.Marshal(b[i])
+		if errA != nil || errB != nil {
+			if !reflect.DeepEqual(a[i], b[i]) {
+				return false
+			}
+			continue
+		}
+		if !bytes.Equal(ja, jb) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadHistory 从存储后端恢复转换历史
+func loadHistory(backend Backend) error {
+	var list []*proxyHistory
+	if err := backend.Load(historyKind, &list); err != nil {
+		return err
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, h := range list {
+		histories[h.URL] = h
+	}
+	return nil
+}
+
+// saveHistoryLocked 将转换历史写回存储后端，调用方需持有 historyMu
+func saveHistoryLocked() {
+	if store == nil {
+		return
+	}
+	list := make([]*proxyHistory, 0, len(histories))
+	for _, h := range histories {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+	if err := store.Save(historyKind, list); err != nil {
+		errorf("Error saving history: %v", err)
+	}
+}
+
+// diffProxies 比较两组节点：先按名称与 server:port 对应，余下的同名节点按出现顺序对应，
+// 重名节点不会被合并为一个，服务器变化的节点仍显示为 changed
+func diffProxies(old, cur []clash.Proxy) (added, removed []string, changed []ProxyDelta) {
+	matched := make([]bool, len(old))
+	pair := make([]int, len(cur)) // cur[i] 对应的 old 下标，-1 表示新增
+	for i := range pair {
+		pair[i] = -1
+	}
+	match := func(key func(clash.Proxy) string) {
+		unmatched := make(map[string][]int)
+		for i, p := range old {
+			if !matched[i] {
+				unmatched[key(p)] = append(unmatched[key(p)], i)
+			}
+		}
+		for i, p := range cur {
+			if pair[i] >= 0 {
+				continue
+			}
+			if idx := unmatched[key(p)]; len(idx) > 0 {
+				pair[i], matched[idx[0]] = idx[0], true
+				unmatched[key(p)] = idx[1:]
+			}
+		}
+	}
+	match(func(p clash.Proxy) string { return p.Name + "\x00" + nodeAddress(p) })
+	match(func(p clash.Proxy) string { return p.Name })
+
+	label := diffLabel(old, cur)
+	added, removed, changed = []string{}, []string{}, []ProxyDelta{}
+	for i, p := range cur {
+		if pair[i] < 0 {
+			added = append(added, label(p))
+			continue
+		}
+		if fields := changedFields(old[pair[i]], p); len(fields) > 0 {
+			changed = append(changed, ProxyDelta{Name: label(p), Fields: fields})
+		}
+	}
+	for i, p := range old {
+		if !matched[i] {
+			removed = append(removed, label(p))
+		}
+	}
+	return added, removed, changed
+}
+
+// diffLabel 返回结果中节点的显示名称，同一列表中重名的节点附带 server:port 以便区分
+func diffLabel(old, cur []clash.Proxy) func(clash.Proxy) string {
+	dup := make(map[string]bool)
+	for _, list := range [][]clash.Proxy{old, cur} {
+		seen := make(map[string]bool, len(list))
+		for _, p := range list {
+			if seen[p.Name] {
+				dup[p.Name] = true
+			}
+			seen[p.Name] = true
+		}
+	}
+	return func(p clash.Proxy) string {
+		if dup[p.Name] {
+			return p.Name + " (" + nodeAddress(p) + ")"
+		}
+		return p.Name
+	}
+}
+
+// nodeAddress 返回节点的 server:port
+func nodeAddress(p clash.Proxy) string {
+	return net.JoinHostPort(p.Server, strconv.Itoa(p.Port))
+}
+
+// changedFields 返回两个节点输出到 YAML 后取值不同的字段名
+func changedFields(a, b clash.Proxy) []string {
+	ma, mb := proxyFields(a), proxyFields(b)
+	var fields []string
+	for k, va := range ma {
+		if vb, ok := mb[k]; !ok || !reflect.DeepEqual(va, vb) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range mb {
+		if _, ok := ma[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func proxyFields(p clash.Proxy) map[string]interface{} {
+	m := make(map[string]interface{})
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return m
+	}
+	yaml.Unmarshal(data, &m)
+	return m
+}
+
+// configDiff 重新获取订阅，并返回与上一版本相比新增、移除和变化的节点
+func configDiff(c *gin.Context) {
+	subURL, params, err := resolveSubscription(c.Request.URL.Query())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if _, err := fetchProxies(c.Request.Context(), subURL, ConvertOptions{Fetch: subscriptionFetch(params)}); err != nil {
+		c.Error(err)
+		return
+	}
+
+	historyMu.Lock()
+	var prev, cur *proxySnapshot
+	if h := histories[subURL]; h != nil {
+		prev, cur = h.Previous, h.Current
+	}
+	historyMu.Unlock()
+
+	diff := ProxyDiff{Subscription: subscriptionLabel(subURL)}
+	var oldProxies, curProxies []clash.Proxy
+	if cur != nil {
+		curProxies = cur.Proxies
+		diff.CurrentAt = &cur.At
+	}
+	if prev != nil {
+		oldProxies = prev.Proxies
+		diff.PreviousAt = &prev.At
+	} else {
+		// 没有上一版本时不视为全部新增
+		oldProxies = curProxies
+	}
+	diff.Added, diff.Removed, diff.Changed = diffProxies(oldProxies, curProxies)
+	c.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const dohMaxResponse = 64 << 10
+
+// dnsResolver 用于解析订阅域名与节点域名，在 main 中按 dns-server 初始化，默认为系统 DNS
+var dnsResolver = net.DefaultResolver
+
+// newResolver 按地址创建加密 DNS 解析器：https://1.1.1.1/dns-query（DoH）或 tls://1.1.1.1:853（DoT）。
+// DoH 服务器使用域名时，该域名本身仍由系统 DNS 解析，建议直接填写 IP
+func newResolver(server string) (*net.Resolver, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid dns-server: %q", server)
+	}
+
+	var dial func(ctx context.Context, network, address string) (net.Conn, error)
+	switch u.Scheme {
+	case "https":
+		client := &http.Client{Timeout: resolveTimeout}
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, url: server}, nil
+		}
+	case "tls":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "853")
+		}
+		d := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: resolveTimeout},
+			Config:    &tls.Config{ServerName: u.Hostname()},
+		}
+		// 返回流式连接，Go 解析器会按 TCP 格式（长度前缀）收发报文
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	default:
+		return nil, fmt.Errorf("invalid dns-server: unsupported scheme %q", u.Scheme)
+	}
+	return &net.Resolver{PreferGo: true, Dial: dial}, nil
+}
+
+// dohConn 将 Go 解析器的一次 UDP 查询转换为 RFC 8484 的 HTTP POST 请求。
+// 实现 net.PacketConn，解析器据此按 UDP 格式收发，不加长度前缀
+type dohConn struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	resp   *bytes.Reader
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("doh server returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, dohMaxResponse))
+	if err != nil {
+		return 0, err
+	}
+	c.resp = bytes.NewReader(data)
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.resp == nil {
+		return 0, io.EOF
+	}
+	return c.resp.Read(b)
+}
+
+func (c *dohConn) WriteTo(b []byte, _ net.Addr) (int, error) { return c.Write(b) }
+
+func (c *dohConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *dohConn) Close() error                     { return nil }
+func (c *dohConn) LocalAddr() net.Addr              { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr             { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(time.Time) error { return nil }
+
+type dohAddr string
+
+func (a dohAddr) Network() string { return "doh" }
+func (a dohAddr) String() string  { return string(a) }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/generator"
+)
+
+// defaultGeositeURL 为展开 GEOSITE 规则使用的域名列表地址，%s 为 geosite 名称
+const defaultGeositeURL = "https://raw.githubusercontent.com/MetaCubeX/meta-rules-dat/meta/geo/geosite/%s.list"
+
+// downgradeRules 为只支持部分规则的目标改写规则：GEOSITE 与目标无法加载的 RULE-SET 下载后展开为经典规则，
+// 下载失败的以及其他不支持的规则记录警告后移除
+func downgradeRules(clashConfig *clash.Config, rs generator.RuleSupport) {
+	// 需要展开的规则集，键为 GEOSITE,<name> 或 RULE-SET,<name>
+	var keys []string
+	sources := make(map[string]clash.RulesProvider)
+	for _, rule := range clashConfig.Rules {
+		parts := strings.Split(rule, ",")
+		if len(parts) < 3 {
+			continue
+		}
+		typ := strings.ToUpper(strings.TrimSpace(parts[0]))
+		name := strings.TrimSpace(parts[1])
+		key := typ + "," + name
+		if _, ok := sources[key]; ok {
+			continue
+		}
+		switch typ {
+		case "GEOSITE":
+			if rs.SupportsRule(typ) {
+				continue
+			}
+			sources[key] = clash.RulesProvider{
+				Type:     "http",
+				Behavior: "domain",
+				URL:      fmt.Sprintf(geositeURL(), url.PathEscape(strings.ToLower(name))),
+				Format:   "text",
+			}
+		case "RULE-SET":
+			p, ok := clashConfig.RulesProviders[name]
+			if !ok || (rs.SupportsRule(typ) && rs.SupportsProvider(p)) {
+				continue
+			}
+			sources[key] = p
+		default:
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	entries := make([][]string, len(keys))
+	errs := make([]error, len(keys))
+	parallel(context.Background(), len(keys), func(i int) {
+		entries[i], errs[i] = fetchRuleProvider(sources[keys[i]])
+	})
+	expanded := make(map[string][]string, len(keys))
+	for i, key := range keys {
+		if errs[i] != nil {
+			warnf("Dropped %s rules: %v", key, errs[i])
+			continue
+		}
+		expanded[key] = entries[i]
+	}
+
+	rules := make([]string, 0, len(clashConfig.Rules))
+	dropped := make(map[string]int)
+	for _, rule := range clashConfig.Rules {
+		parts := strings.Split(rule, ",")
+		typ := strings.ToUpper(strings.TrimSpace(parts[0]))
+		if len(parts) >= 3 {
+			key := typ + "," + strings.TrimSpace(parts[1])
+			if p, ok := sources[key]; ok {
+				list, ok := expanded[key]
+				if !ok {
+					continue
+				}
+				noResolve := len(parts) > 3 && parts[3] == "no-resolve"
+				for _, entry := range list {
+					r, ok := inlineProviderEntry(p.Behavior, entry, strings.TrimSpace(parts[2]), noResolve)
+					if !ok {
+						continue
+					}
+					// classical 规则集中的条目同样受目标支持的类型限制
+					if t, _, _ := strings.Cut(r, ","); rs.SupportsRule(strings.ToUpper(t)) {
+						rules = append(rules, r)
+					} else {
+						dropped[strings.ToUpper(t)]++
+					}
+				}
+				continue
+			}
+		}
+		if !rs.SupportsRule(typ) {
+			dropped[typ]++
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	types := make([]string, 0, len(dropped))
+	for typ := range dropped {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		warnf("Dropped %d %s rules unsupported by the target", dropped[typ], typ)
+	}
+	for key, p := range sources {
+		if name, ok := strings.CutPrefix(key, "RULE-SET,"); ok && !rs.SupportsProvider(p) {
+			delete(clashConfig.RulesProviders, name)
+		}
+	}
+	clashConfig.Rules = rules
+}
+
+// geositeURL 返回 geosite-url 配置，未设置时使用 defaultGeositeURL
+func geositeURL() string {
+	if Global.GeositeURL != "" {
+		return Global.GeositeURL
+	}
+	return defaultGeositeURL
+}
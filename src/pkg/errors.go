@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 转换流程中的错误类型，由 errorHandler 映射为 HTTP 状态码
+var (
+	ErrBadRequest = errors.New("bad request")                   // 请求参数不合法
+	ErrUpstream   = errors.New("upstream failure")              // 上游订阅不可达或返回错误状态
+	ErrParse      = errors.New("parse failure")                 // 上游内容无法解析为节点
+	ErrNoNodes    = errors.New("no nodes left after filtering") // 过滤后没有剩余节点
+	ErrTemplate   = errors.New("template failure")              // 配置生成或序列化失败
+
+	ErrInvalidConfig = errors.New("generated config failed validation") // 生成的配置无法被客户端加载（见 output-check）
+	ErrBlockedURL    = errors.New("url not allowed")                    // 使用者提供的地址未通过 url-guard 检查
+	ErrLimitReached  = errors.New("limit reached")                      // 短链接或异步任务数量已达上限
+)
+
+// InvalidConfigError 为生成的配置未通过检查时的错误，Problems 随错误响应一并返回
+type InvalidConfigError struct {
+	Problems []string
+}
+
+func (e *InvalidConfigError) Error() string {
+	msg := fmt.Sprintf("%v: %s", ErrInvalidConfig, e.Problems[0])
+	if len(e.Problems) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Problems)-1)
+	}
+	return msg
+}
+
+func (e *InvalidConfigError) Unwrap() error { return ErrInvalidConfig }
+
+// badRequest 将参数校验错误标记为 ErrBadRequest。已带类型的错误原样返回，
+// 如拉取 ?config= / ?base= 时的上游错误（502）或未通过 url-guard 检查的地址（403）
+func badRequest(err error) error {
+	if _, kind := errorStatus(err); kind != "internal" {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrBadRequest, err)
+}
+
+// errorStatus 返回错误对应的 HTTP 状态码与类型名
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound), errors.Is(err, ErrShortLinkNotFound), errors.Is(err, ErrJobNotFound),
+		errors.Is(err, ErrTokenNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, ErrSubscriptionExists), errors.Is(err, ErrTokenExists):
+		return http.StatusConflict, "conflict"
+	case errors.Is(err, ErrInvalidSubscription), errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest, "bad_request"
+	case errors.Is(err, ErrInvalidToken):
+		return http.StatusUnauthorized, "unauthorized"
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrBlockedURL):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, ErrUpstream):
+		return http.StatusBadGateway, "upstream"
+	case errors.Is(err, ErrInvalidConfig):
+		return http.StatusBadGateway, "invalid_config"
+	case errors.Is(err, ErrParse):
+		return http.StatusUnprocessableEntity, "parse"
+	case errors.Is(err, ErrNoNodes):
+		return http.StatusUnprocessableEntity, "no_nodes"
+	case errors.Is(err, ErrLimitReached):
+		return http.StatusTooManyRequests, "limit"
+	case errors.Is(err, ErrTemplate):
+		return http.StatusInternalServerError, "template"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+// errorHandler 统一输出处理函数通过 c.Error 记录的错误：{"error": "...", "type": "upstream"}。
+// 处理函数记录错误后直接返回，不再写入响应
+func errorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		status, kind := errorStatus(err)
+		body := gin.H{"error": err.Error(), "type": kind}
+		var invalid *InvalidConfigError
+		if errors.As(err, &invalid) {
+			body["problems"] = invalid.Problems
+		}
+		c.JSON(status, body)
+	}
+}
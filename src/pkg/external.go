@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+const externalRulesetInterval = 86400
+
+// ExternalConfig 是 ACL4SSR / subconverter 格式的外部配置（.ini），
+// 只使用其中的 custom_proxy_group 与 ruleset，用于替换模板中的分组与规则
+type ExternalConfig struct {
+	Groups   []externalGroup
+	Rulesets []externalRuleset
+}
+
+// externalGroup 对应一行 custom_proxy_group=名称`类型`成员...[`测速地址`间隔,超时,容差]
+type externalGroup struct {
+	Name      string
+	Type      string
+	Members   []string // [] 开头为固定名称，其余为匹配节点名称的正则
+	URL       string
+	Interval  int
+	Tolerance int
+}
+
+// externalRuleset 对应一行 ruleset=分组,规则列表地址[,更新间隔] 或 ruleset=分组,[]内联规则
+type externalRuleset struct {
+	Group    string
+	URL      string
+	Behavior string
+	Format   string
+	Interval int
+	Inline   string
+}
+
+// loadExternalConfig 拉取并解析外部配置，复用远程模板缓存
+func loadExternalConfig(src string) (*ExternalConfig, error) {
+	if err := validateSubscriptionURL(src); err != nil {
+		return nil, fmt.Errorf("invalid config: %q", src)
+	}
+	data, err := remoteTemplates.GetUserURL(src)
+	if err != nil {
+		return nil, err
+	}
+	return parseExternalConfig(data)
+}
+
+// parseExternalConfig 解析 .ini 内容
+func parseExternalConfig(data []byte) (*ExternalConfig, error) {
+	ext := &ExternalConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "custom_proxy_group":
+			g, err := parseExternalGroup(value)
+			if err != nil {
+				return nil, err
+			}
+			ext.Groups = append(ext.Groups, g)
+		case "ruleset":
+			rs, err := parseExternalRuleset(value)
+			if err != nil {
+				return nil, err
+			}
+			ext.Rulesets = append(ext.Rulesets, rs)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ext.Groups) == 0 {
+		return nil, fmt.Errorf("invalid config: no custom_proxy_group found")
+	}
+	return ext, nil
+}
+
+func parseExternalGroup(value string) (externalGroup, error) {
+	fields := strings.Split(value, "`")
+	if len(fields) < 2 || fields[0] == "" {
+		return externalGroup{}, fmt.Errorf("invalid custom_proxy_group: %q", value)
+	}
+	g := externalGroup{Name: fields[0], Type: fields[1]}
+	members := fields[2:]
+
+	switch g.Type {
+	case "select", "relay":
+	case "url-test", "fallback", "load-balance":
+		// 末尾两项为测速地址与 "间隔,超时,容差"
+		if len(members) >= 2 && strings.HasPrefix(members[len(members)-2], "http") {
+			g.URL = members[len(members)-2]
+			timing := strings.Split(members[len(members)-1], ",")
+			g.Interval, _ = strconv.Atoi(timing[0])
+			if len(timing) >= 3 {
+				g.Tolerance, _ = strconv.Atoi(timing[2])
+			}
+			members = members[:len(members)-2]
+		}
+	default:
+		return externalGroup{}, fmt.Errorf("invalid custom_proxy_group: unsupported type %q", g.Type)
+	}
+
+	for _, m := range members {
+		if m == "" {
+			continue
+		}
+		if !strings.HasPrefix(m, "[]") {
+			if _, err := regexp.Compile(m); err != nil {
+				return externalGroup{}, fmt.Errorf("invalid custom_proxy_group %q: %v", g.Name, err)
+			}
+		}
+		g.Members = append(g.Members, m)
+	}
+	return g, nil
+}
+
+func parseExternalRuleset(value string) (externalRuleset, error) {
+	group, target, ok := strings.Cut(value, ",")
+	if !ok || group == "" || target == "" {
+		return externalRuleset{}, fmt.Errorf("invalid ruleset: %q", value)
+	}
+	rs := externalRuleset{Group: group}
+	if strings.HasPrefix(target, "[]") {
+		rs.Inline = strings.TrimPrefix(target, "[]")
+		return rs, nil
+	}
+
+	// 带 clash-domain: 等前缀的地址为 Clash 格式的 rule-provider，否则视为每行一条规则的 .list
+	rs.Behavior, rs.Format = "classical", "text"
+	for prefix, behavior := range map[string]string{"clash-domain:": "domain", "clash-ipcidr:": "ipcidr", "clash-classic:": "classical"} {
+		if strings.HasPrefix(target, prefix) {
+			target = strings.TrimPrefix(target, prefix)
+			rs.Behavior, rs.Format = behavior, ""
+			break
+		}
+	}
+
+	rs.Interval = externalRulesetInterval
+	if u, interval, ok := strings.Cut(target, ","); ok {
+		target = u
+		if n, err := strconv.Atoi(interval); err == nil && n > 0 {
+			rs.Interval = n
+		}
+	}
+	if err := validateSubscriptionURL(target); err != nil {
+		return externalRuleset{}, fmt.Errorf("invalid ruleset: %q", value)
+	}
+	rs.URL = target
+	return rs, nil
+}
+
+// proxyGroups 按外部配置生成代理分组
+func (ext *ExternalConfig) proxyGroups(proxyNames []string) []clash.ProxyGroup {
+	groups := make([]clash.ProxyGroup, 0, len(ext.Groups))
+	for _, g := range ext.Groups {
+		group := clash.ProxyGroup{
+			Name:      g.Name,
+			Type:      g.Type,
+			URL:       g.URL,
+			Interval:  g.Interval,
+			Tolerance: g.Tolerance,
+		}
+		seen := make(map[string]bool)
+		add := func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				group.Proxies = append(group.Proxies, name)
+			}
+		}
+		for _, m := range g.Members {
+			if strings.HasPrefix(m, "[]") {
+				add(strings.TrimPrefix(m, "[]"))
+				continue
+			}
+			re := regexp.MustCompile(m)
+			for _, name := range proxyNames {
+				if re.MatchString(name) {
+					add(name)
+				}
+			}
+		}
+		if len(group.Proxies) == 0 {
+			// Clash 不接受空分组
+			group.Proxies = []string{"DIRECT"}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// rules 按外部配置生成 rule-providers 与规则
+func (ext *ExternalConfig) rules() (map[string]clash.RulesProvider, []string) {
+	providers := make(map[string]clash.RulesProvider)
+	var rules []string
+	for _, rs := range ext.Rulesets {
+		if rs.Inline != "" {
+			rules = append(rules, inlineRule(rs.Inline, rs.Group))
+			continue
+		}
+
+		// 以文件名作为 provider 名称，重名时追加序号
+		base := strings.TrimSuffix(path.Base(rs.URL), path.Ext(rs.URL))
+		name := base
+		for i := 2; providers[name].URL != ""; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		ext := ".yaml"
+		if rs.Format == "text" {
+			ext = ".list"
+		}
+		providers[name] = clash.RulesProvider{
+			Type:     "http",
+			Behavior: rs.Behavior,
+			Format:   rs.Format,
+			URL:      rs.URL,
+			Path:     "./ruleset/" + name + ext,
+			Interval: rs.Interval,
+		}
+		rules = append(rules, "RULE-SET,"+name+","+rs.Group)
+	}
+	return providers, rules
+}
+
+// inlineRule 将 "GEOIP,CN" / "FINAL" 形式的内联规则补上目标分组，no-resolve 保持在末尾
+func inlineRule(rule, group string) string {
+	parts := strings.Split(rule, ",")
+	if t := strings.ToUpper(parts[0]); t == "FINAL" || t == "MATCH" {
+		return "MATCH," + group
+	}
+	if last := len(parts) - 1; last >= 2 && parts[last] == "no-resolve" {
+		return strings.Join(parts[:last], ",") + "," + group + ",no-resolve"
+	}
+	return rule + "," + group
+}
+
+// applyExternalConfig 用外部配置替换模板中的分组与规则
+func applyExternalConfig(clashConfig *clash.Config, ext *ExternalConfig, proxyNames []string) {
+	clashConfig.ProxyGroups = ext.proxyGroups(proxyNames)
+	clashConfig.RulesProviders, clashConfig.Rules = ext.rules()
+	if len(clashConfig.Rules) == 0 {
+		warnf("External config has no ruleset, falling back to MATCH,%s", ext.Groups[0].Name)
+		clashConfig.Rules = []string{"MATCH," + ext.Groups[0].Name}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/parser"
+)
+
+// maxExtractBody 为 /extract 请求体的大小上限
+const maxExtractBody = 16 << 20
+
+// ExtractedLink 为 /extract 中单个节点的结果
+type ExtractedLink struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Link  string `json:"link,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// extractLinks 接收 Clash 配置（请求体），将其中的节点转换为分享链接，适用于只提供 Clash 订阅的机场。
+// ?format=text（默认，每行一个链接）/ base64（与普通订阅相同）/ json（含无法转换的节点及原因）
+func extractLinks(c *gin.Context) {
+	format := c.DefaultQuery("format", "text")
+	if format != "text" && format != "base64" && format != "json" {
+		c.Error(badRequest(fmt.Errorf("invalid format: %q (want text, base64 or json)", format)))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxExtractBody))
+	if err != nil {
+		c.Error(badRequest(fmt.Errorf("failed to read request body: %v", err)))
+		return
+	}
+	results, err := extractConfig(body)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+	links := make([]string, 0, len(results))
+	for _, r := range results {
+		if r.Error != "" {
+			warnf("Skipped node %s: %s", r.Name, r.Error)
+			continue
+		}
+		links = append(links, r.Link)
+	}
+	if len(links) == 0 {
+		c.Error(fmt.Errorf("%w: no node could be converted to a share link", ErrParse))
+		return
+	}
+	c.Header("X-Skipped-Nodes", strconv.Itoa(len(results)-len(links)))
+	out := strings.Join(links, "\n") + "\n"
+	if format == "base64" {
+		out = base64.StdEncoding.EncodeToString([]byte(out))
+	}
+	c.String(http.StatusOK, out)
+}
+
+// extractConfig 解析 Clash 配置中的 proxies 并逐个生成分享链接，单个节点无法解析时记录原因，不影响其余节点
+func extractConfig(data []byte) ([]ExtractedLink, error) {
+	var doc struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid clash config: %v", err)
+	}
+	if len(doc.Proxies) == 0 {
+		return nil, errors.New("no proxies found in the config")
+	}
+
+	results := make([]ExtractedLink, 0, len(doc.Proxies))
+	for i := range doc.Proxies {
+		var node parser.Node
+		if err := doc.Proxies[i].Decode(&node); err != nil {
+			// 字段类型不符时整项解码失败，单独取出名称以便定位
+			var named struct {
+				Name string `yaml:"name"`
+				Type string `yaml:"type"`
+			}
+			doc.Proxies[i].Decode(&named)
+			if named.Name == "" {
+				named.Name = fmt.Sprintf("#%d", i+1)
+			}
+			results = append(results, ExtractedLink{Name: named.Name, Type: named.Type, Error: err.Error()})
+			continue
+		}
+		link, err := parser.ShareLink(node)
+		r := ExtractedLink{Name: node.Name, Type: node.Type, Link: link}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultUserAgent 为拉取订阅时默认的 User-Agent；Go 默认的 UA 会被部分机场拒绝
+	defaultUserAgent = "clash-verge/v1.7.7"
+
+	defaultFetchRetries = 2
+	defaultFetchBackoff = 500 * time.Millisecond
+
+	defaultFetchTimeout        = 30 * time.Second
+	defaultFetchConnectTimeout = 10 * time.Second
+
+	// maxPreallocBody 为按 Content-Length 预分配的上限，超出部分在读取时再扩容
+	maxPreallocBody = 64 << 20
+
+	defaultMaxSubscriptionSize = "32MB"
+)
+
+// upstreamClient 用于拉取上游订阅，在 main 中按配置初始化
+var upstreamClient = http.DefaultClient
+
+var (
+	// fetchGroup 合并同一订阅的并发拉取
+	fetchGroup singleflight.Group
+	// fetchWaiters 记录每次合并拉取的等待方，全部离开后取消上游请求
+	fetchWaiters = struct {
+		sync.Mutex
+		m map[string]*sharedFetch
+	}{m: make(map[string]*sharedFetch)}
+	// upstreamLimiter 限制对同一上游主机的请求频率，在 main 中按 fetch-host-interval 初始化
+	upstreamLimiter = newHostLimiter(0)
+)
+
+// newUpstreamClient 按配置创建拉取订阅的 HTTP 客户端，连接与整体超时分别来自 fetch-connect-timeout 与 fetch-timeout。
+// 配置了 fetch-proxy（http/https/socks5/socks5h）时经由该代理，否则沿用 HTTP_PROXY / HTTPS_PROXY 环境变量
+func newUpstreamClient(cfg *Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: cfg.FetchConnectTimeout, KeepAlive: 30 * time.Second, Resolver: dnsResolver}).DialContext
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.FetchProxy != "" {
+		u, err := url.Parse(cfg.FetchProxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid fetch-proxy: %q", cfg.FetchProxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid fetch-proxy: unsupported scheme %q", u.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	// 自定义 DialContext 后需显式开启，https 订阅才会经 ALPN 协商 HTTP/2
+	transport.ForceAttemptHTTP2 = true
+
+	switch cfg.FetchHTTP {
+	case "", "auto":
+	case "http1":
+		transport.ForceAttemptHTTP2 = false
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	case "http3":
+		// QUIC 基于 UDP，无法经由 HTTP/SOCKS 代理转发
+		if cfg.FetchProxy != "" {
+			return nil, fmt.Errorf("fetch-http: http3 cannot be used with fetch-proxy")
+		}
+		h3 := &http3.Transport{
+			TLSClientConfig: &tls.Config{},
+			QUICConfig:      &quic.Config{HandshakeIdleTimeout: cfg.FetchConnectTimeout},
+		}
+		return &http.Client{Transport: &http3Fallback{h3: h3, tcp: transport}, Timeout: cfg.FetchTimeout, CheckRedirect: checkUpstreamRedirect}, nil
+	default:
+		return nil, fmt.Errorf("invalid fetch-http: %q", cfg.FetchHTTP)
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.FetchTimeout, CheckRedirect: checkUpstreamRedirect}, nil
+}
+
+// checkUpstreamRedirect 与默认行为一样最多跟随 10 次重定向，重定向的目标同样按 fetch-allow-hosts / fetch-deny-hosts 检查
+func checkUpstreamRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return checkFetchHost(req.URL)
+}
+
+// http3Fallback 对 https 订阅优先使用 HTTP/3，失败时（如 UDP 被阻断）回退到 HTTP/2 与 HTTP/1.1
+type http3Fallback struct {
+	h3  http.RoundTripper
+	tcp http.RoundTripper
+}
+
+func (t *http3Fallback) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.tcp.RoundTrip(req)
+	}
+	// 上游请求没有请求体，可以安全地重发
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	if req.Context().Err() != nil {
+		return nil, err
+	}
+	warnf("HTTP/3 fetch of %s failed: %v, falling back to TCP", req.URL.Host, err)
+	return t.tcp.RoundTrip(req)
+}
+
+// FetchOptions 为拉取上游订阅时附加的请求设置，部分机场按 UA 或请求头校验访问
+type FetchOptions struct {
+	Method    string            `json:"method,omitempty" mapstructure:"method"`         // 默认 GET
+	Headers   map[string]string `json:"headers,omitempty" mapstructure:"headers"`       // 额外请求头
+	UserAgent string            `json:"user_agent,omitempty" mapstructure:"user-agent"` // 覆盖默认 User-Agent
+	Username  string            `json:"username,omitempty" mapstructure:"username"`     // HTTP Basic 认证
+	Password  string            `json:"password,omitempty" mapstructure:"password"`
+}
+
+// subscriptionFetch 返回 ?sub= 指定的订阅（用户订阅集时为逗号分隔的多个）各来源的拉取设置，
+// 键为来源地址，未使用命名订阅时为空
+func subscriptionFetch(params url.Values) map[string]FetchOptions {
+	raw := params.Get("sub")
+	if raw == "" {
+		return nil
+	}
+	fetch := make(map[string]FetchOptions)
+	for _, name := range strings.Split(raw, userSubSeparator) {
+		sub, err := lookupSubscription(name)
+		if err != nil || sub.Fetch == nil {
+			continue
+		}
+		for _, src := range splitSources(sub.URL) {
+			fetch[src] = *sub.Fetch
+		}
+	}
+	return fetch
+}
+
+// newFetchRequest 按拉取设置构造上游请求
+func newFetchRequest(subURL string, fetch FetchOptions) (*http.Request, error) {
+	method := strings.ToUpper(fetch.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, subURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", Global.UserAgent)
+	for k, v := range fetch.Headers {
+		req.Header.Set(k, v)
+	}
+	if fetch.UserAgent != "" {
+		req.Header.Set("User-Agent", fetch.UserAgent)
+	}
+	if fetch.Username != "" || fetch.Password != "" {
+		req.SetBasicAuth(fetch.Username, fetch.Password)
+	}
+	return req, nil
+}
+
+// fetchKey 返回合并并发拉取使用的键，拉取设置不同（如不同的 UA、认证）时视为不同的请求
+func fetchKey(subURL string, fetch FetchOptions) string {
+	b, _ := json.Marshal(fetch)
+	return cacheKey("fetch", subURL, string(b))
+}
+
+// sharedFetch 为一次合并后的上游拉取
+type sharedFetch struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// joinFetch 登记一个等待 key 对应拉取的调用方，返回该次拉取使用的 context 与调用方离开时调用的 leave。
+// 最后一个等待方离开（如客户端断开）时取消上游请求
+func joinFetch(key string) (context.Context, func()) {
+	fetchWaiters.Lock()
+	defer fetchWaiters.Unlock()
+	f := fetchWaiters.m[key]
+	if f == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		f = &sharedFetch{ctx: ctx, cancel: cancel}
+		fetchWaiters.m[key] = f
+	}
+	f.waiters++
+	return f.ctx, func() {
+		fetchWaiters.Lock()
+		defer fetchWaiters.Unlock()
+		if f.waiters--; f.waiters > 0 {
+			return
+		}
+		f.cancel()
+		if fetchWaiters.m[key] == f {
+			delete(fetchWaiters.m, key)
+		}
+		// 之后的调用方发起新的拉取，而不是等待已取消的这一次
+		fetchGroup.Forget(key)
+	}
+}
+
+// hostLimiter 保证对同一主机相邻两次请求的间隔不小于 interval，超出频率的请求排队等待
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time // 主机下一次允许请求的时间
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait 为 host 预约下一个请求时间并等待到该时间，ctx 先结束时返回其错误
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.interval <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	if len(l.next) > 1024 {
+		for h, t := range l.next {
+			if t.Before(now) {
+				delete(l.next, h)
+			}
+		}
+	}
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		debugf("Rate limiting fetch from %s, waiting %s", host, d)
+		return sleepContext(ctx, d)
+	}
+	return nil
+}
+
+// sleepContext 等待 d，ctx 先结束时返回其错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readSubscriptionBody 读取订阅响应，超过 max-subscription-size 时停止读取并返回 ErrUpstream，
+// 避免上游（包括使用者提供的 ?url=）以无限长的响应耗尽内存。retryable 表示读取中途失败，可以重试
+func readSubscriptionBody(resp *http.Response) (body []byte, retryable bool, err error) {
+	limit := Global.maxSubscriptionSize
+	if resp.ContentLength > limit {
+		return nil, false, fmt.Errorf("%w: subscription response of %d bytes exceeds max-subscription-size %s",
+			ErrUpstream, resp.ContentLength, Global.MaxSubscriptionSize)
+	}
+	// 按 Content-Length 预分配，避免大订阅在读取时多次扩容
+	var buf bytes.Buffer
+	if resp.ContentLength > 0 {
+		buf.Grow(int(min(resp.ContentLength, maxPreallocBody)))
+	}
+	n, err := buf.ReadFrom(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read subscription response body: %v", err)
+	}
+	if n > limit {
+		return nil, false, fmt.Errorf("%w: subscription response exceeds max-subscription-size %s", ErrUpstream, Global.MaxSubscriptionSize)
+	}
+	return buf.Bytes(), false, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// infoRemarkPattern 匹配机场注入的信息节点名称：开头（允许 emoji 等符号）为关键字，其后紧跟流量、日期、天数或冒号，
+// 如「剩余流量：98.5 GB」「套餐到期：2025-01-31」「Expire: 2025-01-31」「距离下次重置剩余：12 天」「官网：example.com」，
+// 名称中间出现这些词的正常节点（如「香港 01 流量倍率 1.5」）不受影响
+var infoRemarkPattern = regexp.MustCompile(`(?i)^[^\p{L}\p{N}]*(?:` +
+	`(?:剩余流量|已用流量|总流量|流量|remaining(?: traffic)?|used(?: traffic)?|traffic|bandwidth)\s*[:：]?\s*\d+(?:\.\d+)?\s*[kmgtp]i?b` +
+	`|(?:套餐到期|到期时间|过期时间|到期|有效期|expire[sd]?(?: at| on| date)?|expiry(?: date)?)\s*[:：]?\s*(?:\d{4}[-/.年]\d{1,2}[-/.月]\d{1,2}|长期有效|永久|never)` +
+	`|(?:距离下次重置剩余|距离下次重置|下次重置|流量重置|reset in)\s*[:：]?\s*\d+\s*(?:天|日|days?)` +
+	`|(?:官网|网址|最新地址|地址发布页?|发布页|客服|交流群|群组|频道|website|official site)\s*[:：]` +
+	`)`)
+
+// zeroUUID 为信息节点常用的全零 UUID
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// filterProxies 按转换选项过滤节点，保持原有顺序
+func filterProxies(proxies []clash.Proxy, opts ConvertOptions) []clash.Proxy {
+	filtered := make([]clash.Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if opts.StripInfo && isInfoNode(p) {
+			continue
+		}
+		if opts.Types != nil && !opts.Types[p.Type] {
+			continue
+		}
+		if opts.ExcludeTypes[p.Type] {
+			continue
+		}
+		if opts.Ports != nil && !opts.Ports.Match(p.Port) {
+			continue
+		}
+		if opts.Include != nil && !opts.Include.MatchString(p.Name) {
+			continue
+		}
+		if opts.Exclude != nil && opts.Exclude.MatchString(p.Name) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// filterRegions 仅保留地区代码（见 clash.Proxy.RegionCode）在集合中的节点
+func filterRegions(proxies []clash.Proxy, regions map[string]bool) []clash.Proxy {
+	filtered := make([]clash.Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if regions[p.RegionCode()] {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// isInfoNode 判断节点是否为机场用于展示剩余流量、到期时间、官网地址等信息的伪节点。
+// 只按名称的形式与全零 UUID 判断，不按服务器地址判断，避免误删自建或测试用的节点
+func isInfoNode(p clash.Proxy) bool {
+	return p.UUID == zeroUUID || infoRemarkPattern.MatchString(p.Name)
+}
+
+// portRange 为闭区间端口范围
+type portRange struct{ lo, hi int }
+
+// PortFilter 由 ?ports= 解析，逗号分隔，支持 8000-9000 范围，以 ! 开头的条目表示排除
+type PortFilter struct {
+	allow []portRange
+	deny  []portRange
+}
+
+// parsePortFilter 解析端口过滤表达式，如 "443,8443,!80"
+func parsePortFilter(expr string) (*PortFilter, error) {
+	f := &PortFilter{}
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		negate := strings.HasPrefix(item, "!")
+		item = strings.TrimPrefix(item, "!")
+
+		lo, hi, isRange := strings.Cut(item, "-")
+		if !isRange {
+			hi = lo
+		}
+		r := portRange{}
+		var err1, err2 error
+		r.lo, err1 = strconv.Atoi(lo)
+		r.hi, err2 = strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || r.lo < 1 || r.hi > 65535 || r.lo > r.hi {
+			return nil, fmt.Errorf("invalid ports: %q", item)
+		}
+		if negate {
+			f.deny = append(f.deny, r)
+		} else {
+			f.allow = append(f.allow, r)
+		}
+	}
+	return f, nil
+}
+
+// Match 判断端口是否通过过滤
+func (f *PortFilter) Match(port int) bool {
+	in := func(ranges []portRange) bool {
+		for _, r := range ranges {
+			if port >= r.lo && port <= r.hi {
+				return true
+			}
+		}
+		return false
+	}
+	if in(f.deny) {
+		return false
+	}
+	return len(f.allow) == 0 || in(f.allow)
+}
+
+// CIDRFilter 由 ?server-cidr= 解析，逗号分隔，以 ! 开头的网段表示排除
+type CIDRFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// parseCIDRFilter 解析网段过滤表达式，如 "!104.16.0.0/12,!172.64.0.0/13"
+func parseCIDRFilter(expr string) (*CIDRFilter, error) {
+	f := &CIDRFilter{}
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		negate := strings.HasPrefix(item, "!")
+		_, network, err := net.ParseCIDR(strings.TrimPrefix(item, "!"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid server-cidr: %q", item)
+		}
+		if negate {
+			f.deny = append(f.deny, network)
+		} else {
+			f.allow = append(f.allow, network)
+		}
+	}
+	return f, nil
+}
+
+// Match 判断 IP 是否通过过滤；无法解析（ip 为 nil）的节点仅在未设置允许网段时保留
+func (f *CIDRFilter) Match(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0
+	}
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCIDR 按解析出的服务器 IP 过滤节点
+func filterCIDR(proxies []clash.Proxy, ips map[string]net.IP, f *CIDRFilter) []clash.Proxy {
+	filtered := make([]clash.Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if f.Match(ips[p.Server]) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
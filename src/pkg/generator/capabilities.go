@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Capabilities 描述目标内核支持的节点类型与字段。生成配置前按此表丢弃目标无法加载的节点、
+// 清除不认识的字段并替换不支持的取值，避免输出整份无法加载的配置
+type Capabilities struct {
+	ProxyTypes   map[string]bool   // 支持的节点类型
+	Networks     map[string]bool   // 支持的传输方式（network），"" 为 tcp
+	VmessCiphers map[string]string // 支持的 vmess 加密方式，值为输出时使用的加密方式，不同时为降级
+	Unsupported  []string          // 不支持的节点字段（YAML 键），输出前清除
+}
+
+// Capable 由声明了能力表的生成器实现
+type Capable interface {
+	Capabilities() Capabilities
+}
+
+// metaCapabilities 为 mihomo（Meta 内核）的能力表
+var metaCapabilities = Capabilities{
+	ProxyTypes: map[string]bool{
+		"ss": true, "ssr": true, "vmess": true, "vless": true, "trojan": true, "snell": true, "socks5": true, "http": true,
+		"hysteria": true, "hysteria2": true, "tuic": true, "wireguard": true, "ssh": true, "anytls": true,
+	},
+	Networks: map[string]bool{"": true, "tcp": true, "ws": true, "http": true, "h2": true, "grpc": true},
+	VmessCiphers: map[string]string{
+		"auto": "auto", "none": "none", "zero": "zero", "aes-128-gcm": "aes-128-gcm", "chacha20-poly1305": "chacha20-poly1305",
+	},
+}
+
+// premiumCapabilities 为原版 Clash（Premium 内核）的能力表
+var premiumCapabilities = Capabilities{
+	ProxyTypes: map[string]bool{
+		"ss": true, "ssr": true, "vmess": true, "trojan": true, "snell": true, "socks5": true, "http": true,
+	},
+	Networks: metaCapabilities.Networks,
+	VmessCiphers: map[string]string{
+		"auto": "auto", "none": "none", "zero": "none", "aes-128-gcm": "aes-128-gcm", "chacha20-poly1305": "chacha20-poly1305",
+	},
+	Unsupported: []string{"tfo", "mptcp", "ip-version", "packet-encoding", "global-padding", "authenticated-length"},
+}
+
+// proxyFields 为 clash.Proxy 的 YAML 键到字段序号的映射
+var proxyFields = func() map[string]int {
+	t := reflect.TypeOf(clash.Proxy{})
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); key != "" && key != "-" {
+			fields[key] = i
+		}
+	}
+	return fields
+}()
+
+// Adapt 按生成器的能力表调整节点，返回调整后的节点副本与按项汇总的警告；生成器未声明能力表时原样返回。
+// 丢弃节点后分组引用的名称随之减少，因此须在按节点名称渲染模板之前调用
+func Adapt(g Generator, nodes []clash.Proxy) ([]clash.Proxy, []string) {
+	c, ok := g.(Capable)
+	if !ok {
+		return nodes, nil
+	}
+	caps := c.Capabilities()
+
+	counts := make(map[string]int)
+	adapted := make([]clash.Proxy, 0, len(nodes))
+	for _, p := range nodes {
+		switch {
+		case !caps.ProxyTypes[p.Type]:
+			counts[fmt.Sprintf("dropped %%d %s nodes (unsupported type)", p.Type)]++
+			continue
+		case !caps.Networks[p.Network]:
+			counts[fmt.Sprintf("dropped %%d nodes with network %s", p.Network)]++
+			continue
+		}
+		if p.Type == "vmess" && p.Cipher != "" {
+			cipher, ok := caps.VmessCiphers[p.Cipher]
+			if !ok {
+				counts[fmt.Sprintf("dropped %%d vmess nodes with cipher %s", p.Cipher)]++
+				continue
+			}
+			if cipher != p.Cipher {
+				counts[fmt.Sprintf("downgraded vmess cipher %s to %s on %%d nodes", p.Cipher, cipher)]++
+				p.Cipher = cipher
+			}
+		}
+		v := reflect.ValueOf(&p).Elem()
+		for _, key := range caps.Unsupported {
+			i, ok := proxyFields[key]
+			if !ok || v.Field(i).IsZero() {
+				continue
+			}
+			v.Field(i).SetZero()
+			counts[fmt.Sprintf("removed %s from %%d nodes", key)]++
+		}
+		adapted = append(adapted, p)
+	}
+
+	warnings := make([]string, 0, len(counts))
+	for format, n := range counts {
+		warnings = append(warnings, fmt.Sprintf(format, n))
+	}
+	sort.Strings(warnings)
+	return adapted, warnings
+}
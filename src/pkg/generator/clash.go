@@ -0,0 +1,29 @@
+package generator
+
+import (
+	"bytes"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+func init() {
+	Register(clashGenerator{}, "clashmeta", "clash-meta", "mihomo")
+}
+
+// clashGenerator 输出 Clash（Meta 兼容）YAML 配置
+type clashGenerator struct{}
+
+func (clashGenerator) Target() string { return "clash" }
+
+func (clashGenerator) Capabilities() Capabilities { return metaCapabilities }
+
+func (clashGenerator) Generate(nodes []clash.Proxy, tmpl clash.Config) ([]byte, error) {
+	tmpl.Proxies = nodes
+	var buf bytes.Buffer
+	// 每个节点输出约 200~400 字节
+	buf.Grow(4096 + 320*len(nodes))
+	if err := tmpl.WriteYAML(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,67 @@
+// Package generator 将转换后的节点与渲染后的模板输出为目标客户端的配置格式
+package generator
+
+import (
+	"sort"
+	"sync"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Generator 输出一种目标格式。新增格式时在单独的文件中实现 Generator，并在 init 中调用 Register
+type Generator interface {
+	// Target 返回目标格式名称，即 ?target= 的取值
+	Target() string
+	// Generate 以节点与按输出模板渲染出的配置（分组、规则等）生成目标配置
+	Generate(nodes []clash.Proxy, tmpl clash.Config) ([]byte, error)
+}
+
+// RuleSupport 由只支持部分规则的目标实现。输出前，不支持的 GEOSITE 与 RULE-SET 规则被展开为经典规则，
+// 其余不支持的规则被移除
+type RuleSupport interface {
+	// SupportsRule 判断目标内核是否支持该规则类型（大写，如 GEOSITE）
+	SupportsRule(typ string) bool
+	// SupportsProvider 判断目标内核能否加载该 rule-provider
+	SupportsProvider(p clash.RulesProvider) bool
+}
+
+// DefaultTarget 为未指定 ?target= 时的输出格式
+const DefaultTarget = "clash"
+
+var registry = struct {
+	sync.RWMutex
+	generators map[string]Generator
+}{generators: make(map[string]Generator)}
+
+// Register 注册生成器，aliases 为同一格式的其他名称
+func Register(g Generator, aliases ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.generators[g.Target()] = g
+	for _, a := range aliases {
+		registry.generators[a] = g
+	}
+}
+
+// Lookup 按目标格式名称（或别名）查找生成器，target 为空时返回默认格式
+func Lookup(target string) (Generator, bool) {
+	if target == "" {
+		target = DefaultTarget
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	g, ok := registry.generators[target]
+	return g, ok
+}
+
+// Targets 返回所有已注册的目标格式名称（含别名），按名称排序
+func Targets() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	targets := make([]string, 0, len(registry.generators))
+	for t := range registry.generators {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	return targets
+}
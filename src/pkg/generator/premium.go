@@ -0,0 +1,81 @@
+package generator
+
+import (
+	"regexp"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+func init() {
+	Register(premiumGenerator{}, "clash-legacy")
+}
+
+// premiumRuleTypes 为原版 Clash（Premium 内核）支持的规则类型
+var premiumRuleTypes = map[string]bool{
+	"DOMAIN": true, "DOMAIN-SUFFIX": true, "DOMAIN-KEYWORD": true, "GEOIP": true,
+	"IP-CIDR": true, "IP-CIDR6": true, "SRC-IP-CIDR": true, "SRC-PORT": true, "DST-PORT": true,
+	"PROCESS-NAME": true, "PROCESS-PATH": true, "RULE-SET": true, "MATCH": true,
+}
+
+// premiumGenerator 输出原版 Clash（Premium 内核）可加载的配置，输出格式与 clash 相同，
+// 节点按 premiumCapabilities 调整，规则限于 premiumRuleTypes，rule-provider 只能使用 yaml 格式
+type premiumGenerator struct {
+	clashGenerator
+}
+
+func (premiumGenerator) Target() string { return "clash-premium" }
+
+func (premiumGenerator) Capabilities() Capabilities { return premiumCapabilities }
+
+// premiumGroupExtras 为原版内核不支持的 mihomo 分组字段
+var premiumGroupExtras = []string{"filter", "include-all-proxies", "include-all-providers"}
+
+// Generate 将 mihomo 的分组扩展字段改写为原版内核可加载的形式后按 clash 格式输出
+func (g premiumGenerator) Generate(nodes []clash.Proxy, tmpl clash.Config) ([]byte, error) {
+	groups := make([]clash.ProxyGroup, len(tmpl.ProxyGroups))
+	for i, group := range tmpl.ProxyGroups {
+		groups[i] = premiumGroup(group, nodes)
+	}
+	tmpl.ProxyGroups = groups
+	return g.clashGenerator.Generate(nodes, tmpl)
+}
+
+// premiumGroup 将 include-all 分组展开为按 filter / exclude-filter 筛选后的全部节点，并去掉 icon、hidden 等字段。
+// 正则无法被 Go 编译时（mihomo 支持更多语法）不按其筛选
+func premiumGroup(g clash.ProxyGroup, nodes []clash.Proxy) clash.ProxyGroup {
+	if g.IncludeAll || g.Extra["include-all-proxies"] == true {
+		filter, _ := g.Extra["filter"].(string)
+		include, _ := regexp.Compile(filter)
+		exclude, _ := regexp.Compile(g.ExcludeFilter)
+		proxies := append([]string(nil), g.Proxies...)
+		for _, p := range nodes {
+			if (filter != "" && include != nil && !include.MatchString(p.Name)) ||
+				(g.ExcludeFilter != "" && exclude != nil && exclude.MatchString(p.Name)) {
+				continue
+			}
+			proxies = append(proxies, p.Name)
+		}
+		if len(proxies) == 0 {
+			// Clash 不接受空分组
+			proxies = []string{"DIRECT"}
+		}
+		g.Proxies = proxies
+	}
+	g.Icon, g.Hidden, g.IncludeAll, g.ExcludeFilter = "", false, false, ""
+
+	extra := make(map[string]interface{}, len(g.Extra))
+	for k, v := range g.Extra {
+		extra[k] = v
+	}
+	for _, k := range premiumGroupExtras {
+		delete(extra, k)
+	}
+	g.Extra = extra
+	return g
+}
+
+func (premiumGenerator) SupportsRule(typ string) bool { return premiumRuleTypes[typ] }
+
+func (premiumGenerator) SupportsProvider(p clash.RulesProvider) bool {
+	return p.Format == "" || p.Format == "yaml"
+}
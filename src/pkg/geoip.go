@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// geoDB 为加载的 MaxMind 格式数据库（GeoLite2-Country / Country.mmdb 等），未配置时为 nil
+var geoDB *maxminddb.Reader
+
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// loadGeoDB 打开 GeoIP 数据库
+func loadGeoDB(path string) error {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open geoip db: %v", err)
+	}
+	geoDB = db
+	infof("Loaded geoip db %s (%s)", path, db.Metadata.DatabaseType)
+	return nil
+}
+
+// lookupCountry 返回 IP 所属国家代码，查不到时返回空串
+func lookupCountry(ip net.IP) string {
+	if geoDB == nil {
+		return ""
+	}
+	var rec geoRecord
+	if err := geoDB.Lookup(ip, &rec); err != nil {
+		return ""
+	}
+	if rec.Country.ISOCode != "" {
+		return rec.Country.ISOCode
+	}
+	return rec.RegisteredCountry.ISOCode
+}
+
+// tagCountries 根据解析出的服务器 IP 写入 Country
+func tagCountries(proxies []clash.Proxy, ips map[string]net.IP) {
+	if geoDB == nil {
+		warnf("Warning: geoip requested but no geoip-db is configured")
+		return
+	}
+	for i := range proxies {
+		if ip, ok := ips[proxies[i].Server]; ok {
+			proxies[i].Country = lookupCountry(ip)
+		}
+	}
+}
+
+// filterCountries 仅保留国家代码在列表中的节点
+func filterCountries(proxies []clash.Proxy, countries []string) []clash.Proxy {
+	allowed := make(map[string]bool, len(countries))
+	for _, c := range countries {
+		allowed[strings.ToUpper(strings.TrimSpace(c))] = true
+	}
+	filtered := make([]clash.Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if allowed[p.Country] {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
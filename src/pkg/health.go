@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	readyUpstreamTimeout = 10 * time.Second
+	// readyCacheTTL 内复用上游检查结果，避免探针频繁请求机场
+	readyCacheTTL = 30 * time.Second
+)
+
+// HealthCheck 是 /healthz/ready 中单项检查的结果
+type HealthCheck struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+var readyUpstream = struct {
+	sync.Mutex
+	url     string
+	checked time.Time
+	result  HealthCheck
+}{}
+
+// readinessCheck 检查服务能否实际产出配置：默认模板可以渲染，且默认订阅可达并能解析出节点。
+// ?upstream=false 跳过上游检查；任一检查失败时返回 503
+func readinessCheck(c *gin.Context) {
+	checks := map[string]HealthCheck{"template": checkDefaultTemplate()}
+	if c.Query("upstream") != "false" && Global.Url != "" {
+		checks["upstream"] = checkUpstream(Global.Url)
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, ch := range checks {
+		if !ch.OK {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{
+		"status":    status,
+		"timestamp": time.Now().Unix(),
+		"checks":    checks,
+	})
+}
+
+func checkDefaultTemplate() HealthCheck {
+	if err := checkTemplate(""); err != nil {
+		return HealthCheck{Error: err.Error()}
+	}
+	return HealthCheck{OK: true}
+}
+
+// checkUpstream 拉取一次订阅（不重试）并解析，结果缓存 readyCacheTTL
+func checkUpstream(subURL string) HealthCheck {
+	readyUpstream.Lock()
+	defer readyUpstream.Unlock()
+	if readyUpstream.url == subURL && time.Since(readyUpstream.checked) < readyCacheTTL {
+		return readyUpstream.result
+	}
+
+	result := HealthCheck{Detail: subscriptionLabel(subURL)}
+	if n, err := probeUpstream(subURL); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.OK = true
+		result.Detail = fmt.Sprintf("%s: %d nodes", result.Detail, n)
+	}
+	readyUpstream.url, readyUpstream.checked, readyUpstream.result = subURL, time.Now(), result
+	return result
+}
+
+// probeUpstream 返回订阅中的节点数，多个来源时为各来源之和。错误信息中不包含完整地址，避免泄露订阅 token
+func probeUpstream(subURL string) (int, error) {
+	total := 0
+	for _, src := range splitSources(subURL) {
+		n, err := probeSource(src)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// probeSource 拉取一次单个来源（不重试）并返回其中的节点数
+func probeSource(subURL string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readyUpstreamTimeout)
+	defer cancel()
+
+	req, err := newFetchRequest(subURL, FetchOptions{})
+	if err != nil {
+		return 0, errors.New("invalid subscription url")
+	}
+	if err := checkFetchHost(req.URL); err != nil {
+		return 0, err
+	}
+	resp, err := upstreamClient.Do(req.WithContext(ctx))
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return 0, fmt.Errorf("upstream unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+	body, _, err := readSubscriptionBody(resp)
+	if err != nil {
+		return 0, err
+	}
+	proxies, _, err := decodeAndParse(ctx, body)
+	if err != nil {
+		return 0, err
+	}
+	return len(proxies), nil
+}
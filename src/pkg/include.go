@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// includeTag 标记的标量替换为所引用文件的内容，如 rules: !include snippets/rules.yaml；
+	// 出现在列表中且引用内容也是列表时展开到所在列表
+	includeTag      = "!include"
+	maxIncludeDepth = 8
+)
+
+// expandIncludes 展开模板中的 !include，base 为当前文档的路径或 URL，相对路径以其所在目录为准
+func expandIncludes(n *yaml.Node, base string, depth int) error {
+	if n.Kind == yaml.SequenceNode {
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for _, c := range n.Content {
+			if c.Tag != includeTag {
+				if err := expandIncludes(c, base, depth); err != nil {
+					return err
+				}
+				content = append(content, c)
+				continue
+			}
+			inc, err := loadInclude(c.Value, base, depth)
+			if err != nil {
+				return err
+			}
+			if inc.Kind == yaml.SequenceNode {
+				content = append(content, inc.Content...)
+			} else {
+				content = append(content, inc)
+			}
+		}
+		n.Content = content
+		return nil
+	}
+
+	for i, c := range n.Content {
+		if c.Tag != includeTag {
+			if err := expandIncludes(c, base, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		inc, err := loadInclude(c.Value, base, depth)
+		if err != nil {
+			return err
+		}
+		n.Content[i] = inc
+	}
+	return nil
+}
+
+// loadInclude 读取并解析被引用的片段，片段中的 !include 同样展开
+func loadInclude(ref, base string, depth int) (*yaml.Node, error) {
+	if depth >= maxIncludeDepth {
+		return nil, fmt.Errorf("include %s: nested too deep", ref)
+	}
+	src, err := resolveInclude(ref, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if isRemoteTemplate(src) {
+		data, err = remoteTemplates.Get(src)
+	} else {
+		data, err = readTemplateFile(src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("include %s: %v", ref, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("include %s: %v", ref, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	node := doc.Content[0]
+	if err := expandIncludes(node, src, depth+1); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// resolveInclude 计算片段地址。远程模板中的相对路径按 URL 解析，不允许引用本地文件
+func resolveInclude(ref, base string) (string, error) {
+	if isRemoteTemplate(ref) {
+		return ref, nil
+	}
+	if isRemoteTemplate(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		r, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("include %s: %v", ref, err)
+		}
+		return b.ResolveReference(r).String(), nil
+	}
+	if filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	return filepath.Join(filepath.Dir(base), ref), nil
+}
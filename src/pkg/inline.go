@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// inlineRuleProviders 下载规则中引用的每个 rule-provider，将其条目展开为普通规则并移除 provider，
+// 生成无需再拉取规则集的独立配置。下载失败的 provider 保持原样
+func inlineRuleProviders(clashConfig *clash.Config) {
+	names := make([]string, 0, len(clashConfig.RulesProviders))
+	for name := range clashConfig.RulesProviders {
+		names = append(names, name)
+	}
+	entries := make([][]string, len(names))
+	errs := make([]error, len(names))
+	parallel(context.Background(), len(names), func(i int) {
+		entries[i], errs[i] = fetchRuleProvider(clashConfig.RulesProviders[names[i]])
+	})
+
+	expanded := make(map[string][]string, len(names))
+	for i, name := range names {
+		if errs[i] != nil {
+			errorf("Error inlining rule-provider %s: %v, kept as provider", name, errs[i])
+			continue
+		}
+		expanded[name] = entries[i]
+	}
+
+	var rules []string
+	for _, rule := range clashConfig.Rules {
+		parts := strings.Split(rule, ",")
+		if len(parts) < 3 || !strings.EqualFold(parts[0], "RULE-SET") {
+			rules = append(rules, rule)
+			continue
+		}
+		list, ok := expanded[parts[1]]
+		if !ok {
+			rules = append(rules, rule)
+			continue
+		}
+		behavior := clashConfig.RulesProviders[parts[1]].Behavior
+		noResolve := len(parts) > 3 && parts[3] == "no-resolve"
+		for _, entry := range list {
+			if r, ok := inlineProviderEntry(behavior, entry, parts[2], noResolve); ok {
+				rules = append(rules, r)
+			}
+		}
+	}
+	for name := range expanded {
+		delete(clashConfig.RulesProviders, name)
+	}
+	clashConfig.Rules = rules
+}
+
+// fetchRuleProvider 下载规则集，支持 yaml（payload 列表）与每行一条的文本格式
+func fetchRuleProvider(p clash.RulesProvider) ([]string, error) {
+	if p.Type != "http" || p.URL == "" {
+		return nil, fmt.Errorf("unsupported provider type %q", p.Type)
+	}
+	if p.Format == "mrs" {
+		return nil, fmt.Errorf("unsupported provider format %q", p.Format)
+	}
+	data, err := remoteTemplates.Get(p.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Format != "text" {
+		var doc struct {
+			Payload []string `yaml:"payload"`
+		}
+		if err := yaml.Unmarshal(data, &doc); err == nil && len(doc.Payload) > 0 {
+			return doc.Payload, nil
+		}
+	}
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") || line == "payload:" {
+			continue
+		}
+		entries = append(entries, strings.Trim(strings.TrimPrefix(line, "- "), `'"`))
+	}
+	return entries, nil
+}
+
+// inlineProviderEntry 将规则集中的一条按 behavior 转为普通规则，内容与 behavior 不符时按实际语法转换；
+// no-resolve 只附加到按 IP 匹配的规则
+func inlineProviderEntry(behavior, entry, target string, noResolve bool) (string, bool) {
+	e, ok := clash.ParseRuleEntry(behavior, entry)
+	if !ok {
+		return "", false
+	}
+	if noResolve && e.IsIP() && !slices.Contains(e.Options, "no-resolve") {
+		e.Options = append(e.Options, "no-resolve")
+	}
+	return e.Rule(target), true
+}
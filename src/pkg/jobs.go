@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// 任务状态
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+const (
+	// jobTTL 为任务完成后结果的保留时间
+	jobTTL = time.Hour
+
+	defaultMaxJobs = 100
+)
+
+// Job 代表一个后台转换任务
+type Job struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Size       int        `json:"size,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	result []byte
+	err    error
+}
+
+// JobQueue 在内存中保存任务，过期任务在创建新任务时清理
+type JobQueue struct {
+	mu   sync.RWMutex
+	max  int // 保留的任务数上限（含未过期的已完成任务），0 表示不限制
+	jobs map[string]*Job
+}
+
+// Jobs 是全局任务队列，在 setup 中初始化
+var Jobs *JobQueue
+
+// NewJobQueue 创建任务队列，max 为保留的任务数上限，0 表示不限制
+func NewJobQueue(max int) *JobQueue {
+	return &JobQueue{max: max, jobs: make(map[string]*Job)}
+}
+
+// Submit 创建任务并在后台执行 run，保留的任务数达到上限时返回 ErrLimitReached
+func (q *JobQueue) Submit(run func() ([]byte, error)) (Job, error) {
+	id, err := randomID(16)
+	if err != nil {
+		return Job{}, err
+	}
+	job := &Job{ID: id, Status: JobPending, CreatedAt: time.Now()}
+
+	q.mu.Lock()
+	q.expireLocked()
+	if q.max > 0 && len(q.jobs) >= q.max {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("%w: %d jobs kept (max-jobs)", ErrLimitReached, q.max)
+	}
+	q.jobs[id] = job
+	q.mu.Unlock()
+
+	go func() {
+		q.update(id, func(j *Job) { j.Status = JobRunning })
+		data, err := run()
+		q.update(id, func(j *Job) {
+			now := time.Now()
+			j.FinishedAt = &now
+			if err != nil {
+				j.Status = JobFailed
+				j.Error = err.Error()
+				j.err = err
+				return
+			}
+			j.Status = JobDone
+			j.Size = len(data)
+			j.result = data
+		})
+	}()
+	return *job, nil
+}
+
+// Get 返回任务快照
+func (q *JobQueue) Get(id string) (Job, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+func (q *JobQueue) update(id string, fn func(*Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job, ok := q.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// expireLocked 删除已完成且超过保留时间的任务，调用方需持有写锁
+func (q *JobQueue) expireLocked() {
+	now := time.Now()
+	for id, job := range q.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > jobTTL {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// createJob 校验参数后提交后台转换任务
+func createJob(c *gin.Context) {
+	params, err := bindParams(c)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+	query := params
+	subURL, params, err := resolveSubscription(params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	opts, err := parseOptions(params)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+	setProviderURL(&opts, requestBaseURL(c), query)
+
+	job, err := Jobs.Submit(func() ([]byte, error) {
+		data, _, err := processConvert(context.Background(), subURL, opts)
+		if err != nil {
+			return nil, err
+		}
+		// 与 /config 相同，只有成功生成配置时才记录 token 的使用
+		if err := spendToken(query.Get("token")); err != nil {
+			return nil, err
+		}
+		return data, nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", "/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// getJob 返回任务状态
+func getJob(c *gin.Context) {
+	job, err := Jobs.Get(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// getJobResult 返回已完成任务的 YAML 结果
+func getJobResult(c *gin.Context) {
+	job, err := Jobs.Get(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch job.Status {
+	case JobDone:
+		c.Header("Content-Disposition", "attachment; filename=\"out.yaml\"")
+		c.Data(http.StatusOK, "application/x-yaml", job.result)
+	case JobFailed:
+		c.Error(job.err)
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "job is " + job.Status})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+const (
+	defaultProbeTimeout = 2 * time.Second
+	defaultProbeWorkers = 32
+)
+
+// probeWorkers 返回并发探测/解析的 worker 数量
+func probeWorkers() int {
+	if Global != nil && Global.ProbeWorkers > 0 {
+		return Global.ProbeWorkers
+	}
+	return defaultProbeWorkers
+}
+
+// probeLatency 并发对每个节点的 server:port 发起 TCP 连接，将握手耗时写入 Latency。
+// 不可达的节点 Latency 保持为 0。ctx 结束时中止进行中的探测；guard 见 probeDialer
+func probeLatency(ctx context.Context, proxies []clash.Proxy, timeout time.Duration, guard bool) {
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	parallel(ctx, len(proxies), func(i int) {
+		proxies[i].Latency = dialLatency(ctx, proxies[i].Server, proxies[i].Port, timeout, guard)
+	})
+}
+
+// parallel 使用 probeWorkers 个 goroutine 对 [0, n) 并发执行 fn，全部完成后返回。
+// ctx 结束后不再分派新的任务
+func parallel(ctx context.Context, n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < probeWorkers() && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func dialLatency(ctx context.Context, server string, port int, timeout time.Duration, guard bool) time.Duration {
+	start := time.Now()
+	conn, err := probeDialer(timeout, guard).DialContext(ctx, "tcp", net.JoinHostPort(server, strconv.Itoa(port)))
+	if err != nil {
+		return 0
+	}
+	conn.Close()
+	return time.Since(start)
+}
+
+// filterAlive 丢弃探测不可达的节点
+func filterAlive(proxies []clash.Proxy) []clash.Proxy {
+	filtered := make([]clash.Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if p.Latency > 0 {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// filterLatency 丢弃不可达或延迟高于 max 的节点
+func filterLatency(proxies []clash.Proxy, max time.Duration) []clash.Proxy {
+	filtered := make([]clash.Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if p.Latency == 0 || p.Latency > max {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// annotateLatency 在节点名称后附加延迟，如 "香港 01 | 35ms"
+func annotateLatency(proxies []clash.Proxy) {
+	for i := range proxies {
+		if proxies[i].Latency == 0 {
+			proxies[i].Name += " | timeout"
+			continue
+		}
+		proxies[i].Name += fmt.Sprintf(" | %dms", latencyMillis(proxies[i].Latency))
+	}
+}
+
+// latencyMillis 将延迟换算为毫秒，可达节点至少显示 1ms
+func latencyMillis(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return max(d.Milliseconds(), 1)
+}
+
+// fasterThan 比较两个探测结果，不可达（0）视为最慢
+func fasterThan(a, b time.Duration) bool {
+	if a == 0 || b == 0 {
+		return a != 0
+	}
+	return a < b
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// 支持的 ?pick= 取值
+const (
+	PickFirst  = "first"
+	PickBest   = "best"
+	PickRandom = "random"
+)
+
+func validatePick(mode string) error {
+	switch mode {
+	case "", PickFirst, PickBest, PickRandom:
+		return nil
+	}
+	return fmt.Errorf("invalid pick: %q (want first, best or random)", mode)
+}
+
+// limitProxies 按 pick 方式选出至多 n 个节点，选中的节点保持原有相对顺序。
+// pick=best 依赖 probeLatency 的结果。
+func limitProxies(proxies []clash.Proxy, n int, mode string) []clash.Proxy {
+	if n <= 0 || len(proxies) <= n {
+		return proxies
+	}
+
+	idx := make([]int, len(proxies))
+	for i := range idx {
+		idx[i] = i
+	}
+	switch mode {
+	case PickBest:
+		sort.SliceStable(idx, func(i, j int) bool {
+			return fasterThan(proxies[idx[i]].Latency, proxies[idx[j]].Latency)
+		})
+	case PickRandom:
+		rand.Shuffle(len(idx), func(i, j int) { idx[i], idx[j] = idx[j], idx[i] })
+	}
+
+	picked := idx[:n]
+	sort.Ints(picked)
+	limited := make([]clash.Proxy, 0, n)
+	for _, i := range picked {
+		limited = append(limited, proxies[i])
+	}
+	return limited
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/parser"
+	"pkg/main.go/src/pkg/region"
+)
+
+// localProxyTypes 为本地 Clash 配置中可合并的节点类型，clash.Proxy 目前只描述 vmess 的字段
+var localProxyTypes = map[string]bool{"vmess": true}
+
+// loadLocalNodes 读取 local-nodes 中的文件，每次转换时重新读取，修改后无需重启。
+// 读取或解析失败的文件记录警告后跳过，不影响订阅本身的转换
+func loadLocalNodes() []clash.Proxy {
+	var proxies []clash.Proxy
+	for _, path := range Global.LocalNodes {
+		nodes, err := readLocalNodes(path)
+		if err != nil {
+			warnf("Warning: Skipped local nodes %s: %v", path, err)
+			continue
+		}
+		debugf("Merged %d local nodes from %s", len(nodes), path)
+		proxies = append(proxies, nodes...)
+	}
+	region.Tag(proxies)
+	return proxies
+}
+
+// readLocalNodes 解析单个本地文件：含 proxies 列表的 Clash 配置，或与订阅相同格式的节点链接
+func readLocalNodes(path string) ([]clash.Proxy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Proxies []clash.Proxy `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err == nil && len(doc.Proxies) > 0 {
+		proxies := make([]clash.Proxy, 0, len(doc.Proxies))
+		for i, p := range doc.Proxies {
+			switch {
+			case p.Name == "" || p.Server == "" || p.Port <= 0:
+				warnf("Warning: Skipped proxy %d in %s: missing name, server or port", i+1, path)
+			case !localProxyTypes[p.Type]:
+				warnf("Warning: Skipped proxy %s in %s: unsupported type %q", p.Name, path, p.Type)
+			default:
+				proxies = append(proxies, p)
+			}
+		}
+		if len(proxies) == 0 {
+			return nil, fmt.Errorf("no usable proxies")
+		}
+		return proxies, nil
+	}
+
+	proxies, skipped, err := parser.Parse(data)
+	for _, s := range skipped {
+		warnf("Warning: Skipped link on line %d in %s: %s", s.Line, path, s.Reason)
+	}
+	return proxies, err
+}
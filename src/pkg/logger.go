@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// currentLogLevel 低于该级别的日志不输出，由 log-level 配置或 --log-level 设置
+var currentLogLevel = levelInfo
+
+// setLogLevel 设置日志级别：debug / info / warn / error
+func setLogLevel(s string) error {
+	switch strings.ToLower(s) {
+	case "debug":
+		currentLogLevel = levelDebug
+	case "", "info":
+		currentLogLevel = levelInfo
+	case "warn", "warning":
+		currentLogLevel = levelWarn
+	case "error":
+		currentLogLevel = levelError
+	default:
+		return fmt.Errorf("invalid log-level: %q (want debug, info, warn or error)", s)
+	}
+	return nil
+}
+
+func logAt(level logLevel, format string, args ...interface{}) {
+	if level >= currentLogLevel {
+		log.Printf(format, args...)
+	}
+}
+
+func debugf(format string, args ...interface{}) { logAt(levelDebug, format, args...) }
+func infof(format string, args ...interface{})  { logAt(levelInfo, format, args...) }
+func warnf(format string, args ...interface{})  { logAt(levelWarn, format, args...) }
+func errorf(format string, args ...interface{}) { logAt(levelError, format, args...) }
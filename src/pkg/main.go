@@ -1,92 +1,59 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
-	"strings"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gopkg.in/yaml.v3"
+	"go.opentelemetry.io/otel/attribute"
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/generator"
+	"pkg/main.go/src/pkg/parser"
+	"pkg/main.go/src/pkg/region"
+	"pkg/main.go/src/pkg/template"
 )
 
-// VmessNode 用于解析 vmess:// 链接解码后的 JSON
-type VmessNode struct {
-	Add  string `json:"add"`  // 地址
-	Aid  int    `json:"aid"`  // alterId
-	Host string `json:"host"` // 伪装域名
-	ID   string `json:"id"`   // UUID
-	Net  string `json:"net"`  // 网络类型 (ws, tcp)
-	Path string `json:"path"` // WebSocket 路径
-	Port string `json:"port"` // 端口
-	PS   string `json:"ps"`   // 节点名称 (Remark)
-	TLS  string `json:"tls"`  // 是否启用 TLS
-	Type string `json:"type"` // 伪装类型 (none, http)
-	V    string `json:"v"`    // 版本
-}
-
-// ClashProxy 代表 Clash 配置中的一个代理项
-type ClashProxy struct {
-	Name     string                 `yaml:"name"`
-	Type     string                 `yaml:"type"`
-	Server   string                 `yaml:"server"`
-	Port     int                    `yaml:"port"`
-	UUID     string                 `yaml:"uuid"`
-	AlterID  int                    `yaml:"alterId"`
-	Cipher   string                 `yaml:"cipher"`
-	TLS      bool                   `yaml:"tls"`
-	Network  string                 `yaml:"network,omitempty"`
-	WSOpts   map[string]interface{} `yaml:"ws-opts,omitempty"`
-	SkipCert bool                   `yaml:"skip-cert-verify"`
-}
-
-// RulesProvider defines the structure for rule providers
-type RulesProvider struct {
-	Type     string `yaml:"type"`
-	Behavior string `yaml:"behavior"`
-	URL      string `yaml:"url"`
-	Path     string `yaml:"path"`
-	Interval int    `yaml:"interval"`
-}
-
-// ClashConfig 代表完整的 Clash 配置文件结构
-type ClashConfig struct {
-	Port           int                      `yaml:"port"`
-	SocksPort      int                      `yaml:"socks-port"`
-	AllowLan       bool                     `yaml:"allow-lan"`
-	Mode           string                   `yaml:"mode"`
-	LogLevel       string                   `yaml:"log-level"`
-	ExternalCtrl   string                   `yaml:"external-controller"`
-	Proxies        []ClashProxy             `yaml:"proxies"`
-	ProxyGroups    []ProxyGroup             `yaml:"proxy-groups"`
-	RulesProviders map[string]RulesProvider `yaml:"rule-providers"`
-	Rules          []string                 `yaml:"rules"`
-}
-
-// ProxyGroup 代表 Clash 配置中的代理组
-type ProxyGroup struct {
-	Name    string   `yaml:"name"`
-	Type    string   `yaml:"type"`
-	Proxies []string `yaml:"proxies"`
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
-func main() {
-	cfg, err := Init()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-		return
+// serve 启动 HTTP 服务
+func serve(cfg *Config) error {
+	urlGuardActive = true
+	if err := setup(cfg); err != nil {
+		return err
+	}
+	if err := watchTemplates(); err != nil {
+		warnf("Warning: %v, template hot reload disabled", err)
+	}
+	if cfg.RefreshInterval > 0 {
+		startRefresher(cfg.RefreshInterval)
 	}
+	if Usage.backend != nil {
+		startUsageFlusher()
+	}
+	startAuditFlusher()
+	gin.SetMode(cfg.GinMode)
 	r := gin.New()
 
-	// 添加中间件
-	r.Use(gin.Logger())
+	// 添加中间件，访问日志在 log-level 为 warn 及以上时关闭
+	if currentLogLevel <= levelInfo {
+		r.Use(gin.Logger())
+	}
 	r.Use(gin.Recovery())
+	r.Use(errorHandler())
+	if cfg.OTLPEndpoint != "" {
+		r.Use(tracingMiddleware())
+	}
+	r.Use(basicAuth())
 
 	// 添加自定义中间件（示例）
 	r.Use(func(c *gin.Context) {
@@ -96,24 +63,207 @@ func main() {
 
 	// 健康检查路由
 	r.GET("/health", healthCheck)
+	r.GET("/healthz/ready", readinessCheck)
+	r.GET("/selftest", selftest)
 
 	// 配置信息路由
 	r.GET("/config", processConfig)
-	r.Run(":8088")
+	r.GET("/config/diff", configDiff)
+	r.GET("/config/meta", configMeta)
+	r.GET("/config/:name", processNamedConfig)
+	r.GET("/nodes", previewNodes)
+	r.GET("/validate", validateConfig)
+	r.GET("/provider", serveProvider)
+	r.POST("/extract", extractLinks)
+
+	// Web UI
+	registerWebRoutes(r)
+
+	// 短链接
+	r.POST("/short", createShortLink)
+	r.GET("/s/:id", serveShortLink)
+	r.GET("/qrcode", serveQRCode)
+
+	// 异步转换任务
+	r.POST("/jobs", createJob)
+	r.GET("/jobs/:id", getJob)
+	r.GET("/jobs/:id/result", getJobResult)
+
+	// 订阅管理接口
+	registerAdminRoutes(r)
+	err := listen(r, cfg)
+	// 退出前写回定时任务尚未保存的下载记录与使用统计
+	if err := Audit.Flush(); err != nil {
+		warnf("Failed to save audit log: %v", err)
+	}
+	if Usage.backend != nil {
+		if err := Usage.Flush(); err != nil {
+			warnf("Failed to save usage stats: %v", err)
+		}
+	}
+	return err
+}
+
+// setup 初始化转换所需的全局状态（存储、缓存、DNS、上游客户端），HTTP 服务与命令行转换共用
+func setup(cfg *Config) error {
+	var err error
+	if cfg.GeoIPDB != "" {
+		if err := loadGeoDB(cfg.GeoIPDB); err != nil {
+			warnf("Warning: %v, country tagging disabled", err)
+		}
+	}
+
+	if store, err = newBackend(cfg); err != nil {
+		return fmt.Errorf("failed to open storage: %v", err)
+	}
+	Subscriptions, err = NewSubscriptionStore(store)
+	if err != nil {
+		return fmt.Errorf("failed to load subscriptions: %v", err)
+	}
+	Jobs = NewJobQueue(cfg.MaxJobs)
+	ShortLinks, err = NewShortLinkStore(store, cfg.MaxShortLinks)
+	if err != nil {
+		return fmt.Errorf("failed to load short links: %v", err)
+	}
+	Tokens, err = NewTokenStore(store)
+	if err != nil {
+		return fmt.Errorf("failed to load tokens: %v", err)
+	}
+	// 使用统计写入频繁，只在 sqlite 后端时持久化
+	var usageBackend Backend
+	if cfg.Storage == "sqlite" {
+		usageBackend = store
+	}
+	if Usage, err = NewUsageStats(usageBackend); err != nil {
+		return fmt.Errorf("failed to load usage stats: %v", err)
+	}
+	if Audit, err = NewAuditLog(store, cfg.AuditMaxEntries); err != nil {
+		return fmt.Errorf("failed to load audit log: %v", err)
+	}
+	if err := loadHistory(store); err != nil {
+		return fmt.Errorf("failed to load history: %v", err)
+	}
+	if conversionCache, err = newCache(cfg); err != nil {
+		return fmt.Errorf("failed to configure cache: %v", err)
+	}
+	if cfg.UpstreamCache {
+		// redis 后端时上游内容也保存在 redis 中，多个副本共享；否则保存在本地磁盘
+		var store Cache = fileCache{dir: filepath.Join(cfg.DataDir, "cache")}
+		if cfg.Cache == "redis" {
+			store = conversionCache
+		}
+		upstreamCache = NewUpstreamCache(store)
+	}
+	if cfg.DNSServer != "" {
+		if dnsResolver, err = newResolver(cfg.DNSServer); err != nil {
+			return fmt.Errorf("failed to configure DNS: %v", err)
+		}
+	}
+	if upstreamClient, err = newUpstreamClient(cfg); err != nil {
+		return fmt.Errorf("failed to configure upstream fetch: %v", err)
+	}
+	upstreamLimiter = newHostLimiter(cfg.FetchHostInterval)
+	transport, ok := upstreamClient.Transport.(*http.Transport)
+	if h3, isH3 := upstreamClient.Transport.(*http3Fallback); isH3 {
+		transport, ok = h3.tcp.(*http.Transport)
+	}
+	if ok {
+		guardedClient = newGuardedClient(transport, cfg.FetchConnectTimeout, cfg.FetchTimeout)
+	}
+	remoteTemplates.guarded = newGuardedClient(http.DefaultTransport.(*http.Transport), cfg.FetchConnectTimeout, remoteTemplateTimeout)
+	if err := initTracing(cfg); err != nil {
+		return fmt.Errorf("failed to configure tracing: %v", err)
+	}
+	return nil
 }
 
 func processConfig(c *gin.Context) {
-	data, err := processConvert()
+	serveConfig(c, c.Request.URL.Query())
+}
+
+// processNamedConfig 处理 /config/<name>，等价于 /config?sub=<name>
+func processNamedConfig(c *gin.Context) {
+	query := c.Request.URL.Query()
+	query.Set("sub", c.Param("name"))
+	serveConfig(c, query)
+}
+
+// serveConfig 按给定参数完成转换并返回 YAML
+func serveConfig(c *gin.Context, query url.Values) {
+	subURL, params, err := resolveSubscription(query)
+	if err != nil {
+		// 记录用户 token 越权访问其他订阅等失败
+		Usage.Record(query.Get("token"), 0, err)
+		c.Error(err)
+		return
+	}
+	opts, err := parseOptions(params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(badRequest(err))
+		return
 	}
+	setProviderURL(&opts, requestBaseURL(c), query)
+
+	data, nodes, err := convertCached(c.Request.Context(), subURL, params, opts)
+	if err == nil {
+		err = spendToken(query.Get("token"))
+	}
+	Usage.Record(query.Get("token"), len(data), err)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	Audit.Record(c, query.Get("token"), auditSubscription(params, subURL), nodes)
+	setStaleHeader(c, subURL)
 
-	c.Header("Content-Type", "application/x-yaml")
 	c.Header("Content-Disposition", "attachment; filename=\"out.yaml\"")
+	setTrafficHeaders(c, subURL, params, opts.ShowTraffic)
 
 	// 返回 YAML 流
 	c.Data(http.StatusOK, "application/x-yaml", data)
+}
 
+// previewNodes 返回解析并过滤后的节点列表，供 Web UI 预览
+func previewNodes(c *gin.Context) {
+	subURL, params, err := resolveSubscription(c.Request.URL.Query())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	opts, err := parseOptions(params)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+
+	proxies, err := fetchProxies(c.Request.Context(), subURL, opts)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	setStaleHeader(c, subURL)
+
+	nodes := make([]gin.H, 0, len(proxies))
+	for _, p := range proxies {
+		node := gin.H{
+			"name":    p.Name,
+			"type":    p.Type,
+			"server":  p.Server,
+			"port":    p.Port,
+			"network": p.Network,
+		}
+		if opts.GeoIP {
+			node["country"] = p.Country
+		}
+		if opts.needsProbe() {
+			node["latency_ms"] = latencyMillis(p.Latency)
+		}
+		if opts.ResponseTest {
+			node["response_ms"] = latencyMillis(p.Response)
+		}
+		nodes = append(nodes, node)
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(nodes), "nodes": nodes})
 }
 
 func healthCheck(c *gin.Context) {
@@ -124,195 +274,435 @@ func healthCheck(c *gin.Context) {
 	})
 }
 
-func processConvert() (data []byte, err error) {
-	// 1. 获取订阅内容
-	log.Println("Fetching subscription content from:", Global.Url)
-	resp, err := http.Get(Global.Url)
+// resolveSubscription 先合并 ?token= 绑定的默认参数（token 属于 users 时只能访问该用户的订阅集，见 resolveUser），再根据 ?sub=<name> 选择已注册的订阅，其次为 ?url=，都未指定时使用配置文件中的 url。
+// 返回的参数为订阅默认选项与请求查询参数合并后的结果，请求参数优先。
+func resolveSubscription(params url.Values) (string, url.Values, error) {
+	return resolveSubscriptionFor(params, true)
+}
+
+// resolveOperatorSubscription 供命令行与定时刷新使用，由运维发起，按名称访问用户的订阅时不要求其 token
+func resolveOperatorSubscription(params url.Values) (string, url.Values, error) {
+	return resolveSubscriptionFor(params, false)
+}
+
+func resolveSubscriptionFor(params url.Values, checkOwner bool) (string, url.Values, error) {
+	if token := params.Get("token"); token != "" {
+		if u, ok := lookupUser(token); ok {
+			return resolveUser(u, params)
+		}
+	}
+	userURL, userSub := params.Get("url"), params.Get("sub")
+	if checkOwner && userSub != "" {
+		if err := checkOwnedSubscription(userSub, params.Get("token")); err != nil {
+			return "", nil, err
+		}
+	}
+	params, err := applyTokenBinding(params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subscription URL: %v", err)
+		return "", nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read subscription response body: %v", err)
+	name := params.Get("sub")
+	if name == "" {
+		// 允许通过 ?url= 直接指定订阅地址（Web UI 生成的链接使用此方式）
+		if raw := params.Get("url"); raw != "" {
+			if err := validateSubscriptionURL(raw); err != nil {
+				return "", nil, err
+			}
+			if raw != userURL {
+				// token 绑定的地址
+				return raw, params, nil
+			}
+			if err := checkUserURL(raw); err != nil {
+				return "", nil, err
+			}
+			return raw, params, nil
+		}
+		return Global.Url, params, nil
 	}
 
-	// 2. Base64 解码
-	decodedBody, err := base64.StdEncoding.DecodeString(string(body))
+	sub, err := lookupSubscription(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 subscription content: %v", err)
+		return "", nil, fmt.Errorf("%w: %s", err, name)
 	}
+	merged := make(url.Values, len(params)+len(sub.Options))
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range sub.Options {
+		if !merged.Has(k) {
+			merged.Set(k, v)
+		}
+	}
+	return sub.URL, merged, nil
+}
 
-	// 3. 按行分割节点链接
-	nodeLinks := strings.Split(string(decodedBody), "\n")
-
-	var clashProxies []ClashProxy
-	var proxyNames []string
+// processConvert 完成一次转换，返回生成的配置与其中内联的节点数（proxy-provider 模式为 0）
+func processConvert(ctx context.Context, subURL string, opts ConvertOptions) (data []byte, nodes int, err error) {
+	ctx, span := startSpan(ctx, "convert")
+	defer func() { endSpan(span, err) }()
+	if opts.ProxyProvider && opts.ProviderURL == "" {
+		return nil, 0, badRequest(errNoProviderURL)
+	}
 
-	// 4. 循环解析每个节点
-	for _, link := range nodeLinks {
-		link = strings.TrimSpace(link)
-		if strings.HasPrefix(link, "vmess://") {
-			vmessBase64 := strings.TrimPrefix(link, "vmess://")
-			if len(vmessBase64)%4 != 0 {
-				padding_needed := 4 - (len(vmessBase64) % 4)
-				for i := 0; i < padding_needed; i++ {
-					vmessBase64 += "="
-				}
-			}
+	clashProxies, err := fetchProxies(ctx, subURL, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := renderConfig(ctx, clashProxies, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if Global.OutputCheck {
+		if err := checkOutput(out.config); err != nil {
+			return nil, 0, err
+		}
+	}
+	if data, err = out.generate(ctx); err != nil {
+		return nil, 0, err
+	}
+	infof("Generated %s config with %d nodes (%d bytes)", out.gen.Target(), len(out.proxies), len(data))
+	return data, len(out.proxies), nil
+}
 
-			vmessJSON, err := base64.StdEncoding.DecodeString(vmessBase64)
-			if err != nil {
-				log.Printf("Warning: Failed to decode vmess link, skipping: %v", err)
-				continue
-			}
+// renderedConfig 为套用模板后、尚未按目标格式输出的配置
+type renderedConfig struct {
+	gen     generator.Generator
+	proxies []clash.Proxy // 配置中内联的节点，proxy-provider 模式为 nil
+	nodes   int           // 按目标适配后的节点数
+	config  clash.Config
+	rules   RuleCheck
+}
 
-			var node VmessNode
-			if err := json.Unmarshal(vmessJSON, &node); err != nil {
-				log.Printf("Warning: Failed to unmarshal vmess JSON, skipping: %v", err)
-				continue
-			}
+// renderConfig 按目标适配节点并套用模板、外部配置与自定义规则，/config 与 /validate 共用
+func renderConfig(ctx context.Context, clashProxies []clash.Proxy, opts ConvertOptions) (*renderedConfig, error) {
+	gen, ok := generator.Lookup(opts.Target)
+	if !ok {
+		return nil, badRequest(fmt.Errorf("unsupported target: %q", opts.Target))
+	}
+	// 在生成节点名称列表之前去掉目标无法加载的节点与字段
+	clashProxies, warnings := generator.Adapt(gen, clashProxies)
+	for _, w := range warnings {
+		warnf("Target %s: %s", gen.Target(), w)
+	}
+	if len(clashProxies) == 0 {
+		return nil, ErrNoNodes
+	}
 
-			// 5. 转换为 ClashProxy 结构
-			proxy, err := convertVmessToClashProxy(node)
-			if err != nil {
-				log.Printf("Warning: Failed to convert vmess node '%s', skipping: %v", node.PS, err)
-				continue
-			}
-			clashProxies = append(clashProxies, proxy)
-			proxyNames = append(proxyNames, proxy.Name)
-		}
+	if opts.Base != nil {
+		clash.AvoidNames(clashProxies, opts.Base.Names())
 	}
 
-	if len(clashProxies) == 0 {
-		return nil, fmt.Errorf("no valid vmess nodes found in the subscription")
+	proxyNames := make([]string, 0, len(clashProxies))
+	for _, p := range clashProxies {
+		proxyNames = append(proxyNames, p.Name)
 	}
-	log.Printf("Successfully converted %d nodes.", len(clashProxies))
 
 	// 6. 创建完整的 Clash 配置
-	clashConfig := createDefaultClashConfig(clashProxies, proxyNames)
+	_, tspan := startSpan(ctx, "template", attribute.String("template", opts.Template))
+	out := &renderedConfig{gen: gen, nodes: len(clashProxies)}
+	out.config = createDefaultClashConfig(clashProxies, proxyNames, opts)
+	rules, err := checkRules(&out.config)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrTemplate, err)
+		endSpan(tspan, err)
+		return nil, err
+	}
+	out.rules = rules
+	if opts.ProxyProvider {
+		// 节点由客户端从 /provider 下载，配置中只保留分组对 provider 的引用
+		useProxyProviders(&out.config, clashProxies, opts.ProviderURL)
+		clashProxies = nil
+	}
+	out.proxies = clashProxies
+	out.config.Proxies = clashProxies
+	tspan.End()
+	return out, nil
+}
 
-	// 7. 序列化为 YAML
-	yamlData, err := yaml.Marshal(clashConfig)
+// generate 按目标格式输出配置
+func (r *renderedConfig) generate(ctx context.Context) ([]byte, error) {
+	// 7. 按目标格式输出
+	_, gspan := startSpan(ctx, "generate", attribute.String("target", r.gen.Target()))
+	data, err := r.gen.Generate(r.proxies, r.config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal clash config to YAML: %v", err)
+		err = fmt.Errorf("%w: failed to generate %s config: %v", ErrTemplate, r.gen.Target(), err)
+		endSpan(gspan, err)
+		return nil, err
 	}
+	gspan.SetAttributes(attribute.Int("bytes", len(data)))
+	gspan.End()
+	return data, nil
+}
 
-	return yamlData, nil
+// fetchSubscription 获取订阅原始内容。同一订阅（地址与拉取设置相同）的并发请求合并为一次上游拉取，
+// 返回的内容由所有调用方共享，不可修改
+// ctx 结束时立即返回，所有调用方都已返回时取消上游请求
+func fetchSubscription(ctx context.Context, subURL string, fetch FetchOptions) ([]byte, error) {
+	key := fetchKey(subURL, fetch)
+	fetchCtx, leave := joinFetch(key)
+	defer leave()
+	ch := fetchGroup.DoChan(key, func() (interface{}, error) {
+		return fetchSubscriptionRetry(fetchCtx, subURL, fetch)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to fetch subscription URL: %w", ctx.Err())
+	case r := <-ch:
+		if r.Shared {
+			debugf("Shared in-flight fetch of %s", subURL)
+		}
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.([]byte), nil
+	}
 }
 
-// convertVmessToClashProxy 将 VmessNode 转换为 ClashProxy
-func convertVmessToClashProxy(node VmessNode) (ClashProxy, error) {
-	port, err := strconv.Atoi(node.Port)
-	if err != nil {
-		return ClashProxy{}, fmt.Errorf("invalid port: %s", node.Port)
+// fetchSubscriptionRetry 拉取订阅，可重试的失败按 fetch-retries 与 fetch-backoff 重试
+func fetchSubscriptionRetry(ctx context.Context, subURL string, fetch FetchOptions) ([]byte, error) {
+	infof("Fetching subscription content from: %s", subURL)
+	attempts := Global.FetchRetries + 1
+	backoff := Global.FetchBackoff
+	for attempt := 1; ; attempt++ {
+		body, retryable, err := fetchSubscriptionOnce(ctx, subURL, fetch)
+		if err == nil || !retryable || attempt >= attempts || ctx.Err() != nil {
+			return body, err
+		}
+		warnf("Fetch attempt %d/%d failed: %v, retrying in %s", attempt, attempts, err, backoff)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return nil, fmt.Errorf("failed to fetch subscription URL: %w", err)
+		}
+		backoff *= 2
 	}
+}
 
-	proxy := ClashProxy{
-		Name:     node.PS,
-		Type:     "vmess",
-		Server:   node.Add,
-		Port:     port,
-		UUID:     node.ID,
-		AlterID:  int(node.Aid),
-		Cipher:   "auto", // Clash 会自动选择
-		TLS:      node.TLS == "tls",
-		SkipCert: true, // 通常建议跳过证书验证
-		Network:  node.Net,
+// fetchSubscriptionOnce 拉取一次订阅，网络错误、超时与 5xx / 429 响应视为可重试
+func fetchSubscriptionOnce(ctx context.Context, subURL string, fetch FetchOptions) ([]byte, bool, error) {
+	req, err := newFetchRequest(subURL, fetch)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch subscription URL: %v", err)
+	}
+	if err := checkFetchHost(req.URL); err != nil {
+		return nil, false, fmt.Errorf("failed to fetch subscription URL: %w", err)
+	}
+	if err := upstreamLimiter.wait(ctx, req.URL.Host); err != nil {
+		return nil, false, fmt.Errorf("failed to fetch subscription URL: %w", err)
+	}
+	resp, err := fetchClient(subURL).Do(req.WithContext(ctx))
+	if errors.Is(err, ErrBlockedURL) {
+		return nil, false, fmt.Errorf("failed to fetch subscription URL: %w", err)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to fetch subscription URL: %v", err)
 	}
+	defer resp.Body.Close()
 
-	if node.Net == "ws" {
-		proxy.WSOpts = make(map[string]interface{})
-		proxy.WSOpts["path"] = node.Path
-		headers := make(map[string]string)
-		headers["Host"] = node.Host
-		proxy.WSOpts["headers"] = headers
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retryable, fmt.Errorf("subscription URL returned status %d", resp.StatusCode)
 	}
 
-	return proxy, nil
+	body, retryable, err := readSubscriptionBody(resp)
+	if err != nil {
+		return nil, retryable, err
+	}
+	recordUserinfo(subURL, resp.Header.Get(userinfoHeader))
+	return body, false, nil
 }
 
-// createDefaultClashConfig 创建一个默认的 Clash 配置框架
-func createDefaultClashConfig(proxies []ClashProxy, proxyNames []string) ClashConfig {
-	// Read template file
-	f, err := os.ReadFile("resources/out-template.yaml")
+// fetchProxies 获取订阅内容并解析为 clash.Proxy 列表，随后应用节点过滤。
+// 订阅含多个来源时并发拉取，部分来源失败时使用其余来源的节点
+func fetchProxies(ctx context.Context, subURL string, opts ConvertOptions) ([]clash.Proxy, error) {
+	// 1. 获取并解析各来源的订阅内容
+	proxies, err := mergeSources(ctx, loadSources(ctx, subURL, opts))
 	if err != nil {
-		log.Printf("Error reading template file: %v, using hardcoded defaults", err)
-		// Fallback to hardcoded defaults if template fails
-		return ClashConfig{
-			Port:         7890,
-			SocksPort:    7891,
-			AllowLan:     true,
-			Mode:         "Rule",
-			LogLevel:     "info",
-			ExternalCtrl: "127.0.0.1:9090",
-			Proxies:      proxies,
-			ProxyGroups: []ProxyGroup{
-				{
-					Name:    "PROXY",
-					Type:    "select",
-					Proxies: append([]string{"DIRECT", "REJECT"}, proxyNames...),
-				},
-			},
-			Rules: []string{
-				"MATCH,DIRECT",
-			},
+		return nil, err
+	}
+	return prepareProxies(ctx, subURL, proxies, opts)
+}
+
+// loadSources 并发拉取并解析订阅的各个来源
+func loadSources(ctx context.Context, subURL string, opts ConvertOptions) []sourceResult {
+	return collectSources(ctx, subURL, func(ctx context.Context, src string) sourceResult {
+		proxies, skipped, err := fetchSourceProxies(ctx, src, opts.Fetch[src])
+		return sourceResult{Proxies: proxies, Skipped: skipped, Err: err}
+	})
+}
+
+// prepareProxies 对合并后的节点依次应用过滤、探测、限量、排序与重命名，得到 /config 输出的节点
+func prepareProxies(ctx context.Context, subURL string, proxies []clash.Proxy, opts ConvertOptions) ([]clash.Proxy, error) {
+	region.Tag(proxies)
+	trackNodeCount(subURL, len(proxies))
+	recordSnapshot(subURL, proxies)
+
+	// 复制一份再处理，避免修改已记录的快照；本地节点不计入订阅的节点数与快照
+	proxies = append([]clash.Proxy(nil), proxies...)
+	if opts.Local {
+		proxies = append(proxies, loadLocalNodes()...)
+	}
+	normalizeZh(proxies, opts.Zh)
+	proxies = filterProxies(proxies, opts)
+	applyOverrides(proxies, opts)
+	if opts.GeoIP || opts.Resolve || opts.ServerCIDR != nil || (opts.IPVersion != "" && opts.IPVersion != ipVersionDual) {
+		_, span := startSpan(ctx, "resolve", attribute.Int("nodes", len(proxies)))
+		ips := resolveServers(ctx, proxies, opts.IPVersion)
+		span.End()
+		proxies = filterIPVersion(proxies, ips, opts.IPVersion)
+		if opts.ServerCIDR != nil {
+			proxies = filterCIDR(proxies, ips, opts.ServerCIDR)
+		}
+		if opts.GeoIP {
+			tagCountries(proxies, ips)
+			if len(opts.Countries) > 0 {
+				proxies = filterCountries(proxies, opts.Countries)
+			}
+		}
+		if opts.Resolve {
+			applyResolved(proxies, ips)
 		}
 	}
+	if opts.Regions != nil {
+		proxies = filterRegions(proxies, opts.Regions)
+	}
+	if opts.needsProbe() {
+		_, span := startSpan(ctx, "probe", attribute.Int("nodes", len(proxies)))
+		probeLatency(ctx, proxies, opts.ProbeTimeout, guardProbes(subURL))
+		span.End()
+		if opts.Alive {
+			proxies = filterAlive(proxies)
+		}
+		if opts.MaxLatency > 0 {
+			proxies = filterLatency(proxies, opts.MaxLatency)
+		}
+	}
+	if opts.ResponseTest {
+		_, span := startSpan(ctx, "response-test", attribute.Int("nodes", len(proxies)))
+		probeResponse(ctx, proxies, opts.ResponseTimeout, guardProbes(subURL))
+		span.End()
+	}
+	if err := ctx.Err(); err != nil {
+		// 客户端已断开或超时，探测结果不完整
+		return nil, fmt.Errorf("conversion canceled: %w", err)
+	}
+	proxies = limitProxies(proxies, opts.Limit, opts.Pick)
+	sortProxies(proxies, opts.Sort)
+	if opts.Rename != nil {
+		opts.Rename.Apply(proxies)
+	}
+	affixNames(proxies, opts.Prefix, opts.Suffix)
+	if opts.ShowLatency {
+		annotateLatency(proxies)
+	}
+	clash.SanitizeNames(proxies)
+	clash.DedupeNames(proxies)
+	return proxies, nil
+}
 
-	// Temporary struct for parsing template
-	type TemplateConfig struct {
-		Port          int                      `yaml:"port"`
-		SocksPort     int                      `yaml:"socks-port"`
-		AllowLan      bool                     `yaml:"allow-lan"`
-		Mode          string                   `yaml:"mode"`
-		LogLevel      string                   `yaml:"log-level"`
-		ExternalCtrl  string                   `yaml:"external-controller"`
-		RuleProviders map[string]RulesProvider `yaml:"rule-providers"`
-		Rules         []string                 `yaml:"rules"`
-		ProxyGroups   []map[string]interface{} `yaml:"proxy-groups"`
-	}
-
-	var tmpl TemplateConfig
-	if err := yaml.Unmarshal(f, &tmpl); err != nil {
-		log.Fatalf("Error parsing template: %v", err)
-	}
-
-	var proxyGroups []ProxyGroup
-	for _, g := range tmpl.ProxyGroups {
-		name, _ := g["name"].(string)
-		typ, _ := g["type"].(string)
-
-		var groupProxies []string
-		// Check proxies field
-		if p, ok := g["proxies"].(string); ok && p == "${proxies}" {
-			groupProxies = append(groupProxies, proxyNames...)
-		} else if pList, ok := g["proxies"].([]interface{}); ok {
-			for _, pItem := range pList {
-				if s, ok := pItem.(string); ok {
-					groupProxies = append(groupProxies, s)
-				}
+// fetchSourceProxies 拉取并解析单个来源，上游不可用时使用缓存的内容
+func fetchSourceProxies(ctx context.Context, subURL string, fetch FetchOptions) ([]clash.Proxy, []parser.SkippedLink, error) {
+	_, span := startSpan(ctx, "fetch", upstreamHostAttr(subURL))
+	body, err := fetchSubscription(ctx, subURL, fetch)
+	fresh := err == nil
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		// 客户端已断开，不使用缓存内容也不发送上游错误通知；超时仍回退到缓存
+		err = fmt.Errorf("%w: %v", ErrUpstream, err)
+		endSpan(span, err)
+		return nil, nil, err
+	}
+	if errors.Is(err, ErrBlockedURL) {
+		// 地址未通过 url-guard 检查，不回退到缓存的内容
+		endSpan(span, err)
+		return nil, nil, err
+	}
+	if err != nil {
+		notify(EventUpstreamError, subURL, err.Error())
+		cached, at, ok := upstreamCache.Load(subURL)
+		if !ok {
+			if !errors.Is(err, ErrUpstream) {
+				err = fmt.Errorf("%w: %v", ErrUpstream, err)
 			}
+			endSpan(span, err)
+			return nil, nil, err
 		}
+		warnf("Upstream unavailable, using cached subscription from %s", at.Format(time.RFC3339))
+		span.SetAttributes(attribute.Bool("stale", true))
+		body = cached
+	}
+	span.SetAttributes(attribute.Int("bytes", len(body)))
+	span.End()
 
-		proxyGroups = append(proxyGroups, ProxyGroup{
-			Name:    name,
-			Type:    typ,
-			Proxies: groupProxies,
-		})
-	}
-
-	return ClashConfig{
-		Port:           tmpl.Port,
-		SocksPort:      tmpl.SocksPort,
-		AllowLan:       tmpl.AllowLan,
-		Mode:           tmpl.Mode,
-		LogLevel:       tmpl.LogLevel,
-		ExternalCtrl:   tmpl.ExternalCtrl,
-		Proxies:        proxies,
-		ProxyGroups:    proxyGroups,
-		RulesProviders: tmpl.RuleProviders,
-		Rules:          tmpl.Rules,
+	proxies, skipped, err := decodeAndParse(ctx, body)
+	if err != nil {
+		notify(EventConversionFailed, subURL, err.Error())
+		return nil, skipped, fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	if fresh {
+		// 只缓存能成功解析的内容
+		upstreamCache.Store(subURL, body)
+	}
+	recordTraffic(subURL, proxies)
+	return proxies, skipped, nil
+}
+
+// decodeAndParse 边解码边解析订阅内容，大订阅不会在内存中保留完整的解码结果
+func decodeAndParse(ctx context.Context, body []byte) ([]clash.Proxy, []parser.SkippedLink, error) {
+	_, span := startSpan(ctx, "parse")
+	proxies, skipped, err := parser.Parse(body)
+	span.SetAttributes(attribute.Int("nodes", len(proxies)), attribute.Int("skipped", len(skipped)))
+	endSpan(span, err)
+	logParseResult(proxies, skipped)
+	return proxies, skipped, err
+}
+
+// logParseResult 记录解析结果，被跳过链接的逐条原因只在 debug 级别输出，避免大订阅刷屏
+func logParseResult(proxies []clash.Proxy, skipped []parser.SkippedLink) {
+	for _, s := range skipped {
+		debugf("Skipped link on line %d %s: %s", s.Line, s.Name, s.Reason)
+	}
+	if len(proxies) == 0 {
+		return
+	}
+	infof("Successfully converted %d nodes.", len(proxies))
+	if len(skipped) > 0 {
+		warnf("Skipped %d unusable links, set log-level to debug for details", len(skipped))
+	}
+}
+
+// createDefaultClashConfig 根据选项生成完整的 Clash 配置
+func createDefaultClashConfig(proxies []clash.Proxy, proxyNames []string, opts ConvertOptions) clash.Config {
+	var clashConfig clash.Config
+	if opts.Base != nil {
+		clashConfig = opts.Base.Render(proxies, proxyNames, template.Options{Logf: errorf})
+	} else {
+		clashConfig = renderClashConfig(proxies, proxyNames, opts.Template, opts.Vars)
+	}
+	if opts.External != nil {
+		applyExternalConfig(&clashConfig, opts.External, proxyNames)
+	}
+	clashConfig.Rules = withCustomRules(clashConfig.Rules, opts.Rules)
+	if opts.InlineRules {
+		inlineRuleProviders(&clashConfig)
+	}
+	if gen, ok := generator.Lookup(opts.Target); ok {
+		if rs, ok := gen.(generator.RuleSupport); ok {
+			downgradeRules(&clashConfig, rs)
+		}
+	}
+	return clashConfig
+}
+
+// renderClashConfig 渲染输出模板并生成 Clash 配置，模板不可用时使用内置的最小配置
+func renderClashConfig(proxies []clash.Proxy, proxyNames []string, templateName string, vars map[string]string) clash.Config {
+	t, err := loadTemplate(templateName)
+	if err != nil {
+		errorf("Error reading template file: %v, using hardcoded defaults", err)
+		return clash.DefaultConfig(proxies, proxyNames)
+	}
+	cfg, err := t.Render(proxies, proxyNames, templateOptions(templateName, vars))
+	if err != nil {
+		errorf("Error rendering template: %v, using hardcoded defaults", err)
+		return clash.DefaultConfig(proxies, proxyNames)
 	}
+	return cfg
 }
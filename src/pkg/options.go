@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pkg/main.go/src/pkg/generator"
+	"pkg/main.go/src/pkg/template"
+)
+
+// ConvertOptions 是从查询参数（及订阅默认选项）解析出的转换选项
+type ConvertOptions struct {
+	StripInfo bool   // ?strip-info= 移除剩余流量、到期时间等信息伪节点，默认开启
+	Local     bool   // ?local=false 不合并 local-nodes 中的本地节点
+	Zh        string // ?zh=simplified|traditional 统一节点名称的繁简体
+
+	Include *regexp.Regexp // ?include= 仅保留名称匹配的节点
+	Exclude *regexp.Regexp // ?exclude= 排除名称匹配的节点
+
+	Types        map[string]bool // ?types=vmess,trojan 仅保留这些协议
+	ExcludeTypes map[string]bool // ?exclude-types=vless,hysteria 排除这些协议
+	Ports        *PortFilter     // ?ports=443,8443 按端口过滤
+	ServerCIDR   *CIDRFilter     // ?server-cidr=!104.16.0.0/12 按解析后的服务器 IP 过滤
+	Sort         string          // ?sort= 节点排序方式
+
+	GeoIP     bool            // ?geoip=true 通过 GeoIP 为节点标记国家
+	Countries []string        // ?country=HK,JP 仅保留这些国家的节点（隐含 geoip=true）
+	Regions   map[string]bool // ?region=HK,JP 仅保留这些地区的节点，按名称识别，开启 geoip 时名称中识别不到的按 GeoIP 结果
+	Resolve   bool            // ?resolve=true 将节点域名预先解析为 IP
+	IPVersion string          // ?ip-version=ipv4|ipv6|ipv4-prefer|ipv6-prefer|dual 解析时选择的地址族并写入节点，ipv4/ipv6 丢弃没有该地址族的节点
+
+	TFO   bool // ?tfo=true 为所有节点开启 TCP Fast Open，链接中已开启的节点不受影响
+	MPTCP bool // ?mptcp=true 为所有节点开启 Multipath TCP，链接中已开启的节点不受影响
+
+	InterfaceName string // ?interface-name= 节点绑定的出站网卡，node-routing 中匹配的节点除外
+	RoutingMark   int    // ?routing-mark= 节点的 routing-mark，node-routing 中匹配的节点除外
+
+	Probe        bool          // ?probe=tcp 对节点进行 TCP 延迟探测
+	ProbeTimeout time.Duration // ?probe-timeout= 单个节点的探测超时
+	MaxLatency   time.Duration // ?max-latency= 丢弃延迟高于该值或不可达的节点
+	Alive        bool          // ?alive=true 丢弃不可达的节点
+	ShowLatency  bool          // ?show-latency=true 在节点名称后附加延迟
+
+	ResponseTest    bool          // ?response-test=true 测量节点入口的响应时间
+	ResponseTimeout time.Duration // ?response-timeout= 单个节点的响应超时
+
+	ShowTraffic bool // ?show-traffic=true 在配置名称后附加剩余流量
+
+	Limit int    // ?limit= 最多保留的节点数，0 表示不限制
+	Pick  string // ?pick= 超出 limit 时的选择方式
+
+	Cipher string // ?scv-cipher= 覆盖 vmess 节点的加密方式
+
+	PacketEncoding      string // ?packet-encoding=packetaddr|xudp vmess 节点的 UDP 封装方式，链接中已设置的节点除外
+	GlobalPadding       bool   // ?global-padding=true 为 vmess 节点开启 global-padding
+	AuthenticatedLength bool   // ?authenticated-length=true 为 vmess 节点开启 authenticated-length
+
+	Rename *RenameTemplate // ?rename= 节点重命名格式
+	Prefix string          // ?prefix= 节点名称前缀
+	Suffix string          // ?suffix= 节点名称后缀
+
+	Target   string   // ?target= 输出格式，为空时为 clash
+	Template string   // ?template= resources/templates 下的模板名称，为空时使用默认模板
+	Rules    []string // ?rules= base64 编码的自定义规则，与配置中的 custom-rules 一起插入到模板规则之前
+
+	External    *ExternalConfig // ?config= ACL4SSR 格式的外部配置，替换模板中的分组与规则
+	Base        *template.Base  // ?base= 用户自己的 Clash 配置，节点与地区分组合并到其中，取代模板
+	InlineRules bool            // ?inline-rules=true 将 rule-provider 展开为普通规则
+
+	ProxyProvider bool   // ?proxy-provider=true 分组引用本服务的 /provider 而不内联节点
+	ProviderURL   string // proxy-provider 模式下的 /provider 地址，由调用方按原始请求参数设置（见 setProviderURL），不来自查询参数
+
+	Vars map[string]string // ?var.NAME=value 填充模板中的 ${var:NAME}，默认值来自配置 vars
+
+	Fetch map[string]FetchOptions // 命名订阅各来源的上游拉取设置，键为来源地址，不来自查询参数
+}
+
+// needsProbe 判断本次转换是否需要探测延迟
+func (o ConvertOptions) needsProbe() bool {
+	return o.Probe || o.Alive || o.Sort == SortLatency || o.MaxLatency > 0 || o.ShowLatency ||
+		(o.Limit > 0 && o.Pick == PickBest)
+}
+
+// parseOptions 将查询参数解析为 ConvertOptions
+func parseOptions(params url.Values) (ConvertOptions, error) {
+	var opts ConvertOptions
+	var err error
+
+	if opts.StripInfo, err = boolParamDefault(params, "strip-info", Global.StripInfo); err != nil {
+		return opts, err
+	}
+	if opts.Local, err = boolParamDefault(params, "local", true); err != nil {
+		return opts, err
+	}
+	opts.Zh = stringParam(params, "zh", Global.Zh)
+	if err := validateZh(opts.Zh); err != nil {
+		return opts, err
+	}
+
+	if opts.Include, err = compileParam(params, "include"); err != nil {
+		return opts, err
+	}
+	if opts.Exclude, err = compileParam(params, "exclude"); err != nil {
+		return opts, err
+	}
+
+	opts.Types = setParam(params, "types")
+	opts.ExcludeTypes = setParam(params, "exclude-types")
+	if raw := params.Get("ports"); raw != "" {
+		if opts.Ports, err = parsePortFilter(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params.Get("server-cidr"); raw != "" {
+		if opts.ServerCIDR, err = parseCIDRFilter(raw); err != nil {
+			return opts, err
+		}
+	}
+
+	if opts.GeoIP, err = boolParamDefault(params, "geoip", Global.GeoIP); err != nil {
+		return opts, err
+	}
+	if raw := params.Get("country"); raw != "" {
+		opts.Countries = strings.Split(raw, ",")
+		opts.GeoIP = true
+	}
+
+	if set := setParam(params, "region"); set != nil {
+		opts.Regions = make(map[string]bool, len(set))
+		for code := range set {
+			opts.Regions[strings.ToUpper(code)] = true
+		}
+	}
+
+	if opts.Resolve, err = boolParamDefault(params, "resolve", Global.Resolve); err != nil {
+		return opts, err
+	}
+	opts.IPVersion = stringParam(params, "ip-version", Global.IPVersion)
+	if err := validateIPVersion(opts.IPVersion); err != nil {
+		return opts, err
+	}
+	if opts.TFO, err = boolParamDefault(params, "tfo", Global.TFO); err != nil {
+		return opts, err
+	}
+	if opts.MPTCP, err = boolParamDefault(params, "mptcp", Global.MPTCP); err != nil {
+		return opts, err
+	}
+	opts.InterfaceName = stringParam(params, "interface-name", Global.InterfaceName)
+	if err := validateInterfaceName(opts.InterfaceName); err != nil {
+		return opts, err
+	}
+	opts.RoutingMark = Global.RoutingMark
+	if raw := params.Get("routing-mark"); raw != "" {
+		if opts.RoutingMark, err = parseRoutingMark(raw); err != nil {
+			return opts, err
+		}
+	}
+
+	opts.Sort = params.Get("sort")
+	if err := validateSort(opts.Sort); err != nil {
+		return opts, err
+	}
+
+	switch p := params.Get("probe"); p {
+	case "", "none":
+	case "tcp":
+		opts.Probe = true
+	default:
+		return opts, fmt.Errorf("invalid probe: %q (want tcp)", p)
+	}
+	if opts.ProbeTimeout, err = durationParam(params, "probe-timeout", Global.ProbeTimeout); err != nil {
+		return opts, err
+	}
+	if opts.MaxLatency, err = durationParam(params, "max-latency", 0); err != nil {
+		return opts, err
+	}
+	if opts.Alive, err = boolParamDefault(params, "alive", Global.Alive); err != nil {
+		return opts, err
+	}
+	if opts.ShowLatency, err = boolParam(params, "show-latency"); err != nil {
+		return opts, err
+	}
+
+	opts.Cipher = params.Get("scv-cipher")
+	if err := validateVmessCipher(opts.Cipher); err != nil {
+		return opts, err
+	}
+	opts.PacketEncoding = stringParam(params, "packet-encoding", Global.PacketEncoding)
+	if err := validatePacketEncoding(opts.PacketEncoding); err != nil {
+		return opts, err
+	}
+	if opts.GlobalPadding, err = boolParamDefault(params, "global-padding", Global.GlobalPadding); err != nil {
+		return opts, err
+	}
+	if opts.AuthenticatedLength, err = boolParamDefault(params, "authenticated-length", Global.AuthenticatedLength); err != nil {
+		return opts, err
+	}
+
+	if opts.ResponseTest, err = boolParam(params, "response-test"); err != nil {
+		return opts, err
+	}
+	if opts.ResponseTimeout, err = durationParam(params, "response-timeout", defaultResponseTimeout); err != nil {
+		return opts, err
+	}
+	if opts.Sort == SortResponse {
+		opts.ResponseTest = true
+	}
+	if opts.ShowTraffic, err = boolParam(params, "show-traffic"); err != nil {
+		return opts, err
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		if opts.Limit, err = strconv.Atoi(raw); err != nil || opts.Limit < 0 {
+			return opts, fmt.Errorf("invalid limit: %q", raw)
+		}
+	}
+	opts.Pick = params.Get("pick")
+	if err := validatePick(opts.Pick); err != nil {
+		return opts, err
+	}
+
+	if format := params.Get("rename"); format != "" {
+		if opts.Rename, err = compileRename(format); err != nil {
+			return opts, err
+		}
+	}
+	opts.Prefix = stringParam(params, "prefix", Global.Prefix)
+	opts.Suffix = stringParam(params, "suffix", Global.Suffix)
+
+	opts.Target = params.Get("target")
+	if _, ok := generator.Lookup(opts.Target); !ok {
+		return opts, fmt.Errorf("unsupported target: %q (want %s)", opts.Target, strings.Join(generator.Targets(), ", "))
+	}
+	opts.Template = params.Get("template")
+	if err := validateTemplate(opts.Template); err != nil {
+		return opts, err
+	}
+	if opts.Rules, err = customRules(params.Get("rules")); err != nil {
+		return opts, err
+	}
+	opts.Vars = templateVars(params)
+	opts.Fetch = subscriptionFetch(params)
+	if opts.InlineRules, err = boolParam(params, "inline-rules"); err != nil {
+		return opts, err
+	}
+	if opts.ProxyProvider, err = boolParamDefault(params, "proxy-provider", Global.ProxyProvider.Enable); err != nil {
+		return opts, err
+	}
+	if opts.ProxyProvider {
+		// 客户端每次更新节点列表都会重新转换，随机选择与名称中的测量结果会让节点集合或名称每次都不同
+		switch {
+		case opts.Pick == PickRandom:
+			return opts, fmt.Errorf("pick=random cannot be combined with proxy-provider")
+		case opts.ShowLatency:
+			return opts, fmt.Errorf("show-latency cannot be combined with proxy-provider")
+		}
+	}
+	if raw := params.Get("config"); raw != "" {
+		if opts.External, err = loadExternalConfig(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params.Get("base"); raw != "" {
+		if opts.External != nil {
+			return opts, fmt.Errorf("base cannot be combined with config")
+		}
+		if opts.Base, err = loadBaseConfig(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// stringParam 返回参数值；参数未出现时返回默认值，显式传空值可覆盖默认值
+func stringParam(params url.Values, key, def string) string {
+	if !params.Has(key) {
+		return def
+	}
+	return params.Get(key)
+}
+
+// setParam 将逗号分隔的参数解析为小写集合，参数为空时返回 nil
+func setParam(params url.Values, key string) map[string]bool {
+	raw := params.Get(key)
+	if raw == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+// durationParam 解析 Go duration 格式（如 1500ms）的参数，参数为空时返回默认值
+func durationParam(params url.Values, key string, def time.Duration) (time.Duration, error) {
+	raw := params.Get(key)
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return d, nil
+}
+
+// boolParam 解析布尔参数，参数为空时返回 false
+func boolParam(params url.Values, key string) (bool, error) {
+	return boolParamDefault(params, key, false)
+}
+
+// boolParamDefault 解析布尔参数，参数为空时返回默认值
+func boolParamDefault(params url.Values, key string, def bool) (bool, error) {
+	raw := params.Get(key)
+	if raw == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return b, nil
+}
+
+// compileParam 将参数编译为正则，参数为空时返回 nil
+func compileParam(params url.Values, key string) (*regexp.Regexp, error) {
+	expr := params.Get(key)
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %v", key, err)
+	}
+	return re, nil
+}
+
+// maxParamsBody 为 /short、/jobs 请求体的大小上限
+const maxParamsBody = 64 << 10
+
+// bindParams 读取 POST 请求中的参数表：请求体不为空时按 Content-Type 解析为 JSON（字符串键值）或表单，
+// 否则使用查询参数。按实际读到的内容判断是否有请求体，分块传输（没有 Content-Length）的请求同样适用
+func bindParams(c *gin.Context) (url.Values, error) {
+	params := c.Request.URL.Query()
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return params, nil
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxParamsBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %v", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return params, nil
+	}
+
+	switch c.ContentType() {
+	case "application/x-www-form-urlencoded":
+		return url.ParseQuery(string(data))
+	case "", "application/json":
+		var body map[string]string
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("invalid json body: %v", err)
+		}
+		params = url.Values{}
+		for k, v := range body {
+			params.Set(k, v)
+		}
+		return params, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %q (want application/json or application/x-www-form-urlencoded)", c.ContentType())
+	}
+}
+
+// templateVars 合并配置中的 vars 与 ?var.NAME= 参数，请求参数优先。
+// viper 会将配置键转为小写，变量名因此统一按小写处理
+func templateVars(params url.Values) map[string]string {
+	vars := make(map[string]string, len(Global.Vars))
+	for k, v := range Global.Vars {
+		vars[strings.ToLower(k)] = v
+	}
+	for key := range params {
+		if name, ok := strings.CutPrefix(key, "var."); ok && name != "" {
+			vars[strings.ToLower(name)] = params.Get(key)
+		}
+	}
+	return vars
+}
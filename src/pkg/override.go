@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// vmessCiphers 是 Clash 支持的 vmess 加密方式
+var vmessCiphers = map[string]bool{
+	"auto":              true,
+	"none":              true,
+	"zero":              true,
+	"aes-128-gcm":       true,
+	"chacha20-poly1305": true,
+}
+
+func validateVmessCipher(cipher string) error {
+	if cipher == "" || vmessCiphers[cipher] {
+		return nil
+	}
+	return fmt.Errorf("invalid scv-cipher: %q", cipher)
+}
+
+func validatePacketEncoding(encoding string) error {
+	switch encoding {
+	case "", "packetaddr", "xudp":
+		return nil
+	}
+	return fmt.Errorf("invalid packet-encoding: %q (want packetaddr or xudp)", encoding)
+}
+
+// applyOverrides 将请求中的字段覆盖选项应用到每个节点
+func applyOverrides(proxies []clash.Proxy, opts ConvertOptions) {
+	for i := range proxies {
+		p := &proxies[i]
+		if p.Type == "vmess" {
+			if opts.Cipher != "" {
+				p.Cipher = opts.Cipher
+			}
+			if p.PacketEncoding == "" {
+				p.PacketEncoding = opts.PacketEncoding
+			}
+			p.GlobalPadding = p.GlobalPadding || opts.GlobalPadding
+			p.AuthenticatedLength = p.AuthenticatedLength || opts.AuthenticatedLength
+		}
+		p.TFO = p.TFO || opts.TFO
+		p.MPTCP = p.MPTCP || opts.MPTCP
+		if p.IPVersion == "" {
+			p.IPVersion = opts.IPVersion
+		}
+		applyRouting(p, opts)
+	}
+}
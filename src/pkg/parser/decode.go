@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	bom = "\ufeff"
+
+	// sniffLength 为判断内容格式时预读的字节数
+	sniffLength = 512
+	// maxNesting 为订阅内容 Base64 嵌套的最大层数
+	maxNesting = 3
+)
+
+// Decode 对订阅内容做 Base64 解码，得到每行一个的节点链接，规则同 NewDecoder
+func Decode(body []byte) ([]byte, error) {
+	return io.ReadAll(NewDecoder(bytes.NewReader(body)))
+}
+
+// NewDecoder 返回边读边解码订阅内容的 Reader。忽略 BOM、空白与换行，兼容缺少填充和 URL 安全字符的 Base64；
+// 内容已是明文链接时原样返回，多层 Base64 时逐层解开；内容是网页时读取返回 ErrHTMLPage
+func NewDecoder(r io.Reader) io.Reader {
+	br := bufio.NewReaderSize(r, sniffLength)
+	for depth := 0; ; depth++ {
+		skipBOM(br)
+		head, _ := br.Peek(sniffLength)
+		switch {
+		case isHTML(head):
+			return errReader{ErrHTMLPage}
+		case bytes.Contains(head, []byte("://")):
+			return br
+		case depth > 0 && !isBase64(head), depth == maxNesting:
+			// 解码结果既不是链接也不是 Base64，交给逐行解析报告
+			return br
+		}
+		br = bufio.NewReaderSize(&decodeReader{r: base64.NewDecoder(base64.RawStdEncoding, &base64Filter{r: br})}, sniffLength)
+	}
+}
+
+func skipBOM(br *bufio.Reader) {
+	if b, err := br.Peek(len(bom)); err == nil && string(b) == bom {
+		br.Discard(len(bom))
+	}
+}
+
+// isHTML 判断内容是否为网页，部分机场在 token 失效时返回 200 与登录页
+func isHTML(head []byte) bool {
+	s := strings.ToLower(string(bytes.TrimSpace(head)))
+	return strings.HasPrefix(s, "<!doctype html") || strings.HasPrefix(s, "<html") || strings.HasPrefix(s, "<head") || strings.HasPrefix(s, "<body")
+}
+
+// isBase64 判断内容是否只含（标准或 URL 安全的）Base64 字符与空白
+func isBase64(head []byte) bool {
+	if len(bytes.TrimSpace(head)) == 0 {
+		return false
+	}
+	for _, c := range head {
+		if base64Char(c) == 0 && !isBase64Ignored(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// base64Char 将 URL 安全字符换成标准字符，非 Base64 字符返回 0
+func base64Char(c byte) byte {
+	switch {
+	case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '/':
+		return c
+	case c == '-':
+		return '+'
+	case c == '_':
+		return '/'
+	}
+	return 0
+}
+
+// isBase64Ignored 判断解码时忽略的字符：空白、换行与填充
+func isBase64Ignored(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '='
+}
+
+// base64Filter 去掉空白与填充并将 URL 安全字符换成标准字符，供 RawStdEncoding 解码
+type base64Filter struct {
+	r io.Reader
+}
+
+func (f *base64Filter) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		n, err := f.r.Read(p)
+		j := 0
+		for _, c := range p[:n] {
+			if isBase64Ignored(c) {
+				continue
+			}
+			if std := base64Char(c); std != 0 {
+				c = std
+			}
+			p[j] = c
+			j++
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// decodeBase64 宽松地解码单个 Base64 字符串，规则同 NewDecoder
+func decodeBase64(s string) ([]byte, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isBase64Ignored(c) {
+			continue
+		}
+		if std := base64Char(c); std != 0 {
+			c = std
+		}
+		b.WriteByte(c)
+	}
+	return base64.RawStdEncoding.DecodeString(b.String())
+}
+
+type decodeReader struct {
+	r io.Reader
+}
+
+func (d *decodeReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = decodeError(err)
+	}
+	return n, err
+}
+
+func decodeError(err error) error {
+	return fmt.Errorf("failed to decode base64 subscription content: %v", err)
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
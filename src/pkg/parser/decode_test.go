@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// FuzzDecode 检查任意订阅内容都不会使解码 panic，逐字节读取与一次读取的结果一致，
+// 且明文链接与网页按原样识别。种子见 testdata/fuzz/FuzzDecode
+func FuzzDecode(f *testing.F) {
+	f.Fuzz(func(t *testing.T, body []byte) {
+		out, err := Decode(body)
+
+		streamed, streamErr := io.ReadAll(NewDecoder(iotest.OneByteReader(bytes.NewReader(body))))
+		if (err == nil) != (streamErr == nil) {
+			t.Fatalf("Decode error %v, streamed error %v", err, streamErr)
+		}
+		if err == nil && !bytes.Equal(out, streamed) {
+			t.Fatalf("Decode = %q, streamed = %q", out, streamed)
+		}
+
+		plain := bytes.TrimPrefix(body, []byte(bom))
+		head := plain[:min(len(plain), sniffLength)]
+		switch {
+		case isHTML(head):
+			if !errors.Is(err, ErrHTMLPage) {
+				t.Fatalf("html page: got error %v, want ErrHTMLPage", err)
+			}
+		case bytes.Contains(head, []byte("://")):
+			if err != nil || !bytes.Equal(out, plain) {
+				t.Fatalf("plain links changed: got %q, %v", out, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Node 为 Clash 配置中的一个代理项，字段覆盖生成 vmess / ss / trojan 分享链接所需的部分。
+// clash.Proxy 只描述 vmess，提取其他协议时需要 password、plugin 等字段
+type Node struct {
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`
+	Server     string `yaml:"server"`
+	Port       int    `yaml:"port"`
+	UUID       string `yaml:"uuid"`
+	AlterID    int    `yaml:"alterId"`
+	Cipher     string `yaml:"cipher"`
+	Password   string `yaml:"password"`
+	TLS        bool   `yaml:"tls"`
+	Network    string `yaml:"network"`
+	SkipCert   bool   `yaml:"skip-cert-verify"`
+	ServerName string `yaml:"servername"` // vmess 的 TLS SNI
+	SNI        string `yaml:"sni"`        // trojan 的 TLS SNI
+	TFO        bool   `yaml:"tfo"`
+	MPTCP      bool   `yaml:"mptcp"`
+	IPVersion  string `yaml:"ip-version"`
+
+	PacketEncoding      string `yaml:"packet-encoding"`
+	GlobalPadding       bool   `yaml:"global-padding"`
+	AuthenticatedLength bool   `yaml:"authenticated-length"`
+
+	Plugin     string                 `yaml:"plugin"`      // ss 插件：obfs / v2ray-plugin
+	PluginOpts map[string]interface{} `yaml:"plugin-opts"` // 插件参数，如 mode、host、path、tls
+
+	WSOpts   *clash.WSOptions   `yaml:"ws-opts"`
+	HTTPOpts *clash.HTTPOptions `yaml:"http-opts"`
+	H2Opts   *clash.H2Options   `yaml:"h2-opts"`
+	GRPCOpts *clash.GRPCOptions `yaml:"grpc-opts"`
+}
+
+// ShareLink 将代理项转换为分享链接，为 Parse 的逆过程：vmess 为 v2rayN 格式，ss 为 SIP002，trojan 为 trojan-go 常用的查询参数
+func ShareLink(n Node) (string, error) {
+	if n.Server == "" || n.Port <= 0 || n.Port > 65535 {
+		return "", fmt.Errorf("missing server or port")
+	}
+	switch strings.ToLower(n.Type) {
+	case "vmess":
+		return vmessLink(n)
+	case "ss":
+		return ssLink(n)
+	case "trojan":
+		return trojanLink(n)
+	}
+	return "", fmt.Errorf("unsupported type %q", n.Type)
+}
+
+// vmessLinkJSON 为 vmess:// 链接中的 JSON，字段与 VmessNode 对应
+type vmessLinkJSON struct {
+	V    string `json:"v"`
+	PS   string `json:"ps"`
+	Add  string `json:"add"`
+	Port string `json:"port"`
+	ID   string `json:"id"`
+	Aid  string `json:"aid"`
+	Scy  string `json:"scy,omitempty"`
+	Net  string `json:"net"`
+	Type string `json:"type"`
+	Host string `json:"host,omitempty"`
+	Path string `json:"path,omitempty"`
+	TLS  string `json:"tls,omitempty"`
+	SNI  string `json:"sni,omitempty"`
+
+	TFO   string `json:"tfo,omitempty"`
+	MPTCP string `json:"mptcp,omitempty"`
+
+	IPVersion string `json:"ip-version,omitempty"`
+
+	PacketEncoding      string `json:"packet-encoding,omitempty"`
+	GlobalPadding       string `json:"global-padding,omitempty"`
+	AuthenticatedLength string `json:"authenticated-length,omitempty"`
+}
+
+func vmessLink(n Node) (string, error) {
+	if n.UUID == "" {
+		return "", fmt.Errorf("missing uuid")
+	}
+	v := vmessLinkJSON{
+		V:    "2",
+		PS:   n.Name,
+		Add:  n.Server,
+		Port: strconv.Itoa(n.Port),
+		ID:   n.UUID,
+		Aid:  strconv.Itoa(n.AlterID),
+		Scy:  n.Cipher,
+		Net:  n.Network,
+		Type: "none",
+		SNI:  n.ServerName,
+
+		IPVersion:      n.IPVersion,
+		PacketEncoding: n.PacketEncoding,
+	}
+	if v.Net == "" {
+		v.Net = "tcp"
+	}
+	if n.TLS {
+		v.TLS = "tls"
+	}
+	if n.TFO {
+		v.TFO = "1"
+	}
+	if n.MPTCP {
+		v.MPTCP = "1"
+	}
+	if n.GlobalPadding {
+		v.GlobalPadding = "1"
+	}
+	if n.AuthenticatedLength {
+		v.AuthenticatedLength = "1"
+	}
+	switch n.Network {
+	case "ws":
+		if n.WSOpts != nil {
+			v.Path, v.Host = n.WSOpts.Path, n.WSOpts.Headers["Host"]
+		}
+	case "h2":
+		if n.H2Opts != nil {
+			v.Path = n.H2Opts.Path
+			if len(n.H2Opts.Host) > 0 {
+				v.Host = n.H2Opts.Host[0]
+			}
+		}
+	case "grpc":
+		if n.GRPCOpts != nil {
+			v.Path = n.GRPCOpts.ServiceName
+		}
+	case "http":
+		// Clash 的 network: http 即 v2rayN 中 tcp + http 伪装
+		v.Net, v.Type = "tcp", "http"
+		if n.HTTPOpts != nil {
+			if len(n.HTTPOpts.Path) > 0 {
+				v.Path = n.HTTPOpts.Path[0]
+			}
+			if hosts := n.HTTPOpts.Headers["Host"]; len(hosts) > 0 {
+				v.Host = hosts[0]
+			}
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+func ssLink(n Node) (string, error) {
+	if n.Cipher == "" || n.Password == "" {
+		return "", fmt.Errorf("missing cipher or password")
+	}
+	u := url.URL{
+		Scheme:   "ss",
+		User:     url.User(base64.RawURLEncoding.EncodeToString([]byte(n.Cipher + ":" + n.Password))),
+		Host:     net.JoinHostPort(n.Server, strconv.Itoa(n.Port)),
+		Fragment: n.Name,
+	}
+	if n.Plugin != "" {
+		plugin, err := ssPlugin(n.Plugin, n.PluginOpts)
+		if err != nil {
+			return "", err
+		}
+		u.RawQuery = url.Values{"plugin": {plugin}}.Encode()
+	}
+	return u.String(), nil
+}
+
+// ssPlugin 按 SIP003 格式拼接插件参数，如 obfs-local;obfs=http;obfs-host=example.com
+func ssPlugin(plugin string, pluginOpts map[string]interface{}) (string, error) {
+	opts := make(map[string]string, len(pluginOpts))
+	for k, v := range pluginOpts {
+		opts[k] = fmt.Sprint(v)
+	}
+	var parts []string
+	switch plugin {
+	case "obfs":
+		parts = append(parts, "obfs-local", "obfs="+opts["mode"])
+		if host := opts["host"]; host != "" {
+			parts = append(parts, "obfs-host="+host)
+		}
+	case "v2ray-plugin":
+		parts = append(parts, "v2ray-plugin")
+		if mode := opts["mode"]; mode != "" && mode != "websocket" {
+			parts = append(parts, "mode="+mode)
+		}
+		if host := opts["host"]; host != "" {
+			parts = append(parts, "host="+host)
+		}
+		if path := opts["path"]; path != "" {
+			parts = append(parts, "path="+path)
+		}
+		if opts["tls"] == "true" {
+			parts = append(parts, "tls")
+		}
+	default:
+		return "", fmt.Errorf("unsupported ss plugin %q", plugin)
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+func trojanLink(n Node) (string, error) {
+	if n.Password == "" {
+		return "", fmt.Errorf("missing password")
+	}
+	q := url.Values{}
+	if n.SNI != "" {
+		q.Set("sni", n.SNI)
+	}
+	if n.SkipCert {
+		q.Set("allowInsecure", "1")
+	}
+	switch n.Network {
+	case "ws":
+		q.Set("type", "ws")
+		if n.WSOpts != nil {
+			if n.WSOpts.Path != "" {
+				q.Set("path", n.WSOpts.Path)
+			}
+			if host := n.WSOpts.Headers["Host"]; host != "" {
+				q.Set("host", host)
+			}
+		}
+	case "grpc":
+		q.Set("type", "grpc")
+		if n.GRPCOpts != nil && n.GRPCOpts.ServiceName != "" {
+			q.Set("serviceName", n.GRPCOpts.ServiceName)
+		}
+	}
+	u := url.URL{
+		Scheme:   "trojan",
+		User:     url.User(n.Password),
+		Host:     net.JoinHostPort(n.Server, strconv.Itoa(n.Port)),
+		RawQuery: q.Encode(),
+		Fragment: n.Name,
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,111 @@
+// Package parser 将订阅内容（Base64 编码、每行一个节点链接）解析为 Clash 代理
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+var (
+	// ErrNoNodes 表示订阅中没有可解析的节点
+	ErrNoNodes = errors.New("no valid nodes found in the subscription")
+	// ErrHTMLPage 表示上游返回的是网页（如登录页、错误页）而不是订阅内容
+	ErrHTMLPage = errors.New("upstream returned an HTML page instead of a subscription")
+)
+
+// SkippedLink 记录解析时被跳过的节点链接及原因
+type SkippedLink struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// maxLinkLength 为单行节点链接的长度上限，超出的行整行跳过
+const maxLinkLength = 64 << 10
+
+// Parse 解码订阅内容并逐行解析节点链接，无法解析的链接连同原因一并返回。
+// 解码与解析同时进行，不会在内存中保留完整的解码结果
+func Parse(body []byte) ([]clash.Proxy, []SkippedLink, error) {
+	return ParseReader(NewDecoder(bytes.NewReader(body)))
+}
+
+// ParseLinks 逐行解析解码后的节点链接，每行交给已注册的 Parser 处理，没有可用节点时返回 ErrNoNodes
+func ParseLinks(decodedBody []byte) ([]clash.Proxy, []SkippedLink, error) {
+	return ParseReader(bytes.NewReader(decodedBody))
+}
+
+// ParseReader 从 r 逐行读取并解析解码后的节点链接，读取出错时返回该错误
+func ParseReader(r io.Reader) ([]clash.Proxy, []SkippedLink, error) {
+	var clashProxies []clash.Proxy
+	var skipped []SkippedLink
+
+	err := scanLines(r, func(line int, link string, tooLong bool) {
+		if tooLong {
+			skipped = append(skipped, SkippedLink{Line: line, Reason: fmt.Sprintf("link longer than %d bytes", maxLinkLength)})
+			return
+		}
+		link = strings.TrimSpace(strings.TrimPrefix(link, bom))
+		if link == "" {
+			return
+		}
+		proxy, err := ParseLink(link)
+		if err != nil {
+			skip := SkippedLink{Line: line, Reason: err.Error()}
+			var nodeErr *NodeError
+			if errors.As(err, &nodeErr) {
+				skip.Name = nodeErr.Name
+			}
+			skipped = append(skipped, skip)
+			return
+		}
+		clashProxies = append(clashProxies, proxy)
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	if len(clashProxies) == 0 {
+		return nil, skipped, ErrNoNodes
+	}
+
+	return clashProxies, skipped, nil
+}
+
+// scanLines 按行读取 r 并对每行调用 fn（行号从 1 开始）。超过 maxLinkLength 的行不读入内存，
+// 以 tooLong 通知调用方
+func scanLines(r io.Reader, fn func(line int, text string, tooLong bool)) error {
+	br := bufio.NewReaderSize(r, maxLinkLength)
+	for line := 1; ; line++ {
+		b, err := br.ReadSlice('\n')
+		tooLong := false
+		for err == bufio.ErrBufferFull {
+			// 丢弃该行剩余部分
+			tooLong = true
+			_, err = br.ReadSlice('\n')
+		}
+		if len(b) > 0 || tooLong {
+			fn(line, string(b), tooLong)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// scheme 返回链接的协议名，如 vmess；不像链接的行（如网页内容）返回 unknown
+func scheme(link string) string {
+	s, _, ok := strings.Cut(link, "://")
+	if !ok || s == "" || len(s) > 16 {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Parser 解析一种协议的节点链接。新增协议时在单独的文件中实现 Parser，并在 init 中调用 Register
+type Parser interface {
+	// CanParse 判断链接是否由该解析器处理，通常按 scheme 判断
+	CanParse(link string) bool
+	// Parse 将链接转换为 Clash 代理；链接已解码出节点名称但仍无法使用时返回 *NodeError
+	Parse(link string) (clash.Proxy, error)
+}
+
+// NodeError 表示已识别出节点名称的链接无法转换，名称会记录在 SkippedLink 中
+type NodeError struct {
+	Name string
+	Err  error
+}
+
+func (e *NodeError) Error() string { return e.Err.Error() }
+
+func (e *NodeError) Unwrap() error { return e.Err }
+
+var registry struct {
+	sync.RWMutex
+	parsers []Parser
+}
+
+// Register 注册解析器，按注册顺序匹配，第一个 CanParse 返回 true 的解析器负责该链接
+func Register(p Parser) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.parsers = append(registry.parsers, p)
+}
+
+// parserFor 返回处理该链接的解析器，没有时返回 nil
+func parserFor(link string) Parser {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, p := range registry.parsers {
+		if p.CanParse(link) {
+			return p
+		}
+	}
+	return nil
+}
+
+// ParseLink 使用已注册的解析器解析单个节点链接。解析器 panic 时返回错误，不影响其余链接
+func ParseLink(link string) (proxy clash.Proxy, err error) {
+	p := parserFor(link)
+	if p == nil {
+		return clash.Proxy{}, fmt.Errorf("unsupported protocol: %s", scheme(link))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			proxy, err = clash.Proxy{}, fmt.Errorf("%s parser failed: %v", scheme(link), r)
+		}
+	}()
+	return p.Parse(link)
+}
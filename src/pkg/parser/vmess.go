@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+func init() {
+	Register(vmessParser{})
+}
+
+// vmessParser 解析 v2rayN 格式的 vmess:// 链接（Base64 编码的 JSON）
+type vmessParser struct{}
+
+func (vmessParser) CanParse(link string) bool {
+	return strings.HasPrefix(link, "vmess://")
+}
+
+func (vmessParser) Parse(link string) (clash.Proxy, error) {
+	vmessBase64 := strings.TrimPrefix(link, "vmess://")
+	// 部分客户端导出的链接在末尾附带 #备注
+	if i := strings.IndexByte(vmessBase64, '#'); i >= 0 {
+		vmessBase64 = vmessBase64[:i]
+	}
+
+	vmessJSON, err := decodeBase64(vmessBase64)
+	if err != nil {
+		return clash.Proxy{}, fmt.Errorf("invalid vmess base64: %v", err)
+	}
+	vmessJSON = bytes.TrimPrefix(bytes.TrimSpace(vmessJSON), []byte(bom))
+
+	var node VmessNode
+	if err := json.Unmarshal(vmessJSON, &node); err != nil {
+		return clash.Proxy{}, fmt.Errorf("invalid vmess json: %v", err)
+	}
+
+	proxy, err := convertVmess(node)
+	if err != nil {
+		return clash.Proxy{}, &NodeError{Name: string(node.PS), Err: err}
+	}
+	return proxy, nil
+}
+
+// VmessNode 用于解析 vmess:// 链接解码后的 JSON
+// 各机场生成的 JSON 中数字与字符串常混用（如 "port": 443 与 "aid": "0"），两种写法均接受
+type VmessNode struct {
+	Add  string     `json:"add"`  // 地址
+	Aid  flexString `json:"aid"`  // alterId
+	Host string     `json:"host"` // 伪装域名
+	ID   string     `json:"id"`   // UUID
+	Net  string     `json:"net"`  // 网络类型 (ws, tcp)
+	Path string     `json:"path"` // WebSocket 路径
+	Port flexString `json:"port"` // 端口
+	PS   flexString `json:"ps"`   // 节点名称 (Remark)
+	Scy  string     `json:"scy"`  // 加密方式 (security)
+	TLS  flexString `json:"tls"`  // 是否启用 TLS
+	Type string     `json:"type"` // 伪装类型 (none, http)
+	V    flexString `json:"v"`    // 版本
+
+	// 部分机场附带的连接选项，取值为 1 / true
+	TFO   flexString `json:"tfo"`
+	MPTCP flexString `json:"mptcp"`
+
+	IPVersion string `json:"ip-version"` // 与 Clash 的 ip-version 取值相同，无法识别时忽略
+
+	PacketEncoding      string     `json:"packet-encoding"` // packetaddr / xudp，无法识别时忽略
+	GlobalPadding       flexString `json:"global-padding"`
+	AuthenticatedLength flexString `json:"authenticated-length"`
+}
+
+// flexString 接受 JSON 字符串、数字、布尔值与 null，统一保存为字符串
+type flexString string
+
+func (f *flexString) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		*f = ""
+	case string:
+		*f = flexString(v)
+	case float64:
+		*f = flexString(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		*f = flexString(strconv.FormatBool(v))
+	default:
+		return fmt.Errorf("unexpected value %s", b)
+	}
+	return nil
+}
+
+// ipVersions 为 Clash 节点 ip-version 字段的取值
+var ipVersions = map[string]bool{"dual": true, "ipv4": true, "ipv6": true, "ipv4-prefer": true, "ipv6-prefer": true}
+
+// enabled 判断开关字段是否开启
+func (f flexString) enabled() bool {
+	v := strings.ToLower(strings.TrimSpace(string(f)))
+	return v == "1" || v == "true"
+}
+
+// convertVmess 将 VmessNode 转换为 clash.Proxy
+func convertVmess(node VmessNode) (clash.Proxy, error) {
+	// add 可能是带方括号的 IPv6 地址，部分机场还会把端口写在括号后
+	server, linkPort := splitServer(strings.TrimSpace(node.Add))
+	if server == "" {
+		return clash.Proxy{}, errors.New("missing server address")
+	}
+	portStr := strings.TrimSpace(string(node.Port))
+	if portStr == "" {
+		portStr = linkPort
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return clash.Proxy{}, fmt.Errorf("invalid port: %s", portStr)
+	}
+	if strings.TrimSpace(node.ID) == "" {
+		return clash.Proxy{}, errors.New("missing uuid")
+	}
+	aid := 0
+	if s := strings.TrimSpace(string(node.Aid)); s != "" {
+		if aid, err = strconv.Atoi(s); err != nil || aid < 0 {
+			return clash.Proxy{}, fmt.Errorf("invalid alterId: %s", s)
+		}
+	}
+
+	cipher := node.Scy
+	if cipher == "" {
+		cipher = "auto" // Clash 会自动选择
+	}
+
+	proxy := clash.Proxy{
+		Name:     string(node.PS),
+		Type:     "vmess",
+		Server:   server,
+		Port:     port,
+		UUID:     strings.TrimSpace(node.ID),
+		AlterID:  aid,
+		Cipher:   cipher,
+		TLS:      node.TLS == "tls" || node.TLS == "true",
+		SkipCert: true, // 通常建议跳过证书验证
+		Network:  node.Net,
+		TFO:      node.TFO.enabled(),
+		MPTCP:    node.MPTCP.enabled(),
+
+		GlobalPadding:       node.GlobalPadding.enabled(),
+		AuthenticatedLength: node.AuthenticatedLength.enabled(),
+	}
+	if ipVersions[node.IPVersion] {
+		proxy.IPVersion = node.IPVersion
+	}
+	if node.PacketEncoding == "packetaddr" || node.PacketEncoding == "xudp" {
+		proxy.PacketEncoding = node.PacketEncoding
+	}
+
+	applyVmessTransport(&proxy, node)
+
+	return proxy, nil
+}
+
+// applyVmessTransport 按 vmess 链接的 net / type 字段填充传输层设置
+func applyVmessTransport(p *clash.Proxy, node VmessNode) {
+	switch node.Net {
+	case "ws":
+		p.WSOpts = &clash.WSOptions{Path: node.Path}
+		if node.Host != "" {
+			p.WSOpts.Headers = map[string]string{"Host": node.Host}
+		}
+	case "h2":
+		p.H2Opts = &clash.H2Options{Path: node.Path}
+		if node.Host != "" {
+			p.H2Opts.Host = []string{node.Host}
+		}
+	case "grpc":
+		// v2rayN 格式中 path 字段为 serviceName
+		p.GRPCOpts = &clash.GRPCOptions{ServiceName: node.Path}
+	case "", "tcp":
+		if node.Type != "http" {
+			return
+		}
+		path := node.Path
+		if path == "" {
+			path = "/"
+		}
+		p.Network = "http"
+		p.HTTPOpts = &clash.HTTPOptions{Method: "GET", Path: []string{path}}
+		if node.Host != "" {
+			p.HTTPOpts.Headers = map[string][]string{"Host": {node.Host}}
+		}
+	}
+}
+
+// splitServer 拆分节点地址中带方括号的 IPv6 写法，如 [2001:db8::1] 或 [2001:db8::1]:443，
+// 返回去掉括号的地址与其中的端口（没有时为空）
+func splitServer(addr string) (host, port string) {
+	if !strings.HasPrefix(addr, "[") {
+		return addr, ""
+	}
+	end := strings.Index(addr, "]")
+	if end < 0 {
+		return addr, ""
+	}
+	host, rest := addr[1:end], addr[end+1:]
+	if strings.HasPrefix(rest, ":") {
+		port = rest[1:]
+	}
+	return host, port
+}
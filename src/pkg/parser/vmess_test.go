@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzParseVmess 检查任意 vmess:// 链接都不会使解析 panic，解析成功的节点地址、端口与 UUID 均有效。
+// 种子见 testdata/fuzz/FuzzParseVmess
+func FuzzParseVmess(f *testing.F) {
+	f.Fuzz(func(t *testing.T, link string) {
+		if !strings.HasPrefix(link, "vmess://") {
+			link = "vmess://" + link
+		}
+		p, err := vmessParser{}.Parse(link)
+		if err != nil {
+			return
+		}
+		switch {
+		case p.Type != "vmess":
+			t.Fatalf("type = %q", p.Type)
+		case p.Server == "":
+			t.Fatal("empty server")
+		case p.Port < 1 || p.Port > 65535:
+			t.Fatalf("port = %d", p.Port)
+		case p.UUID == "" || strings.TrimSpace(p.UUID) != p.UUID:
+			t.Fatalf("uuid = %q", p.UUID)
+		case p.AlterID < 0:
+			t.Fatalf("alterId = %d", p.AlterID)
+		}
+	})
+}
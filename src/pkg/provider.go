@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/generator"
+)
+
+const (
+	defaultProviderInterval    = time.Hour
+	defaultHealthCheckURL      = "https://www.gstatic.com/generate_204"
+	defaultHealthCheckInterval = 5 * time.Minute
+)
+
+// providerAll 为包含全部节点的 proxy-provider 名称
+const providerAll = "subscription"
+
+// ProxyProviderConfig 为 proxy-provider 模式的设置。该模式下输出的配置不内联节点，分组通过 use 引用本服务的 /provider，
+// 客户端按 interval 单独更新节点列表，分组与规则随配置本身更新
+type ProxyProviderConfig struct {
+	Enable              bool          `mapstructure:"enable"`                // 是否默认使用 proxy-provider 模式（?proxy-provider=）
+	PublicURL           string        `mapstructure:"public-url"`            // 客户端访问本服务的地址，如 https://sub.example.com；为空时按请求推断，命令行转换与定时刷新时必须设置
+	Interval            time.Duration `mapstructure:"interval"`              // 客户端更新节点列表的间隔
+	HealthCheckURL      string        `mapstructure:"health-check-url"`      // 节点健康检查地址
+	HealthCheckInterval time.Duration `mapstructure:"health-check-interval"` // 健康检查间隔，0 表示不检查
+}
+
+// validate 检查 proxy-provider 设置并去掉 public-url 末尾的 /
+func (p *ProxyProviderConfig) validate() error {
+	if p.PublicURL != "" {
+		u, err := url.Parse(p.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid public-url: %q (want http(s)://host[/path])", p.PublicURL)
+		}
+		p.PublicURL = strings.TrimRight(p.PublicURL, "/")
+	}
+	if p.Interval < time.Second {
+		return fmt.Errorf("invalid interval: %s (want at least 1s)", p.Interval)
+	}
+	if p.HealthCheckInterval < 0 {
+		return fmt.Errorf("invalid health-check-interval: %s", p.HealthCheckInterval)
+	}
+	return nil
+}
+
+// providerOnlyParams 为只影响节点以外配置内容的参数，不传给 /provider
+var providerOnlyParams = map[string]bool{
+	"proxy-provider": true, "template": true, "config": true, "base": true, "rules": true, "inline-rules": true, "show-traffic": true,
+}
+
+// setProviderURL 在 proxy-provider 模式下按原始请求参数设置 /provider 地址。配置了 public-url 时使用该地址，
+// 否则使用 base（按请求推断的地址）；两者都为空时不设置，processConvert 随后报错
+func setProviderURL(opts *ConvertOptions, base string, query url.Values) {
+	if !opts.ProxyProvider {
+		return
+	}
+	if Global.ProxyProvider.PublicURL != "" {
+		base = Global.ProxyProvider.PublicURL
+	}
+	if base == "" {
+		return
+	}
+	params := make(url.Values, len(query))
+	for k, v := range query {
+		if !providerOnlyParams[k] && !strings.HasPrefix(k, "var.") {
+			params[k] = v
+		}
+	}
+	opts.ProviderURL = base + "/provider?" + params.Encode()
+}
+
+// errNoProviderURL 为 proxy-provider 模式下无法确定 /provider 地址时的错误
+var errNoProviderURL = errors.New("proxy-provider mode needs proxy-provider.public-url outside of HTTP requests")
+
+// useProxyProviders 将分组中的节点改为通过 use 引用 proxy-provider：包含全部节点的分组引用完整的节点列表，
+// 只包含部分节点的分组（如地区分组）引用按名称筛选的 provider，筛选相同的分组共用同一个 provider。
+// 筛选按分组的定义生成（模板占位符与地区的正则），节点增减或改名后客户端更新节点列表即可，无需重新下载配置；
+// 只有模板中直接写出的节点名称按名称精确匹配。筛选写在 provider 而非分组上，原版 Clash 也能加载
+func useProxyProviders(cfg *clash.Config, nodes []clash.Proxy, providerURL string) {
+	isNode := make(map[string]bool, len(nodes))
+	for _, p := range nodes {
+		isNode[p.Name] = true
+	}
+	settings := Global.ProxyProvider
+
+	providers := make(map[string]clash.ProxyProvider)
+	byFilter := make(map[string]string)
+	for i := range cfg.ProxyGroups {
+		g := &cfg.ProxyGroups[i]
+		var members, others []string
+		for _, name := range g.Proxies {
+			if isNode[name] {
+				members = append(members, name)
+			} else {
+				others = append(others, name)
+			}
+		}
+
+		filter, exclude, ok := groupFilter(g, members, len(nodes))
+		if !ok {
+			continue
+		}
+		key := filter + "\x00" + exclude
+		name, ok := byFilter[key]
+		if !ok {
+			name = providerAll
+			if key != "\x00" {
+				name = g.Name
+			}
+			for base, n := name, 2; providers[name].URL != ""; n++ {
+				name = fmt.Sprintf("%s-%d", base, n)
+			}
+			providers[name] = clash.ProxyProvider{
+				Type:          "http",
+				URL:           providerURL,
+				Path:          "./providers/" + cacheKey(providerURL, filter, exclude)[:16] + ".yaml",
+				Interval:      int(settings.Interval / time.Second),
+				Filter:        filter,
+				ExcludeFilter: exclude,
+				HealthCheck: clash.HealthCheck{
+					Enable:   settings.HealthCheckInterval > 0,
+					URL:      settings.HealthCheckURL,
+					Interval: int(settings.HealthCheckInterval / time.Second),
+				},
+			}
+			byFilter[key] = name
+		}
+
+		extra := make(map[string]interface{}, len(g.Extra)+1)
+		for k, v := range g.Extra {
+			extra[k] = v
+		}
+		if includesAll(*g) {
+			// include-all 分组改为引用 provider：配置中没有内联节点，原版 Clash 展开 include-all 时只会得到 DIRECT，
+			// mihomo 也会同时引入各地区的 provider 而出现重复节点
+			for _, k := range includeAllExtras {
+				delete(extra, k)
+			}
+			g.IncludeAll, g.ExcludeFilter = false, ""
+		}
+		use, _ := extra["use"].([]interface{})
+		extra["use"] = append(append([]interface{}(nil), use...), name)
+		g.Extra = extra
+		g.Proxies = others
+	}
+	cfg.ProxyProviders = providers
+}
+
+// includeAllExtras 为 include-all 分组引入节点时使用的 mihomo 字段，筛选移到 provider 后去掉
+var includeAllExtras = []string{"include-all-proxies", "include-all-providers", "filter"}
+
+// includesAll 判断分组是否由客户端引入全部节点（include-all、include-all-proxies 或 include-all-providers）
+func includesAll(g clash.ProxyGroup) bool {
+	return g.IncludeAll || g.Extra["include-all-proxies"] == true || g.Extra["include-all-providers"] == true
+}
+
+// groupFilter 返回分组引用的 provider 的 filter 与 exclude-filter，ok 为 false 时分组不含节点。
+// include-all 分组沿用其 filter / exclude-filter；模板占位符展开的分组使用占位符的正则，
+// 其余直接写出的节点名称按名称精确匹配；filter 为空表示全部节点
+func groupFilter(g *clash.ProxyGroup, members []string, total int) (filter, exclude string, ok bool) {
+	if includesAll(*g) {
+		filter, _ = g.Extra["filter"].(string)
+		return filter, g.ExcludeFilter, true
+	}
+	if len(g.NodeFilters) == 0 {
+		if len(members) == 0 {
+			return "", "", false
+		}
+		if len(members) < total {
+			filter = nameFilter(members)
+		}
+		return filter, "", true
+	}
+
+	var parts []string
+	var compiled []*regexp.Regexp
+	for _, expr := range g.NodeFilters {
+		if expr == "" {
+			// ${proxies}：全部节点
+			return "", "", true
+		}
+		parts = append(parts, "(?:"+expr+")")
+		if re, err := regexp.Compile(expr); err == nil {
+			compiled = append(compiled, re)
+		}
+	}
+	// 不被占位符正则匹配的节点（如模板中写出的名称，或 rename 去掉了地区关键词的地区分组成员）按名称精确匹配
+	var literal []string
+	for _, name := range members {
+		if !slices.ContainsFunc(compiled, func(re *regexp.Regexp) bool { return re.MatchString(name) }) {
+			literal = append(literal, name)
+		}
+	}
+	if len(literal) > 0 {
+		parts = append(parts, nameFilter(literal))
+	}
+	return strings.Join(parts, "|"), "", true
+}
+
+// nameFilter 返回只匹配这些节点名称的正则
+func nameFilter(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return "^(?:" + strings.Join(quoted, "|") + ")$"
+}
+
+// serveProvider 返回 /provider：转换并过滤后的节点列表，格式为 proxy-provider 的 proxies 列表。
+// 参数与 /config 相同，proxy-provider 模式生成的配置引用此地址，自备完整配置的用户也可直接将 proxy-provider 指向此地址
+func serveProvider(c *gin.Context) {
+	query := c.Request.URL.Query()
+	subURL, params, err := resolveSubscription(query)
+	if err != nil {
+		Usage.Record(query.Get("token"), 0, err)
+		c.Error(err)
+		return
+	}
+	opts, err := parseOptions(params)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+
+	data, nodes, err := providerCached(c.Request.Context(), subURL, params, opts)
+	if err == nil {
+		// 与 /config 相同，返回节点列表即计入 token 的使用次数并记录下载
+		err = spendToken(query.Get("token"))
+	}
+	Usage.Record(query.Get("token"), len(data), err)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	Audit.Record(c, query.Get("token"), auditSubscription(params, subURL), nodes)
+	setStaleHeader(c, subURL)
+	// mihomo 从 proxy-provider 的响应头读取剩余流量与到期时间
+	setTrafficHeaders(c, subURL, params, false)
+	c.Data(http.StatusOK, "application/x-yaml", data)
+}
+
+// providerCached 复用相同订阅与参数的节点列表，缓存时间与转换结果相同，同时返回节点数。客户端按 interval 定时拉取，
+// 多个客户端共用同一地址时不必每次都拉取上游
+func providerCached(ctx context.Context, subURL string, params url.Values, opts ConvertOptions) ([]byte, int, error) {
+	if !conversionCacheEnabled() {
+		return providerPayload(ctx, subURL, opts)
+	}
+	key := cacheKey("provider", subURL, params.Encode())
+	if data, nodes, ok := loadConversion(key); ok {
+		return data, nodes, nil
+	}
+	data, nodes, err := providerPayload(ctx, subURL, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	storeConversion(key, data, nodes)
+	return data, nodes, nil
+}
+
+// providerPayload 拉取节点并按目标能力表调整，返回节点列表与节点数，节点名称与 proxy-provider 模式配置中的筛选一致
+func providerPayload(ctx context.Context, subURL string, opts ConvertOptions) ([]byte, int, error) {
+	proxies, err := fetchProxies(ctx, subURL, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	gen, _ := generator.Lookup(opts.Target)
+	proxies, warnings := generator.Adapt(gen, proxies)
+	for _, w := range warnings {
+		warnf("Target %s: %s", gen.Target(), w)
+	}
+	if len(proxies) == 0 {
+		return nil, 0, ErrNoNodes
+	}
+	var buf bytes.Buffer
+	if err := clash.WriteProxies(&buf, proxies); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrTemplate, err)
+	}
+	return buf.Bytes(), len(proxies), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/generator"
+	"pkg/main.go/src/pkg/region"
+	"pkg/main.go/src/pkg/template"
+)
+
+const providerTestTemplate = `
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: ["${groups:region}", "${proxies}"]
+  - name: Netflix
+    type: select
+    proxies: ["${proxies:(?i)netflix}", "DIRECT"]
+  - name: Pinned
+    type: select
+    proxies: ["香港 01"]
+  - ${groups:region}
+`
+
+// renderProviderConfig 以模板渲染配置并改为引用 proxy-provider
+func renderProviderConfig(t *testing.T, text string, names ...string) clash.Config {
+	t.Helper()
+	Global = &Config{ProxyProvider: ProxyProviderConfig{Interval: defaultProviderInterval}}
+	nodes := make([]clash.Proxy, len(names))
+	for i, name := range names {
+		nodes[i] = clash.Proxy{Name: name, Type: "vmess", Server: "example.com", Port: 443}
+	}
+	region.Tag(nodes)
+
+	tmpl, err := template.Parse("test", []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := tmpl.Render(nodes, names, template.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	useProxyProviders(&cfg, nodes, "https://sub.example.com/provider?sub=work")
+	return cfg
+}
+
+// providerOf 返回分组通过 use 引用的 provider
+func providerOf(t *testing.T, cfg clash.Config, group string) clash.ProxyProvider {
+	t.Helper()
+	for _, g := range cfg.ProxyGroups {
+		if g.Name != group {
+			continue
+		}
+		use, _ := g.Extra["use"].([]interface{})
+		if len(use) != 1 {
+			t.Fatalf("group %s: use = %v, want one provider", group, use)
+		}
+		return cfg.ProxyProviders[use[0].(string)]
+	}
+	t.Fatalf("group %s not found", group)
+	return clash.ProxyProvider{}
+}
+
+// TestUseProxyProvidersFilters 检查 provider 的筛选来自分组的定义：生成配置之后新增或改名的节点仍能进入对应分组
+func TestUseProxyProvidersFilters(t *testing.T) {
+	cfg := renderProviderConfig(t, providerTestTemplate, "香港 01", "🇯🇵 Tokyo 02", "美国 Netflix")
+
+	tests := []struct {
+		group    string
+		match    []string // 之后新增的节点，应被筛选引入
+		reject   []string
+		wantAll  bool
+		wantRest []string // 分组中保留的非节点成员
+	}{
+		{group: "Proxy", wantAll: true, wantRest: []string{"🇭🇰 HK", "🇯🇵 JP", "🇺🇸 US"}},
+		{group: "🇭🇰 HK", match: []string{"香港 02", "🇭🇰 IPLC", "HK-03"}, reject: []string{"🇯🇵 Tokyo 02", "美国 Netflix"}},
+		{group: "🇯🇵 JP", match: []string{"日本 05", "🇯🇵 Osaka"}, reject: []string{"香港 01"}},
+		{group: "Netflix", match: []string{"新加坡 NETFLIX"}, reject: []string{"香港 01"}, wantRest: []string{"DIRECT"}},
+		{group: "Pinned", match: []string{"香港 01"}, reject: []string{"香港 02"}},
+	}
+	for _, tt := range tests {
+		p := providerOf(t, cfg, tt.group)
+		if got := p.Filter == ""; got != tt.wantAll {
+			t.Errorf("%s: filter = %q, want all nodes: %v", tt.group, p.Filter, tt.wantAll)
+			continue
+		}
+		re := regexp.MustCompile(p.Filter)
+		for _, name := range tt.match {
+			if !re.MatchString(name) {
+				t.Errorf("%s: filter %q does not match %q", tt.group, p.Filter, name)
+			}
+		}
+		for _, name := range tt.reject {
+			if re.MatchString(name) {
+				t.Errorf("%s: filter %q matches %q", tt.group, p.Filter, name)
+			}
+		}
+		for _, g := range cfg.ProxyGroups {
+			if g.Name == tt.group && !slices.Equal(g.Proxies, tt.wantRest) {
+				t.Errorf("%s: proxies = %v, want %v", tt.group, g.Proxies, tt.wantRest)
+			}
+		}
+	}
+}
+
+// TestUseProxyProvidersIncludeAllPremium 检查 include-all 分组在 proxy-provider 模式下输出为 clash-premium 时
+// 引用带有分组筛选的 provider，而不是按空的节点列表展开为 DIRECT
+func TestUseProxyProvidersIncludeAllPremium(t *testing.T) {
+	const text = `
+proxy-groups:
+  - name: Proxy
+    type: select
+    include-all: true
+  - name: Asia
+    type: url-test
+    include-all-proxies: true
+    filter: "香港|日本"
+    exclude-filter: "IPLC"
+    proxies: [DIRECT]
+`
+	cfg := renderProviderConfig(t, text, "香港 01", "日本 02", "美国 03")
+	gen, ok := generator.Lookup("clash-premium")
+	if !ok {
+		t.Fatal("clash-premium generator not registered")
+	}
+	// proxy-provider 模式不内联节点
+	data, err := gen.Generate(nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		ProxyProviders map[string]clash.ProxyProvider `yaml:"proxy-providers"`
+		ProxyGroups    []map[string]interface{}       `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		filter, exclude string
+		proxies         []interface{}
+	}{
+		"Proxy": {},
+		"Asia":  {filter: "香港|日本", exclude: "IPLC", proxies: []interface{}{"DIRECT"}},
+	}
+	for _, g := range out.ProxyGroups {
+		name := g["name"].(string)
+		w := want[name]
+		for _, k := range []string{"include-all", "include-all-proxies", "filter", "exclude-filter"} {
+			if _, ok := g[k]; ok {
+				t.Errorf("%s: %s left in group", name, k)
+			}
+		}
+		proxies, _ := g["proxies"].([]interface{})
+		if !slices.Equal(proxies, w.proxies) {
+			t.Errorf("%s: proxies = %v, want %v", name, proxies, w.proxies)
+		}
+		use, _ := g["use"].([]interface{})
+		if len(use) != 1 {
+			t.Errorf("%s: use = %v, want one provider", name, use)
+			continue
+		}
+		p, ok := out.ProxyProviders[use[0].(string)]
+		if !ok {
+			t.Errorf("%s: provider %v not defined", name, use[0])
+			continue
+		}
+		if p.Filter != w.filter || p.ExcludeFilter != w.exclude {
+			t.Errorf("%s: provider filter = %q / %q, want %q / %q", name, p.Filter, p.ExcludeFilter, w.filter, w.exclude)
+		}
+	}
+	if len(out.ProxyGroups) != len(want) {
+		t.Errorf("got %d groups, want %d", len(out.ProxyGroups), len(want))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+// serveQRCode 将转换地址渲染为 PNG 二维码，供手机客户端扫码导入。
+// 其余参数与 /config 相同；?id= 使用已有短链接，?short=true 先创建短链接再编码，?size= 为图片边长（像素）
+func serveQRCode(c *gin.Context) {
+	params := c.Request.URL.Query()
+	size := defaultQRSize
+	if raw := params.Get("size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < minQRSize || n > maxQRSize {
+			c.Error(badRequest(fmt.Errorf("invalid size: %q (want %d-%d)", raw, minQRSize, maxQRSize)))
+			return
+		}
+		size = n
+	}
+	short, err := boolParam(params, "short")
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+	id := params.Get("id")
+	for _, key := range []string{"size", "short", "id"} {
+		params.Del(key)
+	}
+
+	var target string
+	switch {
+	case id != "":
+		if _, err := ShortLinks.Get(id); err != nil {
+			c.Error(err)
+			return
+		}
+		target = requestBaseURL(c) + "/s/" + id
+	default:
+		// 与创建短链接相同，提前校验参数，避免生成无法使用的地址
+		if _, _, err := resolveSubscription(params); err != nil {
+			c.Error(err)
+			return
+		}
+		if _, err := parseOptions(params); err != nil {
+			c.Error(badRequest(err))
+			return
+		}
+		target = requestBaseURL(c) + "/config?" + params.Encode()
+		if short {
+			link, err := ShortLinks.Create(params)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			target = requestBaseURL(c) + "/s/" + link.ID
+		}
+	}
+
+	png, err := qrcode.Encode(target, qrcode.Medium, size)
+	if err != nil {
+		// 内容超出二维码容量时失败，通常是参数过长
+		c.Error(badRequest(fmt.Errorf("failed to encode qr code: %v (try short=true)", err)))
+		return
+	}
+	c.Header("X-Converter-URL", target)
+	c.Data(http.StatusOK, "image/png", png)
+}
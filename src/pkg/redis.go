@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "cct:"
+	redisTimeout   = time.Second
+)
+
+// redisCache 将缓存保存在 Redis 中，供负载均衡后的多个副本共享
+type redisCache struct {
+	client *redis.Client
+}
+
+// newRedisCache 连接 redis-url 指定的实例，如 redis://:password@127.0.0.1:6379/0
+func newRedisCache(rawURL string) (*redisCache, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("redis-url is required for redis cache")
+	}
+	opt, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis-url: %v", err)
+	}
+	if opt.DialTimeout == 0 {
+		// 缓存不可用时尽快按未命中处理，不拖慢转换
+		opt.DialTimeout = redisTimeout
+	}
+	rc := &redisCache{client: redis.NewClient(opt)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := rc.client.Ping(ctx).Err(); err != nil {
+		warnf("Warning: redis %s unreachable: %v", opt.Addr, err)
+	}
+	return rc, nil
+}
+
+func (rc *redisCache) Get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := rc.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			errorf("Error reading redis cache: %v", err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+func (rc *redisCache) Set(key string, value []byte, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := rc.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err(); err != nil {
+		errorf("Error writing redis cache: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// startRefresher 按 refresh-interval 定时拉取并转换所有命名订阅，预热转换缓存，
+// 客户端更新配置时可直接命中缓存；上游故障也能在客户端请求前通过 webhook 发现
+func startRefresher(interval time.Duration) {
+	go func() {
+		refreshSubscriptions()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refreshSubscriptions()
+		}
+	}()
+}
+
+// refreshSubscriptions 依次刷新运行时注册与配置文件中的订阅（同名时只刷新一次），以及 users 中每个用户的配置。
+// 用户订阅集中的订阅只能通过用户 token 访问，按用户请求的参数刷新，不再按名称单独转换
+func refreshSubscriptions() {
+	seen := make(map[string]bool)
+	for _, u := range Global.Users {
+		for _, name := range u.Subscriptions {
+			seen[name] = true
+		}
+	}
+	var names []string
+	for _, s := range Subscriptions.List() {
+		if !seen[s.Name] {
+			seen[s.Name] = true
+			names = append(names, s.Name)
+		}
+	}
+	for _, s := range Global.Subscriptions {
+		if !seen[s.Name] {
+			seen[s.Name] = true
+			names = append(names, s.Name)
+		}
+	}
+
+	for _, name := range names {
+		start := time.Now()
+		if err := refreshSubscription(name); err != nil {
+			warnf("Scheduled refresh of %s failed: %v", name, err)
+			continue
+		}
+		infof("Refreshed subscription %s in %s", name, time.Since(start).Round(time.Millisecond))
+	}
+	for i := range Global.Users {
+		u := &Global.Users[i]
+		label := u.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		start := time.Now()
+		if err := refreshUser(u); err != nil {
+			warnf("Scheduled refresh of user %s failed: %v", label, err)
+			continue
+		}
+		infof("Refreshed user %s in %s", label, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// refreshSubscription 以 /config/<name> 的参数完成一次转换并写入缓存，随后检查到期与流量提醒
+func refreshSubscription(name string) error {
+	query := url.Values{"sub": {name}}
+	subURL, params, err := resolveOperatorSubscription(query)
+	if err != nil {
+		return err
+	}
+	err = warmConversion(subURL, params, query)
+	// 上游暂时不可用时仍按上次记录的流量信息检查
+	checkAlerts(subURL)
+	return err
+}
+
+// refreshUser 以 /config?token=<用户 token> 的参数完成一次转换并写入缓存，与用户实际请求的缓存键相同；
+// 随后按订阅集中的每个订阅检查提醒
+func refreshUser(u *User) error {
+	query := url.Values{"token": {u.Token}}
+	subURL, params, err := resolveUser(u, query)
+	if err != nil {
+		return err
+	}
+	err = warmConversion(subURL, params, query)
+	for _, name := range u.Subscriptions {
+		if sub, lerr := lookupSubscription(name); lerr == nil {
+			checkAlerts(sub.URL)
+		}
+	}
+	return err
+}
+
+// warmConversion 完成一次转换并写入转换缓存，query 为对应的客户端请求参数，用于生成 /provider 地址
+func warmConversion(subURL string, params, query url.Values) error {
+	opts, err := parseOptions(params)
+	if err != nil {
+		return err
+	}
+	setProviderURL(&opts, "", query)
+	data, nodes, err := processConvert(context.Background(), subURL, opts)
+	if err != nil {
+		return err
+	}
+	if conversionCacheEnabled() {
+		storeConversion(conversionKey(subURL, params, opts), data, nodes)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package region 根据节点名称识别地区，并提供地区名称、旗帜 emoji 与排序
+package region
+
+import (
+	"regexp"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Region 描述一个可从节点名称识别的地区
+type Region struct {
+	Code    string // ISO 3166-1 alpha-2 代码
+	Name    string // 中文名称
+	pattern *regexp.Regexp
+}
+
+// regions 按常见程度排列，同时决定 sort=region 时的顺序
+var regions = []Region{
+	newRegion("HK", "香港", `香港|港|Hong\s*Kong`),
+	newRegion("TW", "台湾", `台湾|台灣|台北|Taiwan`),
+	newRegion("JP", "日本", `日本|东京|東京|大阪|Japan|Tokyo|Osaka`),
+	newRegion("SG", "新加坡", `新加坡|狮城|獅城|Singapore`),
+	newRegion("US", "美国", `美国|美國|洛杉矶|硅谷|纽约|西雅图|芝加哥|United\s*States|America|Los\s*Angeles`),
+	newRegion("KR", "韩国", `韩国|韓國|首尔|Korea|Seoul`),
+	newRegion("GB", "英国", `英国|英國|伦敦|United\s*Kingdom|London|\bUK\b`),
+	newRegion("DE", "德国", `德国|德國|法兰克福|Germany|Frankfurt`),
+	newRegion("FR", "法国", `法国|法國|巴黎|France|Paris`),
+	newRegion("NL", "荷兰", `荷兰|荷蘭|阿姆斯特丹|Netherlands|Amsterdam`),
+	newRegion("CA", "加拿大", `加拿大|Canada`),
+	newRegion("AU", "澳大利亚", `澳大利亚|澳洲|悉尼|Australia|Sydney`),
+	newRegion("RU", "俄罗斯", `俄罗斯|俄羅斯|莫斯科|Russia|Moscow`),
+	newRegion("IN", "印度", `印度|India`),
+	newRegion("TR", "土耳其", `土耳其|Turkey|Türkiye`),
+	newRegion("MY", "马来西亚", `马来西亚|馬來西亞|Malaysia`),
+	newRegion("TH", "泰国", `泰国|泰國|Thailand`),
+	newRegion("VN", "越南", `越南|Vietnam`),
+	newRegion("PH", "菲律宾", `菲律宾|菲律賓|Philippines`),
+	newRegion("ID", "印尼", `印尼|印度尼西亚|Indonesia`),
+	newRegion("AR", "阿根廷", `阿根廷|Argentina`),
+	newRegion("BR", "巴西", `巴西|Brazil`),
+}
+
+// newRegion 构造地区，名称中独立出现的两位代码（如 "HK-01"、"HK01"）也会被识别，"100GB" 这类流量信息除外
+func newRegion(code, name, expr string) Region {
+	return Region{
+		Code:    code,
+		Name:    name,
+		pattern: regexp.MustCompile(`(?i)` + expr + `|(^|[^A-Za-z0-9])` + code + `([^A-Za-z]|$)`),
+	}
+}
+
+// Detect 根据节点名称识别地区代码，优先使用名称中的旗帜 emoji；无法识别时返回空串
+func Detect(name string) string {
+	// 部分机场用 🇨🇳 标记港台节点，此时交由关键词判断
+	if code := flagCode(name); code != "" && !(code == "CN" && strings.ContainsAny(name, "港台")) {
+		return code
+	}
+	for _, r := range regions {
+		if r.pattern.MatchString(name) {
+			return r.Code
+		}
+	}
+	return ""
+}
+
+// Tag 按名称为每个节点识别地区
+func Tag(proxies []clash.Proxy) {
+	for i := range proxies {
+		proxies[i].Region = Detect(proxies[i].Name)
+	}
+}
+
+// ByCode 按代码查找地区
+func ByCode(code string) (Region, bool) {
+	for _, r := range regions {
+		if r.Code == code {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// Filter 返回匹配该地区节点名称的正则（旗帜 emoji 或名称关键词），与 Detect 的识别规则一致，
+// 供客户端按名称筛选；只由 GeoIP 识别出的地区返回其旗帜
+func Filter(code string) string {
+	expr := regexp.QuoteMeta(Flag(code))
+	if r, ok := ByCode(code); ok {
+		expr += "|" + r.pattern.String()
+	}
+	return expr
+}
+
+// Name 返回地区中文名，未知地区返回代码本身
+func Name(code string) string {
+	if r, ok := ByCode(code); ok {
+		return r.Name
+	}
+	return code
+}
+
+// Rank 返回地区在 regions 中的位置，未知地区排在最后
+func Rank(code string) int {
+	for i, r := range regions {
+		if r.Code == code {
+			return i
+		}
+	}
+	return len(regions)
+}
+
+// Flag 将两位地区代码转换为旗帜 emoji
+func Flag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	code = strings.ToUpper(code)
+	return string([]rune{0x1F1E6 + rune(code[0]-'A'), 0x1F1E6 + rune(code[1]-'A')})
+}
+
+// flagCode 提取名称中第一个旗帜 emoji 对应的地区代码
+func flagCode(name string) string {
+	runes := []rune(name)
+	for i := 0; i+1 < len(runes); i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			return string([]rune{'A' + runes[i] - 0x1F1E6, 'A' + runes[i+1] - 0x1F1E6})
+		}
+	}
+	return ""
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTemplateCacheTTL = 10 * time.Minute
+	remoteTemplateTimeout   = 10 * time.Second
+	remoteTemplateRetries   = 3
+	remoteTemplateMaxSize   = 4 << 20
+)
+
+// isRemoteTemplate 判断模板来源是否为 http/https 地址
+func isRemoteTemplate(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+type cachedTemplate struct {
+	data      []byte
+	fetchedAt time.Time
+}
+
+// RemoteTemplateCache 缓存远程模板；缓存过期后重新拉取，拉取失败时继续使用上一次成功的内容
+type RemoteTemplateCache struct {
+	mu      sync.Mutex
+	client  *http.Client
+	guarded *http.Client // 拉取使用者提供的地址，见 GetUserURL
+	entries map[string]*cachedTemplate
+}
+
+var remoteTemplates = &RemoteTemplateCache{
+	client:  &http.Client{Timeout: remoteTemplateTimeout},
+	entries: make(map[string]*cachedTemplate),
+}
+
+// Get 返回远程模板内容
+func (rc *RemoteTemplateCache) Get(src string) ([]byte, error) {
+	return rc.get(src, rc.client)
+}
+
+// GetUserURL 返回使用者提供的远程配置（?config=、?base=），地址先按 fetch-allow-hosts / fetch-deny-hosts 与 url-guard 检查
+func (rc *RemoteTemplateCache) GetUserURL(src string) ([]byte, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad url", ErrBlockedURL)
+	}
+	if err := checkFetchHost(u); err != nil {
+		return nil, err
+	}
+	if !urlGuardActive || rc.guarded == nil {
+		return rc.Get(src)
+	}
+	if err := checkUserURL(src); err != nil {
+		return nil, err
+	}
+	return rc.get(src, rc.guarded)
+}
+
+func (rc *RemoteTemplateCache) get(src string, client *http.Client) ([]byte, error) {
+	rc.mu.Lock()
+	entry := rc.entries[src]
+	rc.mu.Unlock()
+
+	ttl := Global.TemplateCacheTTL
+	if ttl <= 0 {
+		ttl = defaultTemplateCacheTTL
+	}
+	if entry != nil && time.Since(entry.fetchedAt) < ttl {
+		return entry.data, nil
+	}
+
+	data, err := rc.fetch(src, client)
+	if err != nil {
+		if entry != nil && !errors.Is(err, ErrBlockedURL) {
+			errorf("Error refreshing template %s: %v, using cached copy from %s", src, err, entry.fetchedAt.Format(time.RFC3339))
+			return entry.data, nil
+		}
+		return nil, err
+	}
+
+	rc.mu.Lock()
+	rc.entries[src] = &cachedTemplate{data: data, fetchedAt: time.Now()}
+	rc.mu.Unlock()
+	return data, nil
+}
+
+// fetch 拉取远程模板，失败时按递增间隔重试
+func (rc *RemoteTemplateCache) fetch(src string, client *http.Client) ([]byte, error) {
+	var lastErr error
+	for attempt := 1; attempt <= remoteTemplateRetries; attempt++ {
+		data, err := rc.fetchOnce(src, client)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+		if errors.Is(err, ErrBlockedURL) {
+			break
+		}
+		if attempt < remoteTemplateRetries {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+	}
+	if errors.Is(lastErr, ErrBlockedURL) {
+		return nil, fmt.Errorf("failed to fetch template %s: %w", src, lastErr)
+	}
+	// 超时、DNS 失败或非 200 状态，与订阅拉取失败同样返回 502
+	return nil, fmt.Errorf("%w: failed to fetch template %s: %v", ErrUpstream, src, lastErr)
+}
+
+func (rc *RemoteTemplateCache) fetchOnce(src string, client *http.Client) ([]byte, error) {
+	resp, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, remoteTemplateMaxSize))
+}
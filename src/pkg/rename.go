@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/region"
+)
+
+// renameToken 匹配 {name} 或 {name:spec} 形式的占位符，spec 为 printf 格式（不含 %），如 02d
+var renameToken = regexp.MustCompile(`\{([a-z_]+)(?::([^{}]*))?\}`)
+
+// renameFields 是重命名模板支持的占位符
+var renameFields = map[string]bool{
+	"name":         true, // 原始名称
+	"flag":         true, // 地区旗帜 emoji
+	"region":       true, // 地区代码，如 HK
+	"region_name":  true, // 地区中文名，如 香港
+	"country":      true, // GeoIP 国家代码
+	"index":        true, // 全局序号，从 1 开始
+	"region_index": true, // 地区内序号，从 1 开始
+	"type":         true, // 协议类型
+	"server":       true,
+	"port":         true,
+	"latency":      true, // 延迟毫秒数，需开启探测
+}
+
+// RenameTemplate 是编译后的节点重命名格式，如 "{flag}{region}-{index:02d}-{type}"
+type RenameTemplate struct {
+	format string
+}
+
+// compileRename 校验重命名格式中的占位符
+func compileRename(format string) (*RenameTemplate, error) {
+	for _, m := range renameToken.FindAllStringSubmatch(format, -1) {
+		if !renameFields[m[1]] {
+			return nil, fmt.Errorf("invalid rename: unknown field {%s}", m[1])
+		}
+	}
+	return &RenameTemplate{format: format}, nil
+}
+
+// Apply 按当前顺序为所有节点生成新名称
+func (t *RenameTemplate) Apply(proxies []clash.Proxy) {
+	regionCount := make(map[string]int)
+	for i := range proxies {
+		p := &proxies[i]
+		code := p.RegionCode()
+		regionCount[code]++
+
+		values := map[string]interface{}{
+			"name":         p.Name,
+			"flag":         region.Flag(code),
+			"region":       code,
+			"region_name":  region.Name(code),
+			"country":      p.Country,
+			"index":        i + 1,
+			"region_index": regionCount[code],
+			"type":         p.Type,
+			"server":       p.Server,
+			"port":         p.Port,
+			"latency":      latencyMillis(p.Latency),
+		}
+		p.Name = strings.TrimSpace(renameToken.ReplaceAllStringFunc(t.format, func(tok string) string {
+			m := renameToken.FindStringSubmatch(tok)
+			v := values[m[1]]
+			if m[2] == "" {
+				return fmt.Sprint(v)
+			}
+			return fmt.Sprintf("%"+m[2], v)
+		}))
+	}
+}
+
+// affixNames 为所有节点名称添加前缀与后缀
+func affixNames(proxies []clash.Proxy, prefix, suffix string) {
+	if prefix == "" && suffix == "" {
+		return
+	}
+	for i := range proxies {
+		proxies[i].Name = prefix + proxies[i].Name + suffix
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+const resolveTimeout = 3 * time.Second
+
+// ?ip-version= 的取值，与 Clash 的 ip-version 字段一致
+const (
+	ipVersionV4       = "ipv4"
+	ipVersionV6       = "ipv6"
+	ipVersionV4Prefer = "ipv4-prefer"
+	ipVersionV6Prefer = "ipv6-prefer"
+	ipVersionDual     = "dual" // 只写入节点，不解析也不过滤
+)
+
+// validateIPVersion 检查 ?ip-version= 的取值
+func validateIPVersion(v string) error {
+	switch v {
+	case "", ipVersionV4, ipVersionV6, ipVersionV4Prefer, ipVersionV6Prefer, ipVersionDual:
+		return nil
+	}
+	return fmt.Errorf("invalid ip-version: %q (want ipv4, ipv6, ipv4-prefer, ipv6-prefer or dual)", v)
+}
+
+// resolveServers 并发解析节点的服务器地址，同一域名只解析一次，按 version 选择地址族。
+// 返回 server -> IP 的映射，解析失败的域名不在结果中。
+func resolveServers(ctx context.Context, proxies []clash.Proxy, version string) map[string]net.IP {
+	var servers []string
+	seen := make(map[string]bool)
+	for _, p := range proxies {
+		if !seen[p.Server] {
+			seen[p.Server] = true
+			servers = append(servers, p.Server)
+		}
+	}
+
+	ips := make(map[string]net.IP)
+	var mu sync.Mutex
+	parallel(ctx, len(servers), func(i int) {
+		if ip := lookupIP(ctx, servers[i], version); ip != nil {
+			mu.Lock()
+			ips[servers[i]] = ip
+			mu.Unlock()
+		}
+	})
+	return ips
+}
+
+// lookupIP 解析域名，默认优先返回 IPv4 地址，version 为 ipv6 / ipv6-prefer 时优先 IPv6；
+// version 为 ipv4 / ipv6 时没有该地址族的地址则返回 nil。server 本身是 IP 时直接返回
+func lookupIP(ctx context.Context, server, version string) net.IP {
+	if ip := net.ParseIP(server); ip != nil {
+		return ip
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := dnsResolver.LookupIPAddr(ctx, server)
+	if err != nil || len(addrs) == 0 {
+		return nil
+	}
+	wantV6 := version == ipVersionV6 || version == ipVersionV6Prefer
+	for _, a := range addrs {
+		if (a.IP.To4() == nil) == wantV6 {
+			return a.IP
+		}
+	}
+	if version == ipVersionV4 || version == ipVersionV6 {
+		return nil
+	}
+	return addrs[0].IP
+}
+
+// filterIPVersion 在 ?ip-version=ipv4|ipv6 时丢弃没有对应地址族地址的节点
+func filterIPVersion(proxies []clash.Proxy, ips map[string]net.IP, version string) []clash.Proxy {
+	if version != ipVersionV4 && version != ipVersionV6 {
+		return proxies
+	}
+	kept := proxies[:0]
+	for _, p := range proxies {
+		ip, ok := ips[p.Server]
+		if ok && (ip.To4() == nil) == (version == ipVersionV6) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// applyResolved 将节点的域名替换为解析出的 IP，原域名写入 servername 与 ws/http 伪装的 Host 头，
+// 供本地 DNS 被污染的客户端直接连接
+func applyResolved(proxies []clash.Proxy, ips map[string]net.IP) {
+	for i := range proxies {
+		p := &proxies[i]
+		ip, ok := ips[p.Server]
+		if !ok || net.ParseIP(p.Server) != nil {
+			continue
+		}
+		host := p.Server
+		p.Server = ip.String()
+
+		if p.TLS && p.ServerName == "" {
+			p.ServerName = host
+		}
+		p.WithHost(host)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+const defaultResponseTimeout = 5 * time.Second
+
+// probeResponse 并发测量每个节点入口的响应时间，写入 Response。由于不运行代理内核，无法经节点下载测速，
+// 这里只测量入口本身：从建立连接、TLS 握手（TLS 节点）到对 ws 路径发出的 HTTP 请求收到首个字节或连接被关闭的耗时，
+// 反映入口的往返时间与负载，不代表节点带宽。guard 见 probeDialer
+func probeResponse(ctx context.Context, proxies []clash.Proxy, timeout time.Duration, guard bool) {
+	if timeout <= 0 {
+		timeout = defaultResponseTimeout
+	}
+	parallel(ctx, len(proxies), func(i int) {
+		proxies[i].Response = measureResponse(ctx, proxies[i], timeout, guard)
+	})
+}
+
+// measureResponse 返回单个节点入口的响应时间，超时或连接失败时返回 0
+func measureResponse(ctx context.Context, p clash.Proxy, timeout time.Duration, guard bool) time.Duration {
+	start := time.Now()
+	raw, err := probeDialer(timeout, guard).DialContext(ctx, "tcp", net.JoinHostPort(p.Server, strconv.Itoa(p.Port)))
+	if err != nil {
+		return 0
+	}
+	defer raw.Close()
+	raw.SetDeadline(start.Add(timeout))
+	// ctx 结束时关闭连接，中断握手与读取
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	defer stop()
+
+	host := p.Server
+	if p.ServerName != "" {
+		host = p.ServerName
+	}
+	path := "/"
+	if p.WSOpts != nil && p.WSOpts.Path != "" {
+		path = p.WSOpts.Path
+	}
+	if h := p.TransportHost(); h != "" {
+		host = h
+	}
+
+	conn := raw
+	if p.TLS {
+		tc := tls.Client(raw, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err := tc.Handshake(); err != nil {
+			return 0
+		}
+		conn = tc
+	}
+
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: Mozilla/5.0\r\nConnection: close\r\n\r\n", path, host)
+	// 收到任意响应或入口主动关闭连接都说明请求已被处理；超时与 ctx 结束按失败处理
+	n, err := conn.Read(make([]byte, 1))
+	if n == 0 && (err == nil || isTimeout(err) || ctx.Err() != nil) {
+		return 0
+	}
+	return max(time.Since(start), time.Millisecond)
+}
+
+// isTimeout 判断错误是否为读写超时
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// maxInterfaceName 为 Linux 网卡名称的最大长度（IFNAMSIZ - 1）
+const maxInterfaceName = 15
+
+// NodeRouting 为名称匹配的节点设置出站网卡与 routing-mark，按配置顺序取第一条匹配的规则
+type NodeRouting struct {
+	Match         string `mapstructure:"match"`          // 节点名称正则，按上游原始名称匹配
+	InterfaceName string `mapstructure:"interface-name"` // 为空时使用默认值
+	RoutingMark   int    `mapstructure:"routing-mark"`   // 为 0 时使用默认值
+
+	pattern *regexp.Regexp
+}
+
+// validateRouting 检查默认的出站设置并编译 node-routing 中的正则
+func validateRouting(iface string, mark int, rules []NodeRouting) error {
+	if err := validateInterfaceName(iface); err != nil {
+		return err
+	}
+	if err := validateRoutingMark(mark); err != nil {
+		return err
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Match == "" {
+			return fmt.Errorf("rule %d: missing match", i+1)
+		}
+		var err error
+		if r.pattern, err = regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("rule %d: invalid match: %v", i+1, err)
+		}
+		if err := validateInterfaceName(r.InterfaceName); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		if err := validateRoutingMark(r.RoutingMark); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateInterfaceName(name string) error {
+	if len(name) > maxInterfaceName || strings.ContainsAny(name, "/ \t\n") {
+		return fmt.Errorf("invalid interface-name: %q", name)
+	}
+	return nil
+}
+
+func validateRoutingMark(mark int) error {
+	if mark < 0 || mark > math.MaxUint32 {
+		return fmt.Errorf("invalid routing-mark: %d", mark)
+	}
+	return nil
+}
+
+// parseRoutingMark 解析十进制或 0x 开头的十六进制 routing-mark
+func parseRoutingMark(raw string) (int, error) {
+	mark, err := strconv.ParseInt(raw, 0, 64)
+	if err != nil || mark < 0 || mark > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid routing-mark: %q", raw)
+	}
+	return int(mark), nil
+}
+
+// applyRouting 为节点设置出站网卡与 routing-mark：node-routing 中匹配的规则优先，其次为请求或配置中的默认值。
+// 节点自身已有的设置（如 local-nodes 中的 Clash 节点）保持不变
+func applyRouting(p *clash.Proxy, opts ConvertOptions) {
+	iface, mark := opts.InterfaceName, opts.RoutingMark
+	for _, r := range Global.NodeRouting {
+		if r.pattern != nil && r.pattern.MatchString(p.Name) {
+			if r.InterfaceName != "" {
+				iface = r.InterfaceName
+			}
+			if r.RoutingMark != 0 {
+				mark = r.RoutingMark
+			}
+			break
+		}
+	}
+	if p.InterfaceName == "" {
+		p.InterfaceName = iface
+	}
+	if p.RoutingMark == 0 {
+		p.RoutingMark = mark
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// decodeRulesParam 解码 ?rules= 参数：base64（标准或 URL 安全，可省略填充）编码的规则列表，每行一条
+func decodeRulesParam(raw string) ([]string, error) {
+	var data []byte
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err = enc.DecodeString(raw); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: not base64")
+	}
+
+	var rules []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	return rules, nil
+}
+
+// validateCustomRule 检查自定义规则格式；MATCH 只能出现在模板末尾，不允许自定义
+func validateCustomRule(rule string) error {
+	parts := strings.Split(rule, ",")
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid rule: %q", rule)
+	}
+	if strings.EqualFold(strings.TrimSpace(parts[0]), "MATCH") {
+		return fmt.Errorf("invalid rule: %q, MATCH is not allowed in custom rules", rule)
+	}
+	return nil
+}
+
+// customRules 汇总请求与配置中的自定义规则，请求中的规则排在前面
+func customRules(raw string) ([]string, error) {
+	var rules []string
+	if raw != "" {
+		decoded, err := decodeRulesParam(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, decoded...)
+	}
+	rules = append(rules, Global.CustomRules...)
+
+	for _, rule := range rules {
+		if err := validateCustomRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// withCustomRules 将自定义规则插入到模板规则之前
+func withCustomRules(rules, custom []string) []string {
+	if len(custom) == 0 {
+		return rules
+	}
+	merged := make([]string, 0, len(custom)+len(rules))
+	merged = append(merged, custom...)
+	return append(merged, rules...)
+}
+
+// RuleCheck 为生成配置时规则检查的结果
+type RuleCheck struct {
+	Duplicates int      `json:"duplicates"`        // 移除的重复规则数
+	Invalid    []string `json:"invalid,omitempty"` // 移除的无效规则及原因
+}
+
+// checkRules 在模板、外部配置与自定义规则合并后去除重复规则，并移除 Clash 内核无法加载的规则；
+// 开启 strict-rules 时存在无效规则即返回错误，否则记录警告
+func checkRules(clashConfig *clash.Config) (RuleCheck, error) {
+	rules, duplicates, invalid := clashConfig.CheckRules()
+	check := RuleCheck{Duplicates: duplicates}
+	errs := make([]error, 0, len(invalid))
+	for _, e := range invalid {
+		check.Invalid = append(check.Invalid, e.Error())
+		errs = append(errs, e)
+	}
+	if len(invalid) > 0 && Global.StrictRules {
+		return check, errors.Join(errs...)
+	}
+	for _, e := range invalid {
+		warnf("Dropped %v", e)
+	}
+	if duplicates > 0 {
+		debugf("Dropped %d duplicate rules", duplicates)
+	}
+	clashConfig.Rules = rules
+	return check, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/generator"
+	"pkg/main.go/src/pkg/parser"
+)
+
+// selftestSamples 为各协议的样例链接，见 resources/selftest.yaml
+//
+//go:embed resources/selftest.yaml
+var selftestSamples []byte
+
+type selftestSample struct {
+	Name    string `yaml:"name"`
+	Link    string `yaml:"link"`
+	Type    string `yaml:"type"`
+	Server  string `yaml:"server"`
+	Port    int    `yaml:"port"`
+	Network string `yaml:"network"`
+	Error   string `yaml:"error"` // 不为空时该链接应被拒绝，且原因包含此文本
+}
+
+// SelftestReport 是 /selftest 返回的结果
+type SelftestReport struct {
+	OK         bool              `json:"ok"`
+	Parsers    []ParserReport    `json:"parsers"`
+	Generators []GeneratorReport `json:"generators"`
+}
+
+// ParserReport 汇总一种协议的样例结果
+type ParserReport struct {
+	Protocol string          `json:"protocol"`
+	Samples  int             `json:"samples"`
+	Passed   int             `json:"passed"`
+	Failures []SampleFailure `json:"failures,omitempty"`
+}
+
+// SampleFailure 记录未通过的样例
+type SampleFailure struct {
+	Sample string `json:"sample"`
+	Error  string `json:"error"`
+}
+
+// GeneratorReport 为一种输出格式的结果
+type GeneratorReport struct {
+	Target string `json:"target"`
+	OK     bool   `json:"ok"`
+	Bytes  int    `json:"bytes,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// selftest 以内置样例逐条检查解析器，再用解析出的节点与默认模板检查每种输出格式。任一项失败时返回 503
+func selftest(c *gin.Context) {
+	report, err := runSelftest()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	code := http.StatusOK
+	if !report.OK {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
+
+func runSelftest() (SelftestReport, error) {
+	var file struct {
+		Samples []selftestSample `yaml:"samples"`
+	}
+	if err := yaml.Unmarshal(selftestSamples, &file); err != nil {
+		return SelftestReport{}, fmt.Errorf("invalid selftest samples: %v", err)
+	}
+
+	report := SelftestReport{OK: true, Parsers: []ParserReport{}, Generators: []GeneratorReport{}}
+	byProtocol := make(map[string]int)
+	var proxies []clash.Proxy
+	for _, s := range file.Samples {
+		i, ok := byProtocol[s.Type]
+		if !ok {
+			i = len(report.Parsers)
+			byProtocol[s.Type] = i
+			report.Parsers = append(report.Parsers, ParserReport{Protocol: s.Type})
+		}
+		pr := &report.Parsers[i]
+		pr.Samples++
+
+		proxy, err := checkSample(s)
+		if err != nil {
+			pr.Failures = append(pr.Failures, SampleFailure{Sample: s.Name, Error: err.Error()})
+			report.OK = false
+			continue
+		}
+		pr.Passed++
+		if s.Error == "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	names := make([]string, 0, len(proxies))
+	for _, p := range proxies {
+		names = append(names, p.Name)
+	}
+	cfg := renderClashConfig(proxies, names, "", nil)
+	seen := make(map[string]bool)
+	for _, target := range generator.Targets() {
+		gen, _ := generator.Lookup(target)
+		if seen[gen.Target()] {
+			continue
+		}
+		seen[gen.Target()] = true
+
+		gr := GeneratorReport{Target: gen.Target()}
+		data, err := gen.Generate(proxies, cfg)
+		if err == nil {
+			err = checkGenerated(data, names)
+		}
+		if err != nil {
+			gr.Error = err.Error()
+			report.OK = false
+		} else {
+			gr.OK, gr.Bytes = true, len(data)
+		}
+		report.Generators = append(report.Generators, gr)
+	}
+	return report, nil
+}
+
+// checkSample 解析样例链接并与期望结果比较
+func checkSample(s selftestSample) (clash.Proxy, error) {
+	proxy, err := parser.ParseLink(s.Link)
+	if s.Error != "" {
+		if err == nil {
+			return clash.Proxy{}, fmt.Errorf("accepted, want error containing %q", s.Error)
+		}
+		if !strings.Contains(err.Error(), s.Error) {
+			return clash.Proxy{}, fmt.Errorf("rejected with %q, want error containing %q", err, s.Error)
+		}
+		return clash.Proxy{}, nil
+	}
+	if err != nil {
+		return clash.Proxy{}, err
+	}
+
+	var diffs []string
+	if proxy.Type != s.Type {
+		diffs = append(diffs, fmt.Sprintf("type %q, want %q", proxy.Type, s.Type))
+	}
+	if proxy.Server != s.Server {
+		diffs = append(diffs, fmt.Sprintf("server %q, want %q", proxy.Server, s.Server))
+	}
+	if proxy.Port != s.Port {
+		diffs = append(diffs, fmt.Sprintf("port %d, want %d", proxy.Port, s.Port))
+	}
+	if network := proxy.Network; network != s.Network && !(network == "" && s.Network == "tcp") {
+		diffs = append(diffs, fmt.Sprintf("network %q, want %q", network, s.Network))
+	}
+	if len(diffs) > 0 {
+		return clash.Proxy{}, errors.New(strings.Join(diffs, ", "))
+	}
+	return proxy, nil
+}
+
+// checkGenerated 检查生成的配置包含所有节点
+func checkGenerated(data []byte, names []string) error {
+	if len(data) == 0 {
+		return errors.New("empty output")
+	}
+	for _, name := range names {
+		if !strings.Contains(string(data), name) {
+			return fmt.Errorf("node %q missing from output", name)
+		}
+	}
+	return nil
+}
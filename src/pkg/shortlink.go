@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrShortLinkNotFound = errors.New("short link not found")
+
+const (
+	shortLinksKind = "shortlinks"
+
+	shortIDAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	shortIDLength   = 8
+
+	defaultMaxShortLinks = 10000
+)
+
+// ShortLink 保存一组完整的转换参数
+type ShortLink struct {
+	ID        string     `json:"id"`
+	Params    url.Values `json:"params"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ShortLinkStore 管理短链接并持久化到存储后端
+type ShortLinkStore struct {
+	mu      sync.RWMutex
+	backend Backend
+	max     int // 短链接数量上限，0 表示不限制
+	links   map[string]*ShortLink
+}
+
+// ShortLinks 是全局短链接存储，在 main 中初始化
+var ShortLinks *ShortLinkStore
+
+// NewShortLinkStore 从存储后端加载短链接，max 为数量上限，0 表示不限制
+func NewShortLinkStore(backend Backend, max int) (*ShortLinkStore, error) {
+	st := &ShortLinkStore{
+		backend: backend,
+		max:     max,
+		links:   make(map[string]*ShortLink),
+	}
+
+	var list []*ShortLink
+	if err := backend.Load(shortLinksKind, &list); err != nil {
+		return nil, err
+	}
+	for _, l := range list {
+		st.links[l.ID] = l
+	}
+	return st, nil
+}
+
+// Create 保存参数并分配新的随机 ID，数量达到上限时返回 ErrLimitReached
+func (st *ShortLinkStore) Create(params url.Values) (ShortLink, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.max > 0 && len(st.links) >= st.max {
+		return ShortLink{}, fmt.Errorf("%w: %d short links stored (max-short-links)", ErrLimitReached, st.max)
+	}
+
+	var id string
+	for {
+		var err error
+		if id, err = randomID(shortIDLength); err != nil {
+			return ShortLink{}, err
+		}
+		if _, ok := st.links[id]; !ok {
+			break
+		}
+	}
+
+	link := &ShortLink{ID: id, Params: params, CreatedAt: time.Now()}
+	st.links[id] = link
+	if err := st.saveLocked(); err != nil {
+		delete(st.links, id)
+		return ShortLink{}, err
+	}
+	return *link, nil
+}
+
+// Get 按 ID 查找短链接，返回的参数为副本
+func (st *ShortLinkStore) Get(id string) (ShortLink, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	l, ok := st.links[id]
+	if !ok {
+		return ShortLink{}, ErrShortLinkNotFound
+	}
+	link := *l
+	link.Params = make(url.Values, len(l.Params))
+	for k, v := range l.Params {
+		link.Params[k] = append([]string(nil), v...)
+	}
+	return link, nil
+}
+
+// saveLocked 将短链接写回存储后端，调用方需持有写锁
+func (st *ShortLinkStore) saveLocked() error {
+	list := make([]*ShortLink, 0, len(st.links))
+	for _, l := range st.links {
+		list = append(list, l)
+	}
+	return st.backend.Save(shortLinksKind, list)
+}
+
+// randomID 生成指定长度的随机 ID，字母表中去掉了易混淆的字符
+func randomID(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(shortIDAlphabet)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = shortIDAlphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// createShortLink 接收 JSON 参数表（或查询参数）并返回短链接地址
+func createShortLink(c *gin.Context) {
+	params, err := bindParams(c)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+
+	// 提前校验参数，避免保存无法使用的短链接
+	if _, _, err := resolveSubscription(params); err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := parseOptions(params); err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+
+	link, err := ShortLinks.Create(params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"id":  link.ID,
+		"url": requestBaseURL(c) + "/s/" + link.ID,
+	})
+}
+
+// serveShortLink 使用短链接保存的参数返回转换结果
+func serveShortLink(c *gin.Context) {
+	link, err := ShortLinks.Get(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	serveConfig(c, link.Params)
+}
+
+// requestBaseURL 根据请求推断服务的外部访问地址，兼容反向代理的 X-Forwarded-* 头
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := c.Request.Host
+	if fwd := c.GetHeader("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host
+}
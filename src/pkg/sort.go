@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/region"
+)
+
+// 支持的 ?sort= 取值
+const (
+	SortNone     = "none"
+	SortName     = "name"
+	SortRegion   = "region"
+	SortLatency  = "latency"
+	SortResponse = "response"
+)
+
+func validateSort(key string) error {
+	switch key {
+	case "", SortNone, SortName, SortRegion, SortLatency, SortResponse:
+		return nil
+	}
+	return fmt.Errorf("invalid sort: %q (want name, region, latency, response or none)", key)
+}
+
+// sortProxies 按指定方式对节点稳定排序，分组成员顺序随之变化
+func sortProxies(proxies []clash.Proxy, key string) {
+	switch key {
+	case SortName:
+		sort.SliceStable(proxies, func(i, j int) bool { return proxies[i].Name < proxies[j].Name })
+	case SortRegion:
+		sort.SliceStable(proxies, func(i, j int) bool {
+			return region.Rank(proxies[i].RegionCode()) < region.Rank(proxies[j].RegionCode())
+		})
+	case SortLatency:
+		// 需先经过 probeLatency，不可达节点（Latency 为 0）排在最后
+		sort.SliceStable(proxies, func(i, j int) bool {
+			return fasterThan(proxies[i].Latency, proxies[j].Latency)
+		})
+	case SortResponse:
+		// 需先经过 probeResponse，入口响应时间从短到长，失败的节点排在最后
+		sort.SliceStable(proxies, func(i, j int) bool {
+			return fasterThan(proxies[i].Response, proxies[j].Response)
+		})
+	}
+}
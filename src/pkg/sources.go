@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/parser"
+)
+
+// sourceSeparator 分隔同一订阅中的多个上游地址，如 ?url=https://a/sub|https://b/sub
+const sourceSeparator = "|"
+
+// maxSourceWorkers 为同时拉取的来源数上限
+const maxSourceWorkers = 8
+
+// splitSources 将订阅地址拆分为各个来源，单个地址时返回只含其本身的切片
+func splitSources(subURL string) []string {
+	if !strings.Contains(subURL, sourceSeparator) {
+		return []string{subURL}
+	}
+	var srcs []string
+	for _, s := range strings.Split(subURL, sourceSeparator) {
+		if s = strings.TrimSpace(s); s != "" {
+			srcs = append(srcs, s)
+		}
+	}
+	return srcs
+}
+
+// sourceResult 为单个来源的拉取与解析结果
+type sourceResult struct {
+	URL     string
+	Proxies []clash.Proxy
+	Skipped []parser.SkippedLink
+	Err     error
+}
+
+// SourceReport 概述多源订阅中单个来源的结果
+type SourceReport struct {
+	Source string `json:"source"`
+	Nodes  int    `json:"nodes"`
+	Error  string `json:"error,omitempty"`
+}
+
+// collectSources 并发处理订阅中的各个来源，每个来源受 source-timeout 限制，结果按来源顺序返回。
+// 单个来源失败不影响其他来源
+func collectSources(ctx context.Context, subURL string, load func(ctx context.Context, src string) sourceResult) []sourceResult {
+	srcs := splitSources(subURL)
+	results := make([]sourceResult, len(srcs))
+
+	var g errgroup.Group
+	g.SetLimit(maxSourceWorkers)
+	for i, src := range srcs {
+		g.Go(func() error {
+			sctx, cancel := sourceContext(ctx)
+			defer cancel()
+			results[i] = load(sctx, src)
+			results[i].URL = src
+			return nil
+		})
+	}
+	g.Wait()
+	return results
+}
+
+// sourceContext 为单个来源设置 source-timeout 超时
+func sourceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if Global.SourceTimeout > 0 {
+		return context.WithTimeout(ctx, Global.SourceTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// mergeSources 按来源顺序合并节点。部分来源失败时记录警告并返回其余来源的节点，
+// 全部失败时返回各来源的错误；只有一个来源时原样返回其错误
+func mergeSources(ctx context.Context, results []sourceResult) ([]clash.Proxy, error) {
+	if len(results) == 1 {
+		return results[0].Proxies, results[0].Err
+	}
+
+	var proxies []clash.Proxy
+	var errs []error
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("source %d (%s): %w", i+1, subscriptionLabel(r.URL), r.Err))
+			continue
+		}
+		proxies = append(proxies, r.Proxies...)
+	}
+	if len(errs) == len(results) {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		warnf("Skipped failed %v", err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("sources", len(results)), attribute.Int("sources.failed", len(errs)))
+	return proxies, nil
+}
+
+// sourceReports 生成各来源的结果概述
+func sourceReports(results []sourceResult) []SourceReport {
+	reports := make([]SourceReport, 0, len(results))
+	for _, r := range results {
+		report := SourceReport{Source: subscriptionLabel(r.URL), Nodes: len(r.Proxies)}
+		if r.Err != nil {
+			report.Error = r.Err.Error()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// staleSince 返回各来源中最早开始使用缓存内容的时间
+func staleSince(subURL string) (time.Time, bool) {
+	var oldest time.Time
+	found := false
+	for _, src := range splitSources(subURL) {
+		if at, ok := upstreamCache.StaleSince(src); ok && (!found || at.Before(oldest)) {
+			oldest, found = at, true
+		}
+	}
+	return oldest, found
+}
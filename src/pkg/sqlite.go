@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+const defaultDatabaseFile = "clash-convert.db"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	kind TEXT NOT NULL,
+	seq  INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (kind, seq)
+)`
+
+// sqliteBackend 将记录保存在嵌入式 SQLite 数据库中，每条记录一行 JSON
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+// newSQLiteBackend 打开（必要时创建）数据库文件并初始化表结构
+func newSQLiteBackend(path string) (*sqliteBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %v", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	// SQLite 同一时刻只允许一个写入者
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init %s: %v", path, err)
+	}
+	return &sqliteBackend{db: db}, nil
+}
+
+func (b *sqliteBackend) Load(kind string, v interface{}) error {
+	rows, err := b.db.Query(`SELECT data FROM records WHERE kind = ? ORDER BY seq`, kind)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %v", kind, err)
+	}
+	defer rows.Close()
+
+	var list []json.RawMessage
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("failed to load %s: %v", kind, err)
+		}
+		list = append(list, json.RawMessage(data))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load %s: %v", kind, err)
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", kind, err)
+	}
+	return nil
+}
+
+// Save 按序号写入记录：内容未变的行不重写，多出的旧记录删除
+func (b *sqliteBackend) Save(kind string, v interface{}) error {
+	list, err := marshalRecords(kind, v)
+	if err != nil {
+		return err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	defer tx.Rollback()
+
+	for i, item := range list {
+		if _, err := tx.Exec(`INSERT INTO records (kind, seq, data) VALUES (?, ?, ?)
+			ON CONFLICT (kind, seq) DO UPDATE SET data = excluded.data WHERE data <> excluded.data`, kind, i, string(item)); err != nil {
+			return fmt.Errorf("failed to save %s: %v", kind, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM records WHERE kind = ? AND seq >= ?`, kind, len(list)); err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	return nil
+}
+
+// Append 以递增的序号插入新记录，再删除最近 keep 条以前的记录
+func (b *sqliteBackend) Append(kind string, v interface{}, keep int) error {
+	list, err := marshalRecords(kind, v)
+	if err != nil {
+		return err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	defer tx.Rollback()
+
+	var next int64
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(seq) + 1, 0) FROM records WHERE kind = ?`, kind).Scan(&next); err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	for i, item := range list {
+		if _, err := tx.Exec(`INSERT INTO records (kind, seq, data) VALUES (?, ?, ?)`, kind, next+int64(i), string(item)); err != nil {
+			return fmt.Errorf("failed to save %s: %v", kind, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM records WHERE kind = ? AND seq < ?`, kind, next+int64(len(list))-int64(keep)); err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save %s: %v", kind, err)
+	}
+	return nil
+}
+
+// marshalRecords 将切片编码为逐条的 JSON
+func marshalRecords(kind string, v interface{}) ([]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %v", kind, err)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %v", kind, err)
+	}
+	return list, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Backend 持久化命名的记录列表（订阅、短链接、token、转换历史），各存储在修改后整体写回
+type Backend interface {
+	// Load 将 kind 对应的列表读入 v（切片指针），不存在时 v 保持不变
+	Load(kind string, v interface{}) error
+	// Save 用 v 替换 kind 对应的全部记录
+	Save(kind string, v interface{}) error
+}
+
+// Appender 为可以只追加新记录的后端，下载记录等只增不改的列表不必每次整体写回
+type Appender interface {
+	// Append 在 kind 对应的记录末尾追加 v（切片）中的条目，并只保留最近的 keep 条
+	Append(kind string, v interface{}, keep int) error
+}
+
+// store 是全局持久化后端，在 main 中按配置初始化
+var store Backend
+
+// newBackend 按配置创建持久化后端：json（默认，每类记录一个文件）或 sqlite
+func newBackend(cfg *Config) (Backend, error) {
+	switch cfg.Storage {
+	case "", "json":
+		return jsonBackend{dir: cfg.DataDir}, nil
+	case "sqlite":
+		path := cfg.Database
+		if path == "" {
+			path = filepath.Join(cfg.DataDir, defaultDatabaseFile)
+		}
+		return newSQLiteBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+	}
+}
+
+// jsonBackend 将每类记录保存为 data-dir 下的 <kind>.json
+type jsonBackend struct {
+	dir string
+}
+
+func (b jsonBackend) Load(kind string, v interface{}) error {
+	_, err := loadJSONFile(filepath.Join(b.dir, kind+".json"), v)
+	return err
+}
+
+func (b jsonBackend) Save(kind string, v interface{}) error {
+	return saveJSONFile(filepath.Join(b.dir, kind+".json"), v)
+}
+
+// loadJSONFile 读取 JSON 文件到 v，文件不存在时返回 false 且不报错
+func loadJSONFile(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// saveJSONFile 将 v 写入 JSON 文件。先写临时文件再重命名，避免写入中断导致文件损坏
+func saveJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", path, err)
+	}
+	return saveFile(path, data)
+}
+
+// saveFile 原子地写入文件：先写临时文件再重命名
+func saveFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data dir: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
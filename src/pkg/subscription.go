@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrSubscriptionExists   = errors.New("subscription already exists")
+	ErrInvalidSubscription  = errors.New("invalid subscription")
+
+	subscriptionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// Subscription 代表一个运行时注册的上游订阅
+type Subscription struct {
+	Name      string            `json:"name" mapstructure:"name"`
+	URL       string            `json:"url" mapstructure:"url"`
+	Options   map[string]string `json:"options,omitempty" mapstructure:"options"` // 默认查询参数，请求中的同名参数优先
+	Fetch     *FetchOptions     `json:"fetch,omitempty" mapstructure:"fetch"`     // 上游拉取设置（请求头、UA、认证）
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Validate 检查订阅名称与地址是否合法
+func (s *Subscription) Validate() error {
+	if !subscriptionNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("%w: bad name %q", ErrInvalidSubscription, s.Name)
+	}
+	if s.Fetch != nil {
+		if m := strings.ToUpper(s.Fetch.Method); m != "" && m != "GET" && m != "POST" {
+			return fmt.Errorf("%w: bad fetch method %q", ErrInvalidSubscription, s.Fetch.Method)
+		}
+	}
+	return validateSubscriptionURL(s.URL)
+}
+
+// validateSubscriptionURL 检查订阅地址为 http/https 绝对地址，多个来源以 | 分隔时逐个检查
+func validateSubscriptionURL(raw string) error {
+	srcs := splitSources(raw)
+	if len(srcs) == 0 {
+		return fmt.Errorf("%w: bad url %q", ErrInvalidSubscription, raw)
+	}
+	for _, src := range srcs {
+		u, err := url.Parse(src)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: bad url %q", ErrInvalidSubscription, src)
+		}
+	}
+	return nil
+}
+
+const subscriptionsKind = "subscriptions"
+
+// SubscriptionStore 管理订阅列表并持久化到存储后端
+type SubscriptionStore struct {
+	mu      sync.RWMutex
+	backend Backend
+	subs    map[string]*Subscription
+}
+
+// Subscriptions 是全局订阅存储，在 main 中初始化
+var Subscriptions *SubscriptionStore
+
+// NewSubscriptionStore 从存储后端加载订阅列表
+func NewSubscriptionStore(backend Backend) (*SubscriptionStore, error) {
+	st := &SubscriptionStore{
+		backend: backend,
+		subs:    make(map[string]*Subscription),
+	}
+
+	var list []*Subscription
+	if err := backend.Load(subscriptionsKind, &list); err != nil {
+		return nil, err
+	}
+	for _, s := range list {
+		st.subs[s.Name] = s
+	}
+	return st, nil
+}
+
+// List 按名称排序返回所有订阅
+func (st *SubscriptionStore) List() []Subscription {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	list := make([]Subscription, 0, len(st.subs))
+	for _, s := range st.subs {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get 按名称查找订阅
+func (st *SubscriptionStore) Get(name string) (Subscription, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	s, ok := st.subs[name]
+	if !ok {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+	return *s, nil
+}
+
+// Create 注册新的订阅
+func (st *SubscriptionStore) Create(sub Subscription) (Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return Subscription{}, err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if _, ok := st.subs[sub.Name]; ok {
+		return Subscription{}, ErrSubscriptionExists
+	}
+	now := time.Now()
+	sub.CreatedAt = now
+	sub.UpdatedAt = now
+	st.subs[sub.Name] = &sub
+	if err := st.saveLocked(); err != nil {
+		delete(st.subs, sub.Name)
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Update 替换已有订阅的地址与选项
+func (st *SubscriptionStore) Update(sub Subscription) (Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return Subscription{}, err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	old, ok := st.subs[sub.Name]
+	if !ok {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+	sub.CreatedAt = old.CreatedAt
+	sub.UpdatedAt = time.Now()
+	st.subs[sub.Name] = &sub
+	if err := st.saveLocked(); err != nil {
+		st.subs[sub.Name] = old
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Delete 移除订阅
+func (st *SubscriptionStore) Delete(name string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	old, ok := st.subs[name]
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(st.subs, name)
+	if err := st.saveLocked(); err != nil {
+		st.subs[name] = old
+		return err
+	}
+	return nil
+}
+
+// lookupSubscription 按名称查找订阅，运行时注册的订阅优先于配置文件中的 subscriptions
+func lookupSubscription(name string) (Subscription, error) {
+	sub, err := Subscriptions.Get(name)
+	if !errors.Is(err, ErrSubscriptionNotFound) {
+		return sub, err
+	}
+	for _, s := range Global.Subscriptions {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Subscription{}, ErrSubscriptionNotFound
+}
+
+// saveLocked 将订阅列表写回存储后端，调用方需持有写锁
+func (st *SubscriptionStore) saveLocked() error {
+	list := make([]*Subscription, 0, len(st.subs))
+	for _, s := range st.subs {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return st.backend.Save(subscriptionsKind, list)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/template"
+)
+
+const (
+	defaultTemplatePath = "resources/out-template.yaml"
+	templatesDir        = "resources/templates" // ?template=<name> 对应 templatesDir/<name>.yaml
+)
+
+var (
+	templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// templateSource 返回模板的文件路径或 URL。name 为空时使用配置中的 template，
+// 否则优先查找配置中的 templates 映射，再回退到 templatesDir 下的同名文件
+func templateSource(name string) string {
+	if name == "" {
+		if Global.Template != "" {
+			return Global.Template
+		}
+		return defaultTemplatePath
+	}
+	if src, ok := Global.Templates[name]; ok {
+		return src
+	}
+	return filepath.Join(templatesDir, name+".yaml")
+}
+
+// validateTemplate 检查命名模板是否存在
+func validateTemplate(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid template: %q", name)
+	}
+	if _, ok := Global.Templates[name]; ok {
+		return nil
+	}
+	if _, err := readTemplateFile(templateSource(name)); err != nil {
+		return fmt.Errorf("unknown template: %q", name)
+	}
+	return nil
+}
+
+// templateNames 返回可用的模板名称：默认模板（空名称）、配置中的 templates 与 resources/templates 下的预设
+func templateNames() []string {
+	seen := map[string]bool{"": true}
+	names := []string{""}
+	for name := range Global.Templates {
+		seen[name] = true
+		names = append(names, name)
+	}
+	files, _ := fs.Glob(embeddedResources, templatesDir+"/*.yaml")
+	if local, err := filepath.Glob(filepath.Join(templatesDir, "*.yaml")); err == nil {
+		files = append(files, local...)
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".yaml")
+		if !seen[name] && templateNamePattern.MatchString(name) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// checkTemplate 用示例节点渲染模板并解析结果，返回模板语法、include、YAML 结构或规则上的错误
+func checkTemplate(name string) error {
+	t, err := loadTemplate(name)
+	if err != nil {
+		return err
+	}
+	sample := []clash.Proxy{{Name: "sample", Type: "vmess", Server: "example.com", Port: 443, Region: "HK"}}
+	cfg, err := t.Render(sample, []string{"sample"}, templateOptions(name, Global.Vars))
+	if err != nil {
+		return err
+	}
+	_, _, invalid := cfg.CheckRules()
+	errs := make([]error, 0, len(invalid))
+	for _, e := range invalid {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
+
+// templateOptions 返回渲染命名模板的选项，!include 相对于模板所在位置解析
+func templateOptions(name string, vars map[string]string) template.Options {
+	return template.Options{
+		Vars: vars,
+		Include: func(doc *yaml.Node) error {
+			return expandIncludes(doc, templateSource(name), 0)
+		},
+		Logf: errorf,
+	}
+}
+
+// parsedTemplates 缓存已解析的本地模板，模板文件变化时由 watchTemplates 清空
+var parsedTemplates = struct {
+	sync.Mutex
+	m map[string]*template.Template
+}{m: make(map[string]*template.Template)}
+
+// loadTemplate 读取并解析模板；本地模板解析后缓存，http/https 地址经由远程模板缓存获取
+func loadTemplate(name string) (*template.Template, error) {
+	src := templateSource(name)
+	if isRemoteTemplate(src) {
+		data, err := remoteTemplates.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		return template.Parse(src, data)
+	}
+
+	parsedTemplates.Lock()
+	t, ok := parsedTemplates.m[src]
+	parsedTemplates.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	data, err := readTemplateFile(src)
+	if err != nil {
+		return nil, err
+	}
+	if t, err = template.Parse(src, data); err != nil {
+		return nil, err
+	}
+	parsedTemplates.Lock()
+	parsedTemplates.m[src] = t
+	parsedTemplates.Unlock()
+	return t, nil
+}
+
+// loadBaseConfig 拉取并解析 ?base= 指定的用户配置，复用远程模板缓存
+func loadBaseConfig(src string) (*template.Base, error) {
+	if err := validateSubscriptionURL(src); err != nil {
+		return nil, fmt.Errorf("invalid base: %q", src)
+	}
+	data, err := remoteTemplates.GetUserURL(src)
+	if err != nil {
+		return nil, err
+	}
+	b, err := template.ParseBase(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base config: %v", err)
+	}
+	return b, nil
+}
+
+// embeddedResources 内置默认模板与预设模板，工作目录下不存在 resources/ 时使用
+//
+//go:embed resources/out-template.yaml resources/templates/*.yaml
+var embeddedResources embed.FS
+
+// readTemplateFile 读取本地模板，文件系统中的同名文件优先于内置版本
+func readTemplateFile(src string) ([]byte, error) {
+	data, err := os.ReadFile(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		if embedded, embedErr := embeddedResources.ReadFile(filepath.ToSlash(filepath.Clean(src))); embedErr == nil {
+			return embedded, nil
+		}
+	}
+	return data, err
+}
+
+// invalidateTemplates 清空本地模板缓存
+func invalidateTemplates() {
+	parsedTemplates.Lock()
+	parsedTemplates.m = make(map[string]*template.Template)
+	parsedTemplates.Unlock()
+}
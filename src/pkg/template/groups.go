@@ -0,0 +1,144 @@
+package template
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/region"
+)
+
+const (
+	// regionGroupsPlaceholder 出现在模板 proxy-groups 中时展开为地区分组，
+	// 出现在某个分组的 proxies 列表中时展开为各地区分组的名称
+	regionGroupsPlaceholder = "${groups:region}"
+
+	// proxiesPlaceholder 展开为全部节点名称，"${proxies:<regex>}" 展开为名称匹配正则的节点
+	proxiesPlaceholder = "${proxies}"
+
+	regionSelectGroupName = "🌐 Regions"
+	urlTestURL            = "http://www.gstatic.com/generate_204"
+	urlTestInterval       = 300
+)
+
+// templateUsesRegionGroups 判断模板是否引用了地区分组占位符
+func templateUsesRegionGroups(groups []interface{}) bool {
+	for _, item := range groups {
+		switch g := item.(type) {
+		case string:
+			if g == regionGroupsPlaceholder {
+				return true
+			}
+		case map[string]interface{}:
+			list, _ := g["proxies"].([]interface{})
+			for _, p := range list {
+				if s, ok := p.(string); ok && s == regionGroupsPlaceholder {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// groupByRegion 按地区归集节点名称，返回排序后的地区代码。
+// 地区顺序与 regions 表一致，表外的 GeoIP 国家按代码排在其后；无法识别地区的节点被忽略。
+func groupByRegion(proxies []clash.Proxy) ([]string, map[string][]string) {
+	members := make(map[string][]string)
+	for _, p := range proxies {
+		code := p.RegionCode()
+		if code == "" {
+			continue
+		}
+		members[code] = append(members[code], p.Name)
+	}
+
+	codes := make([]string, 0, len(members))
+	for code := range members {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		ri, rj := region.Rank(codes[i]), region.Rank(codes[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return codes[i] < codes[j]
+	})
+	return codes, members
+}
+
+// buildRegionGroups 按地区将节点归入 url-test 分组，并生成引用这些分组的 select 组
+func buildRegionGroups(proxies []clash.Proxy) (clash.ProxyGroup, []clash.ProxyGroup) {
+	codes, members := groupByRegion(proxies)
+
+	selectGroup := clash.ProxyGroup{Name: regionSelectGroupName, Type: "select"}
+	groups := make([]clash.ProxyGroup, 0, len(codes))
+	for _, code := range codes {
+		name := regionGroupName(code)
+		selectGroup.Proxies = append(selectGroup.Proxies, name)
+		groups = append(groups, clash.ProxyGroup{
+			Name:     name,
+			Type:     "url-test",
+			Proxies:  members[code],
+			URL:      urlTestURL,
+			Interval: urlTestInterval,
+
+			NodeFilters: []string{region.Filter(code)},
+		})
+	}
+	if len(selectGroup.Proxies) == 0 {
+		// Clash 不接受空分组
+		selectGroup.Proxies = []string{"DIRECT"}
+	}
+	return selectGroup, groups
+}
+
+// regionGroupName 返回地区分组名称，如 "🇭🇰 HK"
+func regionGroupName(code string) string {
+	return region.Flag(code) + " " + code
+}
+
+// decodeTemplateGroup 将模板中的分组映射解码为 clash.ProxyGroup，保留除 proxies 外的全部字段；
+// proxies 由调用方展开占位符后填充
+func decodeTemplateGroup(g map[string]interface{}) (clash.ProxyGroup, error) {
+	fields := make(map[string]interface{}, len(g))
+	for k, v := range g {
+		if k != "proxies" {
+			fields[k] = v
+		}
+	}
+	var group clash.ProxyGroup
+	data, err := yaml.Marshal(fields)
+	if err != nil {
+		return group, err
+	}
+	err = yaml.Unmarshal(data, &group)
+	return group, err
+}
+
+// expandProxiesPlaceholder 展开 "${proxies}" 与 "${proxies:<regex>}"，同时返回占位符的名称正则（${proxies} 为空串），
+// s 不是节点占位符时返回 false。正则无效时视为没有匹配的节点，也不返回正则
+func (r *renderer) expandProxiesPlaceholder(s string, proxyNames []string) ([]string, []string, bool) {
+	if s == proxiesPlaceholder {
+		return proxyNames, []string{""}, true
+	}
+	if !strings.HasPrefix(s, "${proxies:") || !strings.HasSuffix(s, "}") {
+		return nil, nil, false
+	}
+	pattern := strings.TrimSuffix(strings.TrimPrefix(s, "${proxies:"), "}")
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		r.logf("Error compiling proxies placeholder %q: %v", s, err)
+		return nil, nil, true
+	}
+	var matched []string
+	for _, name := range proxyNames {
+		if re.MatchString(name) {
+			matched = append(matched, name)
+		}
+	}
+	return matched, []string{pattern}, true
+}
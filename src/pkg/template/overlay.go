@@ -0,0 +1,124 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Base 是用户自己的 Clash 配置（?base=）。生成的配置以它为底：dns、规则、分组与原有节点等全部保留，
+// 转换的节点与地区分组合并进来，取代输出模板
+type Base struct {
+	doc     yaml.Node
+	tmpl    templateConfig
+	proxies []*yaml.Node
+	names   map[string]bool
+}
+
+// ParseBase 解析用户配置。与模板不同，配置不经 text/template 执行，也不展开 !include 与 ${var:NAME}
+func ParseBase(data []byte) (*Base, error) {
+	b := &Base{}
+	if err := yaml.Unmarshal(data, &b.doc); err != nil {
+		return nil, fmt.Errorf("parse: %v", err)
+	}
+	if templateDocument(&b.doc) == nil {
+		return nil, errors.New("parse: not a clash config")
+	}
+	if err := b.doc.Decode(&b.tmpl); err != nil {
+		return nil, fmt.Errorf("decode: %v", err)
+	}
+	var doc struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if err := b.doc.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode proxies: %v", err)
+	}
+	for i := range doc.Proxies {
+		b.proxies = append(b.proxies, &doc.Proxies[i])
+	}
+
+	b.names = make(map[string]bool, len(b.proxies)+len(b.tmpl.ProxyGroups))
+	for _, n := range b.proxies {
+		b.names[clash.NodeName(n)] = true
+	}
+	for _, item := range b.tmpl.ProxyGroups {
+		if g, ok := item.(map[string]interface{}); ok {
+			if name, ok := g["name"].(string); ok {
+				b.names[name] = true
+			}
+		}
+	}
+	return b, nil
+}
+
+// Names 返回配置中已有的节点与分组名称，转换的节点与之重名时需先改名（见 clash.AvoidNames）
+func (b *Base) Names() map[string]bool {
+	return b.names
+}
+
+// Render 将节点合并到用户配置：节点追加在原有节点之后，地区选择组与地区 url-test 分组追加在原有分组之后，
+// 选择组同时加入第一个 select 分组，使节点可以被选用。无法识别地区的节点直接列在选择组中。
+// 配置中已使用 ${groups:region} 时按模板的方式展开，不再追加
+func (b *Base) Render(proxies []clash.Proxy, proxyNames []string, opts Options) clash.Config {
+	r := &renderer{opts: opts}
+	groups := r.proxyGroups(b.tmpl.ProxyGroups, proxies, proxyNames)
+	if !templateUsesRegionGroups(b.tmpl.ProxyGroups) {
+		groups = b.appendRegionGroups(groups, proxies)
+	}
+
+	return clash.Config{
+		Port:           b.tmpl.Port,
+		SocksPort:      b.tmpl.SocksPort,
+		AllowLan:       b.tmpl.AllowLan,
+		Mode:           b.tmpl.Mode,
+		LogLevel:       b.tmpl.LogLevel,
+		ExternalCtrl:   b.tmpl.ExternalCtrl,
+		Proxies:        proxies,
+		ExtraProxies:   b.proxies,
+		ProxyGroups:    groups,
+		RulesProviders: b.tmpl.RuleProviders,
+		Rules:          b.tmpl.Rules,
+		Template:       templateDocument(&b.doc),
+	}
+}
+
+// appendRegionGroups 追加地区分组。与原有分组重名的地区分组不重复追加，其节点并入原有分组
+func (b *Base) appendRegionGroups(groups []clash.ProxyGroup, proxies []clash.Proxy) []clash.ProxyGroup {
+	sel, regionGroups := buildRegionGroups(proxies)
+	if len(regionGroups) == 0 {
+		// 去掉空选择组的 DIRECT 占位
+		sel.Proxies = nil
+	}
+	for _, p := range proxies {
+		if p.RegionCode() == "" {
+			sel.Proxies = append(sel.Proxies, p.Name)
+		}
+	}
+	if len(sel.Proxies) == 0 {
+		return groups
+	}
+
+	index := make(map[string]int, len(groups))
+	for i, g := range groups {
+		index[g.Name] = i
+	}
+	if _, ok := index[sel.Name]; !ok {
+		for i := range groups {
+			if groups[i].Type == "select" {
+				groups[i].Proxies = append(groups[i].Proxies, sel.Name)
+				break
+			}
+		}
+	}
+	for _, g := range append([]clash.ProxyGroup{sel}, regionGroups...) {
+		if i, ok := index[g.Name]; ok {
+			groups[i].Proxies = append(groups[i].Proxies, g.Proxies...)
+			continue
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
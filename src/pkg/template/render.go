@@ -0,0 +1,146 @@
+package template
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// Options 为渲染模板时的可选设置
+type Options struct {
+	Vars map[string]string // ${var:NAME} 的取值，键为小写名称
+
+	// Include 在变量替换前展开模板文档中的 !include，为空时不处理 !include
+	Include func(doc *yaml.Node) error
+
+	// Logf 记录不影响整体渲染的问题（如无效的分组被跳过），为空时丢弃
+	Logf func(format string, args ...interface{})
+}
+
+type renderer struct {
+	opts Options
+}
+
+func (r *renderer) logf(format string, args ...interface{}) {
+	if r.opts.Logf != nil {
+		r.opts.Logf(format, args...)
+	}
+}
+
+// templateConfig 为模板中由转换器解释的键，其余键保留在 Config.Template 中原样输出
+type templateConfig struct {
+	Port          int                            `yaml:"port"`
+	SocksPort     int                            `yaml:"socks-port"`
+	AllowLan      bool                           `yaml:"allow-lan"`
+	Mode          string                         `yaml:"mode"`
+	LogLevel      string                         `yaml:"log-level"`
+	ExternalCtrl  string                         `yaml:"external-controller"`
+	RuleProviders map[string]clash.RulesProvider `yaml:"rule-providers"`
+	Rules         []string                       `yaml:"rules"`
+	ProxyGroups   []interface{}                  `yaml:"proxy-groups"`
+}
+
+// Render 以节点列表渲染模板并生成 Clash 配置
+func (t *Template) Render(proxies []clash.Proxy, proxyNames []string, opts Options) (clash.Config, error) {
+	r := &renderer{opts: opts}
+
+	f, err := t.Execute(NewData(proxies, proxyNames, opts.Vars))
+	if err != nil {
+		return clash.Config{}, fmt.Errorf("render: %v", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(f, &doc); err != nil {
+		return clash.Config{}, fmt.Errorf("parse: %v", err)
+	}
+	if opts.Include != nil {
+		if err := opts.Include(&doc); err != nil {
+			return clash.Config{}, fmt.Errorf("expand includes: %v", err)
+		}
+	}
+	substituteVars(&doc, opts.Vars)
+
+	var tmpl templateConfig
+	if err := doc.Decode(&tmpl); err != nil {
+		// 变量替换后类型不符（如 port 被替换为非数字）
+		return clash.Config{}, fmt.Errorf("decode: %v", err)
+	}
+
+	return clash.Config{
+		Port:           tmpl.Port,
+		SocksPort:      tmpl.SocksPort,
+		AllowLan:       tmpl.AllowLan,
+		Mode:           tmpl.Mode,
+		LogLevel:       tmpl.LogLevel,
+		ExternalCtrl:   tmpl.ExternalCtrl,
+		Proxies:        proxies,
+		ProxyGroups:    r.proxyGroups(tmpl.ProxyGroups, proxies, proxyNames),
+		RulesProviders: tmpl.RuleProviders,
+		Rules:          tmpl.Rules,
+		Template:       templateDocument(&doc),
+	}, nil
+}
+
+// proxyGroups 展开模板 proxy-groups 中的地区分组与节点占位符
+func (r *renderer) proxyGroups(items []interface{}, proxies []clash.Proxy, proxyNames []string) []clash.ProxyGroup {
+	var regionSelect clash.ProxyGroup
+	var regionGroups []clash.ProxyGroup
+	if templateUsesRegionGroups(items) {
+		regionSelect, regionGroups = buildRegionGroups(proxies)
+	}
+
+	var proxyGroups []clash.ProxyGroup
+	for _, item := range items {
+		// 独立的 "${groups:region}" 条目展开为地区选择组及各地区 url-test 组
+		if s, ok := item.(string); ok {
+			if s == regionGroupsPlaceholder {
+				proxyGroups = append(proxyGroups, regionSelect)
+				proxyGroups = append(proxyGroups, regionGroups...)
+			}
+			continue
+		}
+		g, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, err := decodeTemplateGroup(g)
+		if err != nil {
+			r.logf("Error parsing proxy group %v: %v, skipped", g["name"], err)
+			continue
+		}
+
+		var groupProxies, filters []string
+		expanded := false
+		// Check proxies field，"${proxies}" / "${proxies:<regex>}" 展开为全部或匹配的节点名称
+		if p, ok := g["proxies"].(string); ok {
+			groupProxies, filters, expanded = r.expandProxiesPlaceholder(p, proxyNames)
+		} else if pList, ok := g["proxies"].([]interface{}); ok {
+			for _, pItem := range pList {
+				if s, ok := pItem.(string); ok {
+					if s == regionGroupsPlaceholder {
+						groupProxies = append(groupProxies, regionSelect.Proxies...)
+						continue
+					}
+					if names, f, ok := r.expandProxiesPlaceholder(s, proxyNames); ok {
+						groupProxies = append(groupProxies, names...)
+						filters = append(filters, f...)
+						expanded = true
+						continue
+					}
+					groupProxies = append(groupProxies, s)
+				}
+			}
+		}
+		if expanded && len(groupProxies) == 0 && !group.Dynamic() {
+			// 没有匹配的节点，Clash 不接受空分组
+			groupProxies = []string{"DIRECT"}
+		}
+
+		group.Proxies = groupProxies
+		group.NodeFilters = filters
+		proxyGroups = append(proxyGroups, group)
+	}
+	return proxyGroups
+}
@@ -0,0 +1,136 @@
+// Package template 渲染输出模板：模板先按 text/template 执行，再解析为 YAML，
+// 展开 ${proxies}、${groups:region}、${var:NAME} 等占位符后生成 Clash 配置
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/region"
+)
+
+var templateVarPattern = regexp.MustCompile(`\$\{var:([A-Za-z0-9_-]+)\}`)
+
+// Template 是解析后的输出模板，可被并发使用
+type Template struct {
+	t *texttemplate.Template
+}
+
+// Parse 解析模板，name 用于错误信息（通常为模板路径或 URL）
+func Parse(name string, data []byte) (*Template, error) {
+	t, err := texttemplate.New(name).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Template{t: t}, nil
+}
+
+// Region 是模板中 .Regions 的元素
+type Region struct {
+	Code    string   // 地区代码，如 HK
+	Name    string   // 地区名称，如 香港
+	Group   string   // 对应地区分组名称，如 "🇭🇰 HK"
+	Proxies []string // 该地区的节点名称
+}
+
+// Data 是渲染输出模板时的数据
+type Data struct {
+	Proxies    []clash.Proxy
+	ProxyNames []string
+	Regions    []Region
+	Vars       map[string]string // 配置 vars 与 ?var.NAME= 参数
+}
+
+// templateFuncs 为输出模板提供的辅助函数
+var templateFuncs = texttemplate.FuncMap{
+	// filter 返回匹配正则的名称，如 {{ .ProxyNames | filter "香港|HK" }}
+	"filter": func(pattern string, names []string) ([]string, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		var matched []string
+		for _, n := range names {
+			if re.MatchString(n) {
+				matched = append(matched, n)
+			}
+		}
+		return matched, nil
+	},
+	// join 以分隔符拼接名称，如 {{ .ProxyNames | join ", " }}
+	"join": func(sep string, names []string) string {
+		return strings.Join(names, sep)
+	},
+	// quote 输出带引号的 YAML 字符串
+	"quote": func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	},
+	// list 输出 YAML 流式列表，如 proxies: {{ list .ProxyNames }}
+	"list": func(names []string) string {
+		if names == nil {
+			names = []string{}
+		}
+		b, _ := json.Marshal(names)
+		return string(b)
+	},
+}
+
+// NewData 汇总节点与地区信息供模板使用
+func NewData(proxies []clash.Proxy, proxyNames []string, vars map[string]string) Data {
+	codes, members := groupByRegion(proxies)
+	regions := make([]Region, 0, len(codes))
+	for _, code := range codes {
+		regions = append(regions, Region{
+			Code:    code,
+			Name:    region.Name(code),
+			Group:   regionGroupName(code),
+			Proxies: members[code],
+		})
+	}
+	return Data{Proxies: proxies, ProxyNames: proxyNames, Regions: regions, Vars: vars}
+}
+
+// Execute 以 text/template 渲染输出模板，得到尚未展开占位符的 YAML 文本
+func (t *Template) Execute(data Data) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateDocument 返回模板文档的顶层映射，顶层不是映射时返回 nil
+func templateDocument(doc *yaml.Node) *yaml.Node {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// substituteVars 替换模板中所有标量里的 ${var:NAME}（名称不区分大小写），未定义的变量替换为空字符串。
+// 在解析后的节点上替换，变量值不会被当作 YAML 结构解析
+func substituteVars(n *yaml.Node, vars map[string]string) {
+	if n.Kind == yaml.ScalarNode {
+		replaced := templateVarPattern.ReplaceAllStringFunc(n.Value, func(m string) string {
+			return vars[strings.ToLower(templateVarPattern.FindStringSubmatch(m)[1])]
+		})
+		if replaced != n.Value {
+			n.Value = replaced
+			if n.Style == 0 {
+				// 未加引号的值按替换后的内容重新推断类型，如 port: ${var:PORT}
+				n.Tag = ""
+			}
+		}
+		return
+	}
+	for _, c := range n.Content {
+		substituteVars(c, vars)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TLSConfig 为监听端口的 TLS 设置。设置 client-ca 后只有持有其签发证书的设备能访问（双向 TLS），
+// 适合直接暴露在公网上的小团队实例；账号级的区分仍使用 token
+type TLSConfig struct {
+	Cert     string `mapstructure:"cert"`      // 服务端证书（PEM），与 key 同时设置时以 HTTPS 监听
+	Key      string `mapstructure:"key"`       // 服务端私钥（PEM）
+	ClientCA string `mapstructure:"client-ca"` // 签发客户端证书的 CA（PEM，可含多个），为空时不校验客户端证书
+}
+
+// validate 检查证书与私钥成对设置，client-ca 需要同时开启 TLS
+func (t *TLSConfig) validate() error {
+	if (t.Cert == "") != (t.Key == "") {
+		return errors.New("cert and key must be set together")
+	}
+	if t.ClientCA != "" && t.Cert == "" {
+		return errors.New("client-ca needs cert and key")
+	}
+	return nil
+}
+
+// serverConfig 返回监听使用的 tls.Config，设置了 client-ca 时要求并校验客户端证书
+func (t TLSConfig) serverConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.ClientCA == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(t.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("read client-ca: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("client-ca %s: no PEM certificates", t.ClientCA)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// shutdownTimeout 为收到退出信号后等待进行中请求完成的时间
+const shutdownTimeout = 10 * time.Second
+
+// listen 启动 HTTP 服务，配置了证书时以 HTTPS（及可选的双向 TLS）监听。
+// 收到 SIGINT / SIGTERM 时停止接受新连接，等待进行中的请求完成后返回
+func listen(r *gin.Engine, cfg *Config) error {
+	srv := &http.Server{Addr: cfg.Listen, Handler: r}
+	run := srv.ListenAndServe
+	if cfg.TLS.Cert != "" {
+		tlsCfg, err := cfg.TLS.serverConfig()
+		if err != nil {
+			return fmt.Errorf("failed to configure tls: %v", err)
+		}
+		srv.TLSConfig = tlsCfg
+		run = func() error { return srv.ListenAndServeTLS(cfg.TLS.Cert, cfg.TLS.Key) }
+		if tlsCfg.ClientCAs != nil {
+			infof("Listening on %s (HTTPS, client certificates required)", cfg.Listen)
+		} else {
+			infof("Listening on %s (HTTPS)", cfg.Listen)
+		}
+	} else {
+		infof("Listening on %s", cfg.Listen)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- run() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	infof("Shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(sctx)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenExists   = errors.New("token already exists")
+)
+
+const (
+	tokensKind  = "tokens"
+	tokenLength = 24
+)
+
+// TokenBinding 将访问 token 绑定到默认的订阅、模板与转换参数，
+// 客户端只需使用 /config?token=xxx 即可获得对应配置
+type TokenBinding struct {
+	Token    string            `json:"token" mapstructure:"token"`
+	Name     string            `json:"name,omitempty" mapstructure:"name"`         // 备注，如使用者
+	Sub      string            `json:"sub,omitempty" mapstructure:"sub"`           // 已注册订阅的名称
+	URL      string            `json:"url,omitempty" mapstructure:"url"`           // 订阅地址，未设置 sub 时使用
+	Template string            `json:"template,omitempty" mapstructure:"template"` // 默认模板名称
+	Options  map[string]string `json:"options,omitempty" mapstructure:"options"`   // 默认查询参数，如 include、sort、target
+
+	// 以下为管理接口签发的 token 的有效期与使用次数，配置文件中的 tokens 始终有效
+	CreatedAt *time.Time `json:"created_at,omitempty" mapstructure:"-"`
+	NotBefore *time.Time `json:"not_before,omitempty" mapstructure:"-"` // 生效时间，之前使用返回 401
+	ExpiresAt *time.Time `json:"expires_at,omitempty" mapstructure:"-"` // 过期时间
+	MaxUses   int        `json:"max_uses,omitempty" mapstructure:"-"`   // 最多使用次数，0 表示不限
+	Uses      int        `json:"uses,omitempty" mapstructure:"-"`       // 已使用次数，设置了 max_uses 时记录
+	RevokedAt *time.Time `json:"revoked_at,omitempty" mapstructure:"-"` // 吊销时间，吊销的 token 保留记录以便查询
+	Status    string     `json:"status,omitempty" mapstructure:"-"`     // 查询时计算：active / pending / expired / exhausted / revoked
+}
+
+// token 的状态
+const (
+	tokenActive    = "active"
+	tokenPending   = "pending" // 未到 not_before
+	tokenExpired   = "expired"
+	tokenExhausted = "exhausted" // 已用完 max_uses
+	tokenRevoked   = "revoked"
+)
+
+// state 返回 token 在 now 时的状态
+func (b *TokenBinding) state(now time.Time) string {
+	switch {
+	case b.RevokedAt != nil:
+		return tokenRevoked
+	case b.NotBefore != nil && now.Before(*b.NotBefore):
+		return tokenPending
+	case b.ExpiresAt != nil && !now.Before(*b.ExpiresAt):
+		return tokenExpired
+	case b.MaxUses > 0 && b.Uses >= b.MaxUses:
+		return tokenExhausted
+	}
+	return tokenActive
+}
+
+// withStatus 返回附带当前状态的副本
+func (b *TokenBinding) withStatus() TokenBinding {
+	c := *b
+	c.Status = b.state(time.Now())
+	return c
+}
+
+// TokenStore 管理通过管理接口签发的 token 并持久化到存储后端
+type TokenStore struct {
+	mu      sync.RWMutex
+	backend Backend
+	tokens  map[string]*TokenBinding
+}
+
+// Tokens 是全局 token 存储，在 main 中初始化
+var Tokens *TokenStore
+
+// NewTokenStore 从存储后端加载 token
+func NewTokenStore(backend Backend) (*TokenStore, error) {
+	st := &TokenStore{
+		backend: backend,
+		tokens:  make(map[string]*TokenBinding),
+	}
+
+	var list []*TokenBinding
+	if err := backend.Load(tokensKind, &list); err != nil {
+		return nil, err
+	}
+	for _, b := range list {
+		st.tokens[b.Token] = b
+	}
+	return st, nil
+}
+
+// List 按备注排序返回所有 token
+func (st *TokenStore) List() []TokenBinding {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	list := make([]TokenBinding, 0, len(st.tokens))
+	for _, b := range st.tokens {
+		list = append(list, b.withStatus())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get 返回单个 token
+func (st *TokenStore) Get(token string) (TokenBinding, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	b, ok := st.tokens[token]
+	if !ok {
+		return TokenBinding{}, ErrTokenNotFound
+	}
+	return b.withStatus(), nil
+}
+
+// Create 签发 token，未指定 token 值时随机生成
+func (st *TokenStore) Create(b TokenBinding) (TokenBinding, error) {
+	if b.URL != "" {
+		if err := validateSubscriptionURL(b.URL); err != nil {
+			return TokenBinding{}, err
+		}
+	}
+	if b.MaxUses < 0 {
+		return TokenBinding{}, badRequest(fmt.Errorf("invalid max_uses: %d", b.MaxUses))
+	}
+	if b.NotBefore != nil && b.ExpiresAt != nil && !b.ExpiresAt.After(*b.NotBefore) {
+		return TokenBinding{}, badRequest(errors.New("expires_at must be after not_before"))
+	}
+	now := time.Now()
+	b.CreatedAt, b.Uses, b.RevokedAt, b.Status = &now, 0, nil, ""
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if b.Token == "" {
+		for {
+			id, err := randomID(tokenLength)
+			if err != nil {
+				return TokenBinding{}, err
+			}
+			if _, ok := st.tokens[id]; !ok {
+				b.Token = id
+				break
+			}
+		}
+	} else if _, ok := st.tokens[b.Token]; ok {
+		return TokenBinding{}, ErrTokenExists
+	}
+	st.tokens[b.Token] = &b
+	if err := st.saveLocked(); err != nil {
+		delete(st.tokens, b.Token)
+		return TokenBinding{}, err
+	}
+	return b.withStatus(), nil
+}
+
+// Revoke 吊销 token，记录保留，之后使用返回 401
+func (st *TokenStore) Revoke(token string) (TokenBinding, error) {
+	return st.update(token, func(b *TokenBinding) {
+		if b.RevokedAt == nil {
+			now := time.Now()
+			b.RevokedAt = &now
+		}
+	})
+}
+
+// Expire 将 token 的过期时间设为 at
+func (st *TokenStore) Expire(token string, at time.Time) (TokenBinding, error) {
+	return st.update(token, func(b *TokenBinding) {
+		b.ExpiresAt = &at
+	})
+}
+
+// update 修改 token 并保存，保存失败时恢复原值
+func (st *TokenStore) update(token string, fn func(b *TokenBinding)) (TokenBinding, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	b, ok := st.tokens[token]
+	if !ok {
+		return TokenBinding{}, ErrTokenNotFound
+	}
+	old := *b
+	fn(b)
+	if err := st.saveLocked(); err != nil {
+		*b = old
+		return TokenBinding{}, err
+	}
+	return b.withStatus(), nil
+}
+
+// use 检查 token 当前是否可用，spend 为 true 时记录一次使用。只有设置了 max_uses 的 token 记录次数，
+// 此时每次使用都会写回存储；token 不存在时 found 为 false
+func (st *TokenStore) use(token string, spend bool) (b *TokenBinding, found bool, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, t := range st.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) != 1 {
+			continue
+		}
+		if state := t.state(time.Now()); state != tokenActive {
+			return nil, true, fmt.Errorf("%w: %s", ErrInvalidToken, state)
+		}
+		if spend && t.MaxUses > 0 {
+			t.Uses++
+			if err := st.saveLocked(); err != nil {
+				t.Uses--
+				return nil, true, err
+			}
+		}
+		c := *t
+		return &c, true, nil
+	}
+	return nil, false, nil
+}
+
+// Delete 吊销 token
+func (st *TokenStore) Delete(token string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	old, ok := st.tokens[token]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	delete(st.tokens, token)
+	if err := st.saveLocked(); err != nil {
+		st.tokens[token] = old
+		return err
+	}
+	return nil
+}
+
+// find 以常量时间比较查找 token
+func (st *TokenStore) find(token string) (*TokenBinding, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	for _, b := range st.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(b.Token)) == 1 {
+			c := *b
+			return &c, true
+		}
+	}
+	return nil, false
+}
+
+// saveLocked 将 token 写回存储后端，调用方需持有写锁
+func (st *TokenStore) saveLocked() error {
+	list := make([]*TokenBinding, 0, len(st.tokens))
+	for _, b := range st.tokens {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Token < list[j].Token })
+	if err := st.backend.Save(tokensKind, list); err != nil {
+		return fmt.Errorf("failed to save tokens: %v", err)
+	}
+	return nil
+}
+
+// lookupToken 查找 token 对应的绑定，管理接口签发的 token 优先于配置文件中的 tokens
+func lookupToken(token string) (*TokenBinding, bool) {
+	if Tokens != nil {
+		if b, ok := Tokens.find(token); ok {
+			return b, true
+		}
+	}
+	for i := range Global.Tokens {
+		b := &Global.Tokens[i]
+		if b.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(b.Token)) == 1 {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// checkToken 查找可用的 token 但不记录使用，管理接口签发的 token 还需在有效期内且未用完次数
+func checkToken(token string) (*TokenBinding, error) {
+	if Tokens != nil {
+		if b, found, err := Tokens.use(token, false); found {
+			return b, err
+		}
+	}
+	if b, ok := lookupToken(token); ok {
+		return b, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// spendToken 在配置成功返回后记录一次 token 使用，只有设置了 max_uses 的签发 token 受影响；
+// 期间次数已被其他请求用完时返回错误
+func spendToken(token string) error {
+	if token == "" || Tokens == nil {
+		return nil
+	}
+	_, _, err := Tokens.use(token, true)
+	return err
+}
+
+// applyTokenBinding 将 ?token= 绑定的默认值合并到请求参数中，请求参数优先；未携带 token 时原样返回。
+// 只检查 token 是否可用，使用次数在配置返回后由 spendToken 记录
+func applyTokenBinding(params url.Values) (url.Values, error) {
+	token := params.Get("token")
+	if token == "" {
+		return params, nil
+	}
+	b, err := checkToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(url.Values, len(params)+len(b.Options)+2)
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range b.Options {
+		if !merged.Has(k) {
+			merged.Set(k, v)
+		}
+	}
+	if !merged.Has("sub") && !merged.Has("url") {
+		if b.Sub != "" {
+			merged.Set("sub", b.Sub)
+		} else if b.URL != "" {
+			merged.Set("url", b.URL)
+		}
+	}
+	if b.Template != "" && !merged.Has("template") {
+		merged.Set("template", b.Template)
+	}
+	return merged, nil
+}
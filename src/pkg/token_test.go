@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryBackend 为测试使用的存储后端，fail 为 true 时保存失败
+type memoryBackend struct {
+	saves int
+	fail  bool
+}
+
+func (b *memoryBackend) Load(kind string, v interface{}) error { return nil }
+
+func (b *memoryBackend) Save(kind string, v interface{}) error {
+	if b.fail {
+		return errors.New("disk full")
+	}
+	b.saves++
+	return nil
+}
+
+// newTestTokens 以 bindings 创建全局 token 存储，测试结束后恢复
+func newTestTokens(t *testing.T, backend Backend, bindings ...TokenBinding) *TokenStore {
+	t.Helper()
+	st, err := NewTokenStore(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range bindings {
+		st.tokens[bindings[i].Token] = &bindings[i]
+	}
+	old := Tokens
+	Tokens = st
+	t.Cleanup(func() { Tokens = old })
+	return st
+}
+
+// TestTokenState 检查 token 在不同时间的状态，吊销优先于其他状态
+func TestTokenState(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		b    TokenBinding
+		want string
+	}{
+		{"unlimited", TokenBinding{}, tokenActive},
+		{"in window", TokenBinding{NotBefore: &past, ExpiresAt: &future}, tokenActive},
+		{"pending", TokenBinding{NotBefore: &future}, tokenPending},
+		{"expired", TokenBinding{ExpiresAt: &past}, tokenExpired},
+		{"expires now", TokenBinding{ExpiresAt: &now}, tokenExpired},
+		{"uses left", TokenBinding{MaxUses: 2, Uses: 1}, tokenActive},
+		{"exhausted", TokenBinding{MaxUses: 2, Uses: 2}, tokenExhausted},
+		{"revoked", TokenBinding{RevokedAt: &past}, tokenRevoked},
+		{"revoked and expired", TokenBinding{RevokedAt: &past, ExpiresAt: &past, MaxUses: 1, Uses: 1}, tokenRevoked},
+	}
+	for _, tt := range tests {
+		if got := tt.b.state(now); got != tt.want {
+			t.Errorf("%s: state = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCheckToken 检查不可用的签发 token 返回对应状态的错误，配置文件中的 tokens 不受有效期限制
+func TestCheckToken(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	Global = &Config{Tokens: []TokenBinding{{Token: "static", Sub: "work"}}}
+	newTestTokens(t, &memoryBackend{},
+		TokenBinding{Token: "active", MaxUses: 3},
+		TokenBinding{Token: "pending", NotBefore: &future},
+		TokenBinding{Token: "expired", ExpiresAt: &past},
+		TokenBinding{Token: "revoked", RevokedAt: &past},
+		TokenBinding{Token: "exhausted", MaxUses: 1, Uses: 1},
+	)
+
+	tests := []struct {
+		token string
+		state string // 为空表示可用
+	}{
+		{"active", ""},
+		{"static", ""},
+		{"pending", tokenPending},
+		{"expired", tokenExpired},
+		{"revoked", tokenRevoked},
+		{"exhausted", tokenExhausted},
+		{"unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		b, err := checkToken(tt.token)
+		if tt.state == "" {
+			if err != nil || b == nil || b.Token != tt.token {
+				t.Errorf("%s: checkToken = %v, %v, want binding", tt.token, b, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", tt.token, err)
+		}
+		if tt.state != "unknown" && (err == nil || !strings.HasSuffix(err.Error(), tt.state)) {
+			t.Errorf("%s: err = %v, want state %s", tt.token, err, tt.state)
+		}
+	}
+}
+
+// TestSpendToken 检查只有 spendToken 记录使用次数，次数用完后 token 不可用
+func TestSpendToken(t *testing.T) {
+	Global = &Config{}
+	backend := &memoryBackend{}
+	st := newTestTokens(t, backend,
+		TokenBinding{Token: "limited", MaxUses: 2},
+		TokenBinding{Token: "unlimited"},
+	)
+
+	for i := 0; i < 3; i++ {
+		if _, err := checkToken("limited"); err != nil {
+			t.Fatalf("checkToken: %v", err)
+		}
+	}
+	if st.tokens["limited"].Uses != 0 || backend.saves != 0 {
+		t.Fatalf("checkToken spent a use: uses = %d, saves = %d", st.tokens["limited"].Uses, backend.saves)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := spendToken("limited"); err != nil {
+			t.Fatalf("spend %d: %v", i, err)
+		}
+		if got := st.tokens["limited"].Uses; got != i {
+			t.Errorf("after spend %d: uses = %d", i, got)
+		}
+	}
+	if backend.saves != 2 {
+		t.Errorf("saves = %d, want 2", backend.saves)
+	}
+	if err := spendToken("limited"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("spend exhausted token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := checkToken("limited"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("check exhausted token: err = %v, want ErrInvalidToken", err)
+	}
+	if got := st.tokens["limited"].Uses; got != 2 {
+		t.Errorf("uses = %d after exhausted spend, want 2", got)
+	}
+
+	// 未设置 max_uses 的 token 不记录次数，也不写回存储
+	if err := spendToken("unlimited"); err != nil {
+		t.Fatal(err)
+	}
+	if st.tokens["unlimited"].Uses != 0 || backend.saves != 2 {
+		t.Errorf("unlimited token: uses = %d, saves = %d", st.tokens["unlimited"].Uses, backend.saves)
+	}
+}
+
+// TestSpendTokenSaveFailure 检查保存失败时恢复使用次数，token 仍可继续使用
+func TestSpendTokenSaveFailure(t *testing.T) {
+	Global = &Config{}
+	backend := &memoryBackend{fail: true}
+	st := newTestTokens(t, backend, TokenBinding{Token: "limited", MaxUses: 1})
+
+	if err := spendToken("limited"); err == nil {
+		t.Fatal("spendToken: want save error")
+	}
+	if got := st.tokens["limited"].Uses; got != 0 {
+		t.Fatalf("uses = %d after failed save, want 0", got)
+	}
+	if _, err := checkToken("limited"); err != nil {
+		t.Fatalf("checkToken after failed save: %v", err)
+	}
+
+	backend.fail = false
+	if err := spendToken("limited"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkToken("limited"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("check after spend: err = %v, want ErrInvalidToken", err)
+	}
+}
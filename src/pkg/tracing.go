@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracingServiceName = "clash-convert-tool"
+
+// tracer 在未配置 otlp-endpoint 时为 noop 实现，各阶段可无条件创建 span
+var tracer = otel.Tracer(tracingServiceName)
+
+// tracingShutdown 退出前调用，将缓冲中的 span 发送完毕
+var tracingShutdown = func(context.Context) error { return nil }
+
+// initTracing 配置 OTLP/HTTP 导出器，endpoint 形如 http://127.0.0.1:4318，未写路径时发送到 /v1/traces
+func initTracing(cfg *Config) error {
+	if cfg.OTLPEndpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.OTLPEndpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid otlp-endpoint: %q", cfg.OTLPEndpoint)
+	}
+	// 只写了 collector 地址时使用标准路径
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return fmt.Errorf("invalid otlp-endpoint: %v", err)
+	}
+	ratio := cfg.TraceSampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(tracingServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tracingShutdown = provider.Shutdown
+	return nil
+}
+
+// flushTracing 在命令行一次性任务结束时发送剩余的 span
+func flushTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracingShutdown(ctx); err != nil {
+		warnf("Warning: failed to flush traces: %v", err)
+	}
+}
+
+// tracingMiddleware 为每个请求创建 server span，并将其放入请求的 context 中，
+// 上游带有 traceparent 头时作为其子 span
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if err := c.Errors.Last(); err != nil {
+			span.RecordError(err.Err)
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+}
+
+// startSpan 为转换流程中的一个阶段创建 span
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// upstreamHostAttr 只记录订阅地址的主机名，路径与查询参数中常带有用户 token
+func upstreamHostAttr(subURL string) attribute.KeyValue {
+	host := ""
+	if u, err := url.Parse(subURL); err == nil {
+		host = u.Hostname()
+	}
+	return semconv.ServerAddress(host)
+}
+
+// endSpan 记录阶段的错误并结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
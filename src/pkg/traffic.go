@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// userinfoHeader 为机场返回流量与到期信息的响应头：upload=123; download=456; total=789; expire=1700000000
+const userinfoHeader = "Subscription-Userinfo"
+
+var (
+	// upstreamUserinfo 保存各来源最近一次拉取时的 subscription-userinfo 响应头
+	upstreamUserinfo sync.Map
+	// trafficInfos 保存各来源最近一次解析出的流量信息
+	trafficInfos sync.Map
+
+	trafficSizePattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*([KMGTP]?)(?:i?B\b|\b)`)
+	trafficDatePattern = regexp.MustCompile(`(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})`)
+
+	remainingPattern = regexp.MustCompile(`(?i)剩余|remaining|\bleft\b`)
+	usedPattern      = regexp.MustCompile(`(?i)已用|已使用|\bused\b`)
+	totalPattern     = regexp.MustCompile(`(?i)总流量|套餐流量|\btotal\b`)
+	expirePattern    = regexp.MustCompile(`(?i)到期|过期|有效期|expire`)
+)
+
+// TrafficInfo 为订阅的流量（字节）与到期信息
+type TrafficInfo struct {
+	Upload   int64
+	Download int64
+	Total    int64
+	Expire   time.Time // 为零值时表示未知或长期有效
+	From     string    // header：来自 subscription-userinfo；nodes：来自信息节点
+}
+
+// parseUserinfo 解析 subscription-userinfo 响应头，没有任何字段时返回 false
+func parseUserinfo(header string) (TrafficInfo, bool) {
+	info := TrafficInfo{From: "header"}
+	found := false
+	for _, field := range strings.Split(header, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "upload":
+			info.Upload = int64(n)
+		case "download":
+			info.Download = int64(n)
+		case "total":
+			info.Total = int64(n)
+		case "expire":
+			if n > 0 {
+				info.Expire = time.Unix(int64(n), 0)
+			}
+		default:
+			continue
+		}
+		found = true
+	}
+	return info, found
+}
+
+// parseInfoNodes 从信息节点名称（如「剩余流量：98.5 GB」「套餐到期：2025-01-31」）中提取流量与到期信息，
+// 只有剩余流量时总流量按已用与剩余之和计算
+func parseInfoNodes(proxies []clash.Proxy) (TrafficInfo, bool) {
+	used, remaining, total := int64(-1), int64(-1), int64(-1)
+	var expire time.Time
+	for _, p := range proxies {
+		if !isInfoNode(p) {
+			continue
+		}
+		name := p.Name
+		if expirePattern.MatchString(name) {
+			if m := trafficDatePattern.FindStringSubmatch(name); m != nil {
+				y, _ := strconv.Atoi(m[1])
+				mo, _ := strconv.Atoi(m[2])
+				d, _ := strconv.Atoi(m[3])
+				expire = time.Date(y, time.Month(mo), d, 0, 0, 0, 0, time.Local)
+			}
+		}
+		sizes := trafficSizes(name)
+		switch {
+		case len(sizes) == 0:
+		case len(sizes) >= 2 && strings.Contains(name, "/"):
+			// 「流量：12.3GB / 100GB」为已用与总流量
+			used, total = sizes[0], sizes[1]
+		case remainingPattern.MatchString(name):
+			remaining = sizes[0]
+		case usedPattern.MatchString(name):
+			used = sizes[0]
+		case totalPattern.MatchString(name):
+			total = sizes[0]
+		}
+	}
+
+	info := TrafficInfo{Expire: expire, From: "nodes"}
+	switch {
+	case total >= 0 && used >= 0:
+		info.Total, info.Download = total, used
+	case total >= 0 && remaining >= 0:
+		info.Total, info.Download = total, max(total-remaining, 0)
+	case remaining >= 0:
+		info.Download = max(used, 0)
+		info.Total = remaining + info.Download
+	case total >= 0:
+		info.Total = total
+	case expire.IsZero():
+		return TrafficInfo{}, false
+	}
+	return info, true
+}
+
+// trafficSizes 按出现顺序返回名称中带单位的流量数值（字节，按 1024 进位）
+func trafficSizes(name string) []int64 {
+	var sizes []int64
+	for _, m := range trafficSizePattern.FindAllStringSubmatch(name, -1) {
+		unit := strings.ToUpper(m[2])
+		if unit == "" && !strings.HasSuffix(strings.ToUpper(m[0]), "B") {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		shift := strings.Index("KMGTP", unit) + 1
+		if unit == "" {
+			shift = 0
+		}
+		sizes = append(sizes, int64(n*float64(int64(1)<<(10*shift))))
+	}
+	return sizes
+}
+
+// recordUserinfo 保存来源的 subscription-userinfo 响应头，响应中没有时清除之前的记录
+func recordUserinfo(subURL, header string) {
+	if header == "" {
+		upstreamUserinfo.Delete(subURL)
+		return
+	}
+	upstreamUserinfo.Store(subURL, header)
+}
+
+// recordTraffic 记录来源的流量信息，优先使用响应头，其次为信息节点
+func recordTraffic(subURL string, proxies []clash.Proxy) {
+	if header, ok := upstreamUserinfo.Load(subURL); ok {
+		if info, ok := parseUserinfo(header.(string)); ok {
+			trafficInfos.Store(subURL, info)
+			return
+		}
+	}
+	if info, ok := parseInfoNodes(proxies); ok {
+		trafficInfos.Store(subURL, info)
+		return
+	}
+	trafficInfos.Delete(subURL)
+}
+
+// sourceTraffic 返回来源最近记录的流量信息
+func sourceTraffic(src string) (TrafficInfo, bool) {
+	v, ok := trafficInfos.Load(src)
+	if !ok {
+		return TrafficInfo{}, false
+	}
+	return v.(TrafficInfo), true
+}
+
+// subscriptionTraffic 汇总订阅各来源的流量信息，到期时间取最早的一个
+func subscriptionTraffic(subURL string) (TrafficInfo, bool) {
+	var sum TrafficInfo
+	found := false
+	for _, src := range splitSources(subURL) {
+		info, ok := sourceTraffic(src)
+		if !ok {
+			continue
+		}
+		sum.Upload += info.Upload
+		sum.Download += info.Download
+		sum.Total += info.Total
+		if !info.Expire.IsZero() && (sum.Expire.IsZero() || info.Expire.Before(sum.Expire)) {
+			sum.Expire = info.Expire
+		}
+		if sum.From == "" {
+			sum.From = info.From
+		} else if sum.From != info.From {
+			sum.From = "mixed"
+		}
+		found = true
+	}
+	return sum, found
+}
+
+// TrafficReport 为 /config/meta 中一个来源或整个订阅的流量信息
+type TrafficReport struct {
+	Source    string     `json:"source,omitempty"`
+	Upload    int64      `json:"upload"`
+	Download  int64      `json:"download"`
+	Used      int64      `json:"used"`
+	Total     int64      `json:"total"`
+	Remaining int64      `json:"remaining"`
+	Expire    *time.Time `json:"expire,omitempty"`
+	From      string     `json:"from,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// MetaReport 是 /config/meta 返回的结果
+type MetaReport struct {
+	Subscription string          `json:"subscription"`
+	Traffic      *TrafficReport  `json:"traffic"` // 各来源汇总，都没有流量信息时为 null
+	Sources      []TrafficReport `json:"sources"`
+}
+
+func trafficReport(info TrafficInfo) TrafficReport {
+	r := TrafficReport{
+		Upload:    info.Upload,
+		Download:  info.Download,
+		Used:      info.Upload + info.Download,
+		Total:     info.Total,
+		Remaining: max(info.Total-info.Upload-info.Download, 0),
+		From:      info.From,
+	}
+	if !info.Expire.IsZero() {
+		expire := info.Expire
+		r.Expire = &expire
+	}
+	return r
+}
+
+// configMeta 重新获取订阅，返回其已用/总流量与到期时间
+func configMeta(c *gin.Context) {
+	subURL, params, err := resolveSubscription(c.Request.URL.Query())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	fetch := subscriptionFetch(params)
+	results := collectSources(c.Request.Context(), subURL, func(ctx context.Context, src string) sourceResult {
+		proxies, _, err := fetchSourceProxies(ctx, src, fetch[src])
+		return sourceResult{Proxies: proxies, Err: err}
+	})
+	if _, err := mergeSources(c.Request.Context(), results); err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := MetaReport{Subscription: subscriptionLabel(subURL), Sources: make([]TrafficReport, 0, len(results))}
+	for _, r := range results {
+		var tr TrafficReport
+		if info, ok := sourceTraffic(r.URL); ok {
+			tr = trafficReport(info)
+		}
+		tr.Source = subscriptionLabel(r.URL)
+		if r.Err != nil {
+			tr.Error = r.Err.Error()
+		}
+		report.Sources = append(report.Sources, tr)
+	}
+	if info, ok := subscriptionTraffic(subURL); ok {
+		tr := trafficReport(info)
+		report.Traffic = &tr
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// setTrafficHeaders 转发订阅的流量信息，客户端据此显示用量；show 为 true 时在配置名称（?sub= 或 out）后附加剩余流量
+func setTrafficHeaders(c *gin.Context, subURL string, params url.Values, show bool) {
+	info, ok := subscriptionTraffic(subURL)
+	if !ok {
+		return
+	}
+	header := fmt.Sprintf("upload=%d; download=%d; total=%d", info.Upload, info.Download, info.Total)
+	if !info.Expire.IsZero() {
+		header += fmt.Sprintf("; expire=%d", info.Expire.Unix())
+	}
+	c.Header(userinfoHeader, header)
+	if !show || info.Total <= 0 {
+		return
+	}
+
+	name := params.Get("sub")
+	if name == "" {
+		name = "out"
+	}
+	remaining := max(info.Total-info.Upload-info.Download, 0)
+	name = fmt.Sprintf("%s (%.1fGB left)", name, float64(remaining)/(1<<30))
+	// Clash Verge 等客户端优先使用 profile-title 作为配置名称，其余客户端使用文件名
+	c.Header("Profile-Title", "base64:"+base64.StdEncoding.EncodeToString([]byte(name)))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".yaml"}))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultMaxRedirects = 3
+
+// URLGuardConfig 限制使用者在请求参数中提供的地址（?url=、?config=、?base=），避免服务被用来访问内网。
+// 已配置的订阅来源（url、subscriptions、token 绑定的地址）与模板不受限制
+type URLGuardConfig struct {
+	BlockPrivate bool     `mapstructure:"block-private"` // 拒绝回环、内网（RFC 1918）、链路本地、CGNAT 等地址
+	Schemes      []string `mapstructure:"schemes"`       // 允许的协议：http / https
+	MaxRedirects int      `mapstructure:"max-redirects"` // 最多跟随的重定向次数，0 表示不跟随
+	AllowHosts   []string `mapstructure:"allow-hosts"`   // 只允许这些主机及其子域名，为空时不限制
+}
+
+// validate 检查协议列表并统一主机名的大小写
+func (g *URLGuardConfig) validate() error {
+	if len(g.Schemes) == 0 {
+		return fmt.Errorf("empty schemes")
+	}
+	for i, s := range g.Schemes {
+		s = strings.ToLower(s)
+		if s != "http" && s != "https" {
+			return fmt.Errorf("invalid scheme: %q (want http or https)", s)
+		}
+		g.Schemes[i] = s
+	}
+	if g.MaxRedirects < 0 {
+		return fmt.Errorf("invalid max-redirects: %d", g.MaxRedirects)
+	}
+	if err := normalizeHosts(g.AllowHosts); err != nil {
+		return fmt.Errorf("invalid allow-hosts: %v", err)
+	}
+	return nil
+}
+
+// check 检查单个地址的协议、主机与地址中直接写出的 IP；域名解析得到的 IP 在连接时检查
+func (g *URLGuardConfig) check(u *url.URL) error {
+	if !slices.Contains(g.Schemes, u.Scheme) {
+		return fmt.Errorf("%w: scheme %q", ErrBlockedURL, u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if len(g.AllowHosts) > 0 && !matchHost(g.AllowHosts, host) {
+		return fmt.Errorf("%w: host %s not in allow-hosts", ErrBlockedURL, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && g.BlockPrivate && blockedAddr(ip) {
+		return fmt.Errorf("%w: address %s", ErrBlockedURL, ip)
+	}
+	return nil
+}
+
+// normalizeHosts 统一主机列表的大小写并去掉 *. 前缀，检查其中的 IP 段
+func normalizeHosts(hosts []string) error {
+	for i, h := range hosts {
+		h = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(h)), "*.")
+		if h == "" {
+			return fmt.Errorf("empty host")
+		}
+		if strings.Contains(h, "/") {
+			if _, err := netip.ParsePrefix(h); err != nil {
+				return fmt.Errorf("bad cidr %q", h)
+			}
+		}
+		hosts[i] = h
+	}
+	return nil
+}
+
+// matchHost 判断主机是否属于列表：与条目相同或为其子域名，地址中直接写出的 IP 也可按 IP 段匹配
+func matchHost(hosts []string, host string) bool {
+	ip, ipErr := netip.ParseAddr(host)
+	return slices.ContainsFunc(hosts, func(h string) bool {
+		if prefix, err := netip.ParsePrefix(h); err == nil {
+			return ipErr == nil && prefix.Contains(ip.Unmap())
+		}
+		return host == h || strings.HasSuffix(host, "."+h)
+	})
+}
+
+// checkFetchHost 按 fetch-allow-hosts 与 fetch-deny-hosts 检查订阅地址的主机，对所有订阅来源生效
+func checkFetchHost(u *url.URL) error {
+	host := strings.ToLower(u.Hostname())
+	if matchHost(Global.FetchDenyHosts, host) {
+		return fmt.Errorf("%w: host %s is in fetch-deny-hosts", ErrBlockedURL, host)
+	}
+	if len(Global.FetchAllowHosts) > 0 && !matchHost(Global.FetchAllowHosts, host) {
+		return fmt.Errorf("%w: host %s not in fetch-allow-hosts", ErrBlockedURL, host)
+	}
+	return nil
+}
+
+// urlGuardActive 表示是否检查使用者提供的地址，只在 HTTP 服务中开启；命令行转换由运维执行，不受限制
+var urlGuardActive bool
+
+// guardedClient 用于拉取使用者提供的订阅地址，在 setup 中按配置初始化
+var guardedClient = http.DefaultClient
+
+// cgnatPrefix 为运营商级 NAT 的共享地址段（RFC 6598），常见于云厂商内网
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// blockedAddr 判断 IP 是否为不允许访问的内网地址
+func blockedAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnatPrefix.Contains(ip)
+}
+
+// checkUserURL 按 url-guard 检查使用者提供的地址，多个来源以 | 分隔时逐个检查
+func checkUserURL(raw string) error {
+	if !urlGuardActive {
+		return nil
+	}
+	for _, src := range splitSources(raw) {
+		u, err := url.Parse(src)
+		if err != nil {
+			return fmt.Errorf("%w: bad url", ErrBlockedURL)
+		}
+		if err := Global.URLGuard.check(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configuredSource 判断来源是否属于已配置的订阅：默认订阅、命名订阅与 token 绑定的地址
+func configuredSource(src string) bool {
+	urls := []string{Global.Url}
+	for _, s := range Global.Subscriptions {
+		urls = append(urls, s.URL)
+	}
+	if Subscriptions != nil {
+		for _, s := range Subscriptions.List() {
+			urls = append(urls, s.URL)
+		}
+	}
+	for _, b := range Global.Tokens {
+		urls = append(urls, b.URL)
+	}
+	if Tokens != nil {
+		for _, b := range Tokens.List() {
+			urls = append(urls, b.URL)
+		}
+	}
+	for _, u := range urls {
+		if u != "" && slices.Contains(splitSources(u), src) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchClient 返回拉取订阅来源使用的客户端，使用者提供的来源经 guardedClient 拉取
+func fetchClient(src string) *http.Client {
+	if urlGuardActive && !configuredSource(src) {
+		return guardedClient
+	}
+	return upstreamClient
+}
+
+// newGuardedClient 基于 transport 创建拉取使用者提供地址的客户端：连接前检查域名解析得到的 IP，
+// 避免域名指向内网或在检查后被改为内网地址（DNS rebinding）；重定向的次数与每一跳的地址同样受限。
+// 不使用 HTTP/3（QUIC 连接不经过 DialContext）；经代理拉取时由代理解析域名，只能检查地址中直接写出的 IP
+func newGuardedClient(transport *http.Transport, connectTimeout, timeout time.Duration) *http.Client {
+	transport = transport.Clone()
+
+	// 记录代理的地址，连接代理本身时不检查（fetch-proxy 通常位于本机或内网）
+	var proxies sync.Map
+	if proxy := transport.Proxy; proxy != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := proxy(req)
+			if u != nil {
+				proxies.Store(proxyAddr(u), true)
+			}
+			return u, err
+		}
+	}
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second, Resolver: dnsResolver}
+	guarded := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+		Resolver:  dnsResolver,
+		Control:   guardControl,
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if _, ok := proxies.Load(addr); ok {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > Global.URLGuard.MaxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrBlockedURL, Global.URLGuard.MaxRedirects)
+			}
+			if err := Global.URLGuard.check(req.URL); err != nil {
+				return err
+			}
+			return checkFetchHost(req.URL)
+		},
+	}
+}
+
+// guardControl 在建立连接前拒绝 url-guard 不允许访问的地址，此时域名已解析为 IP
+func guardControl(network, address string, _ syscall.RawConn) error {
+	host, _, _ := net.SplitHostPort(address)
+	if ip, err := netip.ParseAddr(host); err == nil && Global.URLGuard.BlockPrivate && blockedAddr(ip) {
+		return fmt.Errorf("%w: address %s", ErrBlockedURL, ip)
+	}
+	return nil
+}
+
+// guardProbes 判断是否限制测速与延迟探测连接的地址：订阅中含使用者提供的来源时，
+// 其节点的 server 可能指向内网，不能由服务代为连接
+func guardProbes(subURL string) bool {
+	if !urlGuardActive || !Global.URLGuard.BlockPrivate {
+		return false
+	}
+	return slices.ContainsFunc(splitSources(subURL), func(src string) bool { return !configuredSource(src) })
+}
+
+// probeDialer 返回探测节点使用的 Dialer，guard 为 true 时内网地址的节点不会被连接，结果按不可达处理
+func probeDialer(timeout time.Duration, guard bool) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout, Resolver: dnsResolver}
+	if guard {
+		d.Control = guardControl
+	}
+	return d
+}
+
+// proxyAddr 返回连接代理时使用的 host:port，未写端口时按协议补全
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+	return net.JoinHostPort(u.Hostname(), port)
+}
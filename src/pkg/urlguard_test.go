@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBlockedAddr 检查回环、内网、CGNAT 与 IPv4 映射的 IPv6 地址均被拒绝，公网地址放行
+func TestBlockedAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"127.10.0.1", true},
+		{"::1", true},
+		{"10.0.0.1", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true}, // CGNAT
+		{"100.127.255.255", true},
+		{"100.128.0.1", false},
+		{"169.254.169.254", true}, // 云厂商元数据地址
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"224.0.0.1", true},
+		{"::ffff:127.0.0.1", true}, // IPv4 映射的 IPv6 地址
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"::ffff:8.8.8.8", false},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := blockedAddr(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+			t.Errorf("blockedAddr(%s) = %v, want %v", tt.addr, got, tt.blocked)
+		}
+	}
+}
+
+// TestMatchHost 检查 allow-hosts 只匹配主机本身与子域名，IP 段只匹配地址中直接写出的 IP
+func TestMatchHost(t *testing.T) {
+	hosts := []string{"Example.com", "*.sub.org", "10.0.0.0/8", "2001:db8::/32"}
+	if err := normalizeHosts(hosts); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host  string
+		match bool
+	}{
+		{"example.com", true},
+		{"cdn.example.com", true},
+		{"a.b.example.com", true},
+		{"evil-example.com", false},
+		{"example.com.evil.net", false},
+		{"sub.org", true},
+		{"x.sub.org", true},
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"10.example.net", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if got := matchHost(hosts, tt.host); got != tt.match {
+			t.Errorf("matchHost(%q) = %v, want %v", tt.host, got, tt.match)
+		}
+	}
+
+	for _, bad := range []string{"", "*.", "10.0.0.0/33"} {
+		if err := normalizeHosts([]string{bad}); err == nil {
+			t.Errorf("normalizeHosts(%q): want error", bad)
+		}
+	}
+}
+
+// TestURLGuardCheck 检查协议、allow-hosts 与地址中直接写出的内网 IP
+func TestURLGuardCheck(t *testing.T) {
+	g := URLGuardConfig{
+		BlockPrivate: true,
+		Schemes:      []string{"HTTPS"},
+		AllowHosts:   []string{"example.com", "10.0.0.0/8"},
+	}
+	if err := g.validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/sub", true},
+		{"https://API.Example.com/sub", true},
+		{"http://example.com/sub", false},
+		{"file:///etc/passwd", false},
+		{"https://example.org/sub", false},
+		{"https://10.0.0.1/sub", false}, // 在 allow-hosts 中，但为内网地址
+		{"https://[::ffff:10.0.0.1]/sub", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = g.check(u)
+		if (err == nil) != tt.allowed {
+			t.Errorf("check(%s) = %v, want allowed: %v", tt.url, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrBlockedURL) {
+			t.Errorf("check(%s) = %v, want ErrBlockedURL", tt.url, err)
+		}
+	}
+}
+
+// TestGuardControl 检查连接前按解析得到的 IP 拒绝内网地址
+func TestGuardControl(t *testing.T) {
+	tests := []struct {
+		block   bool
+		addr    string
+		allowed bool
+	}{
+		{true, "127.0.0.1:80", false},
+		{true, "[::1]:443", false},
+		{true, "192.168.0.10:8080", false},
+		{true, "[::ffff:100.64.1.1]:80", false},
+		{true, "8.8.8.8:53", true},
+		{false, "127.0.0.1:80", true},
+	}
+	for _, tt := range tests {
+		Global = &Config{URLGuard: URLGuardConfig{BlockPrivate: tt.block}}
+		err := guardControl("tcp", tt.addr, nil)
+		if (err == nil) != tt.allowed {
+			t.Errorf("block-private %v: guardControl(%s) = %v, want allowed: %v", tt.block, tt.addr, err, tt.allowed)
+		}
+	}
+}
+
+// newRedirectServer 返回的服务在 /r/n 重定向到 /r/n-1，/r/0 返回 200
+func newRedirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/r/"))
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/r/%d", n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestGuardedClientRedirects 检查重定向次数的上限与每一跳地址的检查
+func TestGuardedClientRedirects(t *testing.T) {
+	srv := newRedirectServer(t)
+	// 测试服务位于回环地址，这里不拒绝内网地址，只检查重定向次数
+	Global = &Config{URLGuard: URLGuardConfig{Schemes: []string{"http"}, MaxRedirects: 2}}
+	client := newGuardedClient(http.DefaultTransport.(*http.Transport), time.Second, 5*time.Second)
+
+	tests := []struct {
+		hops    int
+		allowed bool
+	}{
+		{0, true},
+		{2, true},
+		{3, false},
+	}
+	for _, tt := range tests {
+		resp, err := client.Get(fmt.Sprintf("%s/r/%d", srv.URL, tt.hops))
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.allowed {
+			t.Errorf("%d redirects: err = %v, want allowed: %v", tt.hops, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrBlockedURL) {
+			t.Errorf("%d redirects: err = %v, want ErrBlockedURL", tt.hops, err)
+		}
+	}
+
+	// 每一跳的地址同样检查
+	Global.URLGuard = URLGuardConfig{BlockPrivate: true, Schemes: []string{"http"}, MaxRedirects: 2}
+	Global.FetchDenyHosts = []string{"deny.example.com"}
+	via := []*http.Request{{URL: &url.URL{Scheme: "http", Host: "example.com"}}}
+	for _, target := range []string{"http://10.0.0.1/", "https://example.com/", "http://cdn.deny.example.com/"} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		if err := client.CheckRedirect(req, via); !errors.Is(err, ErrBlockedURL) {
+			t.Errorf("redirect to %s: err = %v, want ErrBlockedURL", target, err)
+		}
+	}
+}
+
+// TestGuardedClientPrivateAddr 检查域名解析为内网地址时拒绝连接
+func TestGuardedClientPrivateAddr(t *testing.T) {
+	srv := newRedirectServer(t)
+	Global = &Config{URLGuard: URLGuardConfig{BlockPrivate: true, Schemes: []string{"http"}}}
+	client := newGuardedClient(http.DefaultTransport.(*http.Transport), time.Second, 5*time.Second)
+
+	// 以域名访问时地址在连接前检查，localhost 解析为回环地址
+	u := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + "/r/0"
+	resp, err := client.Get(u)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("get %s: err = %v, want ErrBlockedURL", u, err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	usageKind = "usage"
+	// usageFlushInterval 为使用统计写回存储的间隔，只在 storage 为 sqlite 时写回
+	usageFlushInterval = time.Minute
+)
+
+// TokenUsage 为单个访问 token 的使用统计
+type TokenUsage struct {
+	Token      string    `json:"token"`
+	Name       string    `json:"name,omitempty"` // token 或用户的备注
+	Requests   int64     `json:"requests"`
+	Errors     int64     `json:"errors"` // 转换失败的请求数
+	Bytes      int64     `json:"bytes"`  // 返回的配置总字节数
+	LastAccess time.Time `json:"last_access"`
+}
+
+// UsageStats 在内存中按 token 记录 /config 的访问情况，可选地定期写回存储后端
+type UsageStats struct {
+	mu      sync.Mutex
+	backend Backend // 为空时只保存在内存中
+	usage   map[string]*TokenUsage
+	dirty   bool
+}
+
+// Usage 是全局使用统计，在 setup 中初始化
+var Usage *UsageStats
+
+// NewUsageStats 创建使用统计，backend 不为空时加载并定期写回之前的统计
+func NewUsageStats(backend Backend) (*UsageStats, error) {
+	st := &UsageStats{
+		backend: backend,
+		usage:   make(map[string]*TokenUsage),
+	}
+	if backend == nil {
+		return st, nil
+	}
+
+	var list []*TokenUsage
+	if err := backend.Load(usageKind, &list); err != nil {
+		return nil, err
+	}
+	for _, u := range list {
+		st.usage[u.Token] = u
+	}
+	return st, nil
+}
+
+// Record 记录一次携带 token 的请求，token 为空或未知时忽略
+func (st *UsageStats) Record(token string, bytes int, err error) {
+	if token == "" {
+		return
+	}
+	name, ok := tokenName(token)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	u, ok := st.usage[token]
+	if !ok {
+		u = &TokenUsage{Token: token}
+		st.usage[token] = u
+	}
+	u.Name = name
+	u.Requests++
+	if err != nil {
+		u.Errors++
+	}
+	u.Bytes += int64(bytes)
+	u.LastAccess = time.Now()
+	st.dirty = true
+}
+
+// List 按最近访问时间倒序返回所有 token 的统计
+func (st *UsageStats) List() []TokenUsage {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	list := make([]TokenUsage, 0, len(st.usage))
+	for _, u := range st.usage {
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastAccess.After(list[j].LastAccess) })
+	return list
+}
+
+// Flush 将有变化的统计写回存储后端
+func (st *UsageStats) Flush() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.backend == nil || !st.dirty {
+		return nil
+	}
+	list := make([]*TokenUsage, 0, len(st.usage))
+	for _, u := range st.usage {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Token < list[j].Token })
+	if err := st.backend.Save(usageKind, list); err != nil {
+		return err
+	}
+	st.dirty = false
+	return nil
+}
+
+// startUsageFlusher 按 usageFlushInterval 定时写回使用统计
+func startUsageFlusher() {
+	go func() {
+		ticker := time.NewTicker(usageFlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := Usage.Flush(); err != nil {
+				warnf("Failed to save usage stats: %v", err)
+			}
+		}
+	}()
+}
+
+// tokenName 返回 token 所属用户或绑定的备注，token 未知时返回 false
+func tokenName(token string) (string, bool) {
+	if u, ok := lookupUser(token); ok {
+		return u.Name, true
+	}
+	if b, ok := lookupToken(token); ok {
+		return b.Name, true
+	}
+	return "", false
+}
+
+func listUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, Usage.List())
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ErrForbidden 表示用户 token 请求了其订阅集以外的订阅
+var ErrForbidden = errors.New("subscription not allowed for this token")
+
+// userSubSeparator 分隔 ?sub= 中的多个订阅名称，供用户从订阅集中选择一部分
+const userSubSeparator = ","
+
+// User 为配置文件 users 中的一个使用者。其 token 只能访问 subscriptions 中的命名订阅，
+// 多个订阅的节点合并输出，不能通过 ?url= 或其他订阅名称访问别人的节点
+type User struct {
+	Name          string            `mapstructure:"name"`          // 备注，如使用者
+	Token         string            `mapstructure:"token"`         // 访问 token，即 /config?token=xxx
+	Subscriptions []string          `mapstructure:"subscriptions"` // 可访问的命名订阅
+	Template      string            `mapstructure:"template"`      // 默认模板名称
+	Options       map[string]string `mapstructure:"options"`       // 默认查询参数，请求中的同名参数优先
+}
+
+// validateUsers 检查 users 配置：token 不能为空或与其他用户、tokens 重复，且至少有一个订阅
+func validateUsers(users []User, tokens []TokenBinding) error {
+	seen := make(map[string]bool, len(users)+len(tokens))
+	for _, b := range tokens {
+		seen[b.Token] = true
+	}
+	for i, u := range users {
+		label := u.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		switch {
+		case u.Token == "":
+			return fmt.Errorf("user %s: missing token", label)
+		case seen[u.Token]:
+			return fmt.Errorf("user %s: duplicate token", label)
+		case len(u.Subscriptions) == 0:
+			return fmt.Errorf("user %s: no subscriptions", label)
+		}
+		seen[u.Token] = true
+	}
+	return nil
+}
+
+// lookupUser 以常量时间比较查找 token 对应的用户
+func lookupUser(token string) (*User, bool) {
+	for i := range Global.Users {
+		u := &Global.Users[i]
+		if subtle.ConstantTimeCompare([]byte(token), []byte(u.Token)) == 1 {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// checkOwnedSubscription 检查请求中按名称指定的订阅：出现在某个用户订阅集中的订阅只能由其用户 token 访问，
+// 未带 token 时返回 401，其他 token 返回 403
+func checkOwnedSubscription(name, token string) error {
+	for _, u := range Global.Users {
+		if !slices.Contains(u.Subscriptions, name) {
+			continue
+		}
+		if token == "" {
+			return fmt.Errorf("%w: subscription %s requires its user token", ErrInvalidToken, name)
+		}
+		return fmt.Errorf("%w: %s", ErrForbidden, name)
+	}
+	return nil
+}
+
+// resolveUser 返回用户订阅集合并后的订阅地址与参数。?sub= 可选择订阅集中的一部分（以逗号分隔），
+// 默认使用全部订阅；参数优先级依次为请求参数、用户 options 与 template、订阅 options
+func resolveUser(u *User, params url.Values) (string, url.Values, error) {
+	if params.Has("url") {
+		return "", nil, fmt.Errorf("%w: url", ErrForbidden)
+	}
+	names := u.Subscriptions
+	if raw := params.Get("sub"); raw != "" {
+		names = nil
+		for _, name := range strings.Split(raw, userSubSeparator) {
+			name = strings.TrimSpace(name)
+			if name == "" || slices.Contains(names, name) {
+				continue
+			}
+			if !slices.Contains(u.Subscriptions, name) {
+				return "", nil, fmt.Errorf("%w: %s", ErrForbidden, name)
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return "", nil, badRequest(fmt.Errorf("empty sub: %q", raw))
+		}
+	}
+
+	merged := make(url.Values, len(params)+len(u.Options)+2)
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range u.Options {
+		if !merged.Has(k) {
+			merged.Set(k, v)
+		}
+	}
+	if u.Template != "" && !merged.Has("template") {
+		merged.Set("template", u.Template)
+	}
+
+	urls := make([]string, 0, len(names))
+	for _, name := range names {
+		sub, err := lookupSubscription(name)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s", err, name)
+		}
+		urls = append(urls, sub.URL)
+		for k, v := range sub.Options {
+			if !merged.Has(k) {
+				merged.Set(k, v)
+			}
+		}
+	}
+	// subscriptionFetch 按 sub 查找各订阅的拉取设置
+	merged.Set("sub", strings.Join(names, userSubSeparator))
+	return strings.Join(urls, sourceSeparator), merged, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"pkg/main.go/src/pkg/clash"
+	"pkg/main.go/src/pkg/parser"
+)
+
+// ValidateReport 是 /validate 返回的演练结果
+type ValidateReport struct {
+	Subscription  string               `json:"subscription"`
+	Sources       []SourceReport       `json:"sources,omitempty"` // 多源订阅中各来源的结果
+	Valid         bool                 `json:"valid"`
+	Errors        []string             `json:"errors,omitempty"`
+	Parsed        int                  `json:"parsed"`
+	Skipped       []parser.SkippedLink `json:"skipped"`
+	Filtered      int                  `json:"filtered"`
+	Nodes         int                  `json:"nodes"`
+	Groups        []GroupReport        `json:"groups"`
+	RuleProviders int                  `json:"rule_providers"`
+	Rules         int                  `json:"rules"`
+	RuleCheck     RuleCheck            `json:"rule_check"`
+	Size          int                  `json:"size"`
+}
+
+// GroupReport 概述一个生成的代理组
+type GroupReport struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Members int    `json:"members"`
+}
+
+// validateConfig 按 /config 的流程执行一次完整转换但不输出 YAML，只返回结构化报告；
+// 不论是否开启 output-check 都检查生成的配置能否被客户端加载
+func validateConfig(c *gin.Context) {
+	subURL, params, err := resolveSubscription(c.Request.URL.Query())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	opts, err := parseOptions(params)
+	if err != nil {
+		c.Error(badRequest(err))
+		return
+	}
+	setProviderURL(&opts, requestBaseURL(c), c.Request.URL.Query())
+	ctx := c.Request.Context()
+
+	report := ValidateReport{
+		Subscription: subscriptionLabel(subURL),
+		Skipped:      []parser.SkippedLink{},
+		Groups:       []GroupReport{},
+	}
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+		c.JSON(http.StatusOK, report)
+	}
+
+	results := loadSources(ctx, subURL, opts)
+	if len(results) > 1 {
+		report.Sources = sourceReports(results)
+	}
+	for _, r := range results {
+		report.Skipped = append(report.Skipped, r.Skipped...)
+	}
+	proxies, err := mergeSources(ctx, results)
+	report.Parsed = len(proxies)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if proxies, err = prepareProxies(ctx, subURL, proxies, opts); err != nil {
+		fail(err)
+		return
+	}
+	report.Nodes = len(proxies)
+	report.Filtered = report.Parsed - report.Nodes
+	out, err := renderConfig(ctx, proxies, opts)
+	if err != nil {
+		fail(err)
+		return
+	}
+	// 目标无法加载的节点同样计入 filtered
+	report.Nodes = out.nodes
+	report.Filtered = report.Parsed - report.Nodes
+	report.RuleCheck = out.rules
+	for _, g := range out.config.ProxyGroups {
+		report.Groups = append(report.Groups, GroupReport{Name: g.Name, Type: g.Type, Members: len(g.Proxies)})
+	}
+	report.RuleProviders = len(out.config.RulesProviders)
+	report.Rules = len(out.config.Rules)
+
+	if err := checkOutput(out.config); err != nil {
+		report.Errors = append(report.Errors, err.(*InvalidConfigError).Problems...)
+	}
+	data, err := out.generate(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	report.Size = len(data)
+	report.Valid = len(report.Errors) == 0
+	c.JSON(http.StatusOK, report)
+}
+
+// checkOutput 检查生成的配置能否被客户端加载，有问题时返回 *InvalidConfigError
+func checkOutput(cfg clash.Config) error {
+	errs := cfg.Validate()
+	if len(errs) == 0 {
+		return nil
+	}
+	problems := make([]string, len(errs))
+	for i, e := range errs {
+		problems[i] = e.Error()
+	}
+	return &InvalidConfigError{Problems: problems}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchTemplates 监听本地模板所在目录，文件变化时清空模板缓存，使修改无需重启即可生效。
+// 监听目录而非文件，以覆盖编辑器先写临时文件再重命名的保存方式
+func watchTemplates() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create template watcher: %v", err)
+	}
+
+	dirs := map[string]bool{templatesDir: true}
+	if src := templateSource(""); !isRemoteTemplate(src) {
+		dirs[filepath.Dir(src)] = true
+	}
+	for _, src := range Global.Templates {
+		if !isRemoteTemplate(src) {
+			dirs[filepath.Dir(src)] = true
+		}
+	}
+	for dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			warnf("Warning: failed to watch %s: %v", dir, err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+					continue
+				}
+				if ext := filepath.Ext(ev.Name); ext != ".yaml" && ext != ".yml" {
+					continue
+				}
+				infof("Template %s changed, reloading", ev.Name)
+				invalidateTemplates()
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				errorf("Template watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"pkg/main.go/src/pkg/generator"
+)
+
+//go:embed web/index.html
+var webFS embed.FS
+
+// registerWebRoutes 注册内置 Web UI
+func registerWebRoutes(r *gin.Engine) {
+	r.GET("/", serveIndex)
+	r.GET("/ui", serveIndex)
+	r.GET("/ui/options", serveUIOptions)
+}
+
+func serveIndex(c *gin.Context) {
+	page, err := webFS.ReadFile("web/index.html")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// serveUIOptions 返回 Web UI 下拉框的可选项：已注册的目标格式与可用的模板名称
+func serveUIOptions(c *gin.Context) {
+	// templateNames 的第一项为默认模板（空名称），由页面单独显示
+	c.JSON(http.StatusOK, gin.H{
+		"targets":        generator.Targets(),
+		"default_target": generator.DefaultTarget,
+		"templates":      templateNames()[1:],
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 转换过程中可触发 webhook 的事件
+const (
+	EventUpstreamError    = "upstream_error"     // 上游订阅无法获取
+	EventConversionFailed = "conversion_failed"  // 订阅内容无法解析
+	EventNodeCountChanged = "node_count_changed" // 节点数量与上次不同
+)
+
+// WebhookConfig 描述一个 webhook 接收端
+type WebhookConfig struct {
+	URL    string   `mapstructure:"url"`
+	Events []string `mapstructure:"events"` // 为空时接收全部事件
+	Secret string   `mapstructure:"secret"` // 非空时以 HMAC-SHA256 签名写入 X-Signature 头
+}
+
+// WebhookEvent 是 POST 给接收端的 JSON 内容
+type WebhookEvent struct {
+	Event        string `json:"event"`
+	Subscription string `json:"subscription"`
+	Message      string `json:"message,omitempty"`
+	Previous     int    `json:"previous,omitempty"`
+	Current      int    `json:"current,omitempty"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+var (
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+	// nodeCounts 记录每个订阅上次解析出的节点数
+	nodeCounts sync.Map
+)
+
+// notify 触发一个事件
+func notify(event, subURL, message string) {
+	dispatch(WebhookEvent{
+		Event:        event,
+		Subscription: subscriptionLabel(subURL),
+		Message:      message,
+		Timestamp:    time.Now().Unix(),
+	})
+}
+
+// trackNodeCount 记录节点数量，与上次不同时触发 node_count_changed
+func trackNodeCount(subURL string, count int) {
+	prev, loaded := nodeCounts.Swap(subURL, count)
+	if !loaded || prev.(int) == count {
+		return
+	}
+	dispatch(WebhookEvent{
+		Event:        EventNodeCountChanged,
+		Subscription: subscriptionLabel(subURL),
+		Message:      fmt.Sprintf("node count changed from %d to %d", prev.(int), count),
+		Previous:     prev.(int),
+		Current:      count,
+		Timestamp:    time.Now().Unix(),
+	})
+}
+
+// dispatch 异步发送事件到所有订阅了该事件的 webhook 与 telegram
+func dispatch(ev WebhookEvent) {
+	if Global == nil {
+		return
+	}
+	if tg := Global.Alerts.Telegram; tg.enabled() && tg.accepts(ev.Event) {
+		go tg.send(ev)
+	}
+	if len(Global.Webhooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		warnf("Warning: Failed to marshal webhook event: %v", err)
+		return
+	}
+	for _, hook := range Global.Webhooks {
+		if !hook.accepts(ev.Event) {
+			continue
+		}
+		go hook.send(payload)
+	}
+}
+
+func (h WebhookConfig) accepts(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (h WebhookConfig) send(payload []byte) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		warnf("Warning: Invalid webhook url %s: %v", h.URL, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(payload)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		warnf("Warning: Failed to deliver webhook to %s: %v", h.URL, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		warnf("Warning: Webhook %s returned status %d", h.URL, resp.StatusCode)
+	}
+}
+
+// subscriptionLabel 去掉订阅地址中的查询参数与用户信息，避免在通知中泄露 token
+func subscriptionLabel(subURL string) string {
+	srcs := splitSources(subURL)
+	labels := make([]string, 0, len(srcs))
+	for _, src := range srcs {
+		u, err := url.Parse(src)
+		if err != nil {
+			labels = append(labels, "unknown")
+			continue
+		}
+		labels = append(labels, u.Scheme+"://"+u.Host+u.Path)
+	}
+	return strings.Join(labels, sourceSeparator)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"pkg/main.go/src/pkg/clash"
+)
+
+// 支持的 ?zh= 取值
+const (
+	ZhSimplified  = "simplified"  // 繁体转简体
+	ZhTraditional = "traditional" // 简体转繁体
+)
+
+// zhPairs 为“繁简”字对，按字逐个替换；一简对多繁时以 zhPreferred 中的繁体为准
+const zhPairs = "" +
+	"與与 專专 業业 東东 絲丝 兩两 嚴严 喪丧 個个 豐丰 臨临 為为 麗丽 舉举 義义 烏乌 樂乐 喬乔 習习 鄉乡 " +
+	"書书 買买 亂乱 爭争 於于 虧亏 雲云 亞亚 產产 畝亩 親亲 億亿 僅仅 從从 侖仑 倉仓 儀仪 們们 價价 眾众 " +
+	"優优 會会 傘伞 偉伟 傳传 傷伤 倫伦 偽伪 體体 餘余 傭佣 僉佥 俠侠 侶侣 僥侥 偵侦 側侧 僑侨 儈侩 儕侪 " +
+	"儂侬 俁俣 儔俦 儼俨 倆俩 儷俪 儉俭 債债 傾倾 僂偻 僨偾 償偿 儻傥 儐傧 儲储 儺傩 兒儿 兌兑 黨党 蘭兰 " +
+	"關关 興兴 茲兹 養养 獸兽 內内 岡冈 冊册 寫写 軍军 農农 馮冯 衝冲 決决 況况 凍冻 淨净 涼凉 減减 湊凑 " +
+	"凜凛 幾几 鳳凤 鳧凫 憑凭 凱凯 擊击 鑿凿 芻刍 劃划 劉刘 則则 剛刚 創创 刪删 別别 剗刬 剄刭 劊刽 劌刿 " +
+	"剴剀 劑剂 剮剐 劍剑 剝剥 劇剧 勸劝 辦办 務务 勱劢 動动 勵励 勁劲 勞劳 勢势 勳勋 勝胜 區区 醫医 華华 " +
+	"協协 單单 賣卖 盧卢 鹵卤 衛卫 卻却 廠厂 廳厅 曆历 厲厉 壓压 厭厌 厙厍 廁厕 廂厢 厴厣 廈厦 廚厨 廄厩 " +
+	"廝厮 縣县 參参 雙双 發发 變变 敘叙 疊叠 葉叶 號号 嘆叹 嘰叽 籲吁 後后 嚇吓 呂吕 嗎吗 噸吨 聽听 啟启 " +
+	"吳吴 嘸呒 囈呓 嘔呕 嚦呖 唄呗 員员 咼呙 嗆呛 嗚呜 詠咏 嚨咙 嚀咛 噝咝 響响 啞哑 噠哒 嘵哓 嗶哔 噦哕 " +
+	"嘩哗 噲哙 嚌哜 噥哝 喲哟 嘜唛 嗊唝 嘮唠 啢唡 嗩唢 喚唤 嘖啧 嗇啬 囀啭 齧啮 嘽啴 嘯啸 噴喷 嘍喽 嚳喾 " +
+	"囁嗫 噯嗳 噓嘘 嚶嘤 囑嘱 嚕噜 團团 園园 圍围 圇囵 國国 圖图 圓圆 聖圣 壙圹 場场 壞坏 塊块 堅坚 壇坛 " +
+	"壢坜 壩坝 塢坞 墳坟 墜坠 壟垄 壚垆 壘垒 墾垦 堊垩 墊垫 埡垭 壋垱 塏垲 堖垴 塒埘 塤埙 堝埚 壺壶 壽寿 " +
+	"夠够 夢梦 夾夹 奐奂 奧奥 奩奁 奪夺 獎奖 奮奋 奼姹 婦妇 媽妈 嫵妩 嫗妪 姍姗 薑姜 婁娄 婭娅 嬈娆 嬌娇 " +
+	"孌娈 娛娱 媧娲 嫻娴 嬰婴 嬋婵 嬸婶 媼媪 嬡嫒 嬪嫔 嬙嫱 嬤嬷 孫孙 學学 孿孪 寧宁 寶宝 實实 寵宠 審审 " +
+	"憲宪 宮宫 寬宽 賓宾 寢寝 對对 尋寻 導导 將将 爾尔 塵尘 嘗尝 堯尧 尷尴 屍尸 盡尽 層层 屜屉 屆届 屬属 " +
+	"屢屡 屨屦 嶼屿 歲岁 豈岂 嶇岖 崗岗 峴岘 嶴岙 嵐岚 島岛 嶺岭 嶽岳 崠岽 巋岿 嶨峃 嶧峄 峽峡 嶢峣 嶠峤 " +
+	"崢峥 巒峦 嶗崂 崍崃 嶮崄 嶄崭 嶸嵘 嶔嵚 嶁嵝 巔巅 鞏巩 巰巯 幣币 帥帅 師师 幃帏 帳帐 簾帘 幟帜 帶带 " +
+	"幀帧 幫帮 幬帱 幘帻 幗帼 冪幂 莊庄 慶庆 廬庐 廡庑 庫库 應应 廟庙 龐庞 廢废 廎庼 廩廪 開开 異异 棄弃 " +
+	"張张 彌弥 彎弯 彈弹 強强 歸归 當当 錄录 彠彟 彥彦 徹彻 徑径 徠徕 憶忆 懺忏 憂忧 愾忾 懷怀 態态 慫怂 " +
+	"憮怃 慪怄 悵怅 愴怆 憐怜 總总 懟怼 懌怿 戀恋 懇恳 惡恶 慟恸 懨恹 愷恺 惻恻 惱恼 惲恽 悅悦 懸悬 慳悭 " +
+	"憫悯 驚惊 懼惧 慘惨 懲惩 憊惫 愜惬 慚惭 憚惮 慣惯 湣愍 慍愠 憤愤 憒愦 願愿 懾慑 憖慭 懣懑 懶懒 懍懔 " +
+	"戇戆 戔戋 戲戏 戧戗 戰战 戩戬 戶户 紮扎 撲扑 託托 執执 擴扩 捫扪 掃扫 揚扬 擾扰 撫抚 拋抛 摶抟 摳抠 " +
+	"掄抡 搶抢 護护 報报 擔担 擬拟 攏拢 揀拣 擁拥 攔拦 擰拧 撥拨 擇择 掛挂 摯挚 攣挛 掗挜 撾挝 撻挞 挾挟 " +
+	"撓挠 擋挡 撟挢 掙挣 擠挤 揮挥 撏挦 撈捞 損损 撿捡 換换 搗捣 據据 擄掳 摑掴 擲掷 撣掸 摻掺 摜掼 攬揽 " +
+	"撳揿 攙搀 擱搁 摟搂 攪搅 攜携 攝摄 攄摅 擺摆 搖摇 擯摈 攤摊 攖撄 撐撑 攆撵 擷撷 擼撸 攛撺 擻擞 攢攒 " +
+	"敵敌 斂敛 數数 齋斋 斕斓 鬥斗 斬斩 斷断 無无 舊旧 時时 曠旷 暘旸 曇昙 晝昼 曨昽 顯显 晉晋 曬晒 曉晓 " +
+	"曄晔 暈晕 暉晖 暫暂 曖暧 術术 樸朴 機机 殺杀 雜杂 權权 條条 來来 楊杨 榪杩 傑杰 極极 構构 樅枞 樞枢 " +
+	"棗枣 櫪枥 梘枧 棖枨 槍枪 楓枫 梟枭 櫃柜 檸柠 檉柽 梔栀 柵栅 標标 棧栈 櫛栉 櫳栊 棟栋 櫨栌 櫟栎 欄栏 " +
+	"樹树 棲栖 樣样 欒栾 椏桠 橈桡 楨桢 檔档 榿桤 橋桥 樺桦 檜桧 槳桨 樁桩 檢检 欞棂 槨椁 櫝椟 槧椠 槶椢 " +
+	"樓楼 欖榄 櫬榇 櫚榈 櫸榉 檟槚 檻槛 檳槟 櫧槠 橫横 檣樯 櫻樱 櫫橥 櫥橱 櫓橹 櫞橼 檁檩 歡欢 歟欤 歐欧 " +
+	"殲歼 歿殁 殤殇 殘残 殞殒 殮殓 殫殚 殯殡 毆殴 毀毁 轂毂 畢毕 斃毙 氈毡 毿毵 氌氇 氣气 氫氢 氬氩 氳氲 " +
+	"匯汇 漢汉 湯汤 溝沟 沒没 灃沣 漚沤 瀝沥 淪沦 滄沧 溈沩 滬沪 濔沵 濘泞 淚泪 澩泶 瀧泷 瀘泸 濼泺 瀉泻 " +
+	"潑泼 澤泽 涇泾 潔洁 灑洒 窪洼 浹浃 淺浅 漿浆 澆浇 湞浈 溮浉 濁浊 測测 澮浍 濟济 瀏浏 滻浐 渾浑 滸浒 " +
+	"濃浓 潯浔 濜浕 塗涂 濤涛 澇涝 淶涞 漣涟 潿涠 渦涡 溳涢 渙涣 滌涤 潤润 澗涧 漲涨 澀涩 澱淀 淵渊 淥渌 " +
+	"漬渍 瀆渎 漸渐 澠渑 漁渔 瀋沈 滲渗 溫温 遊游 灣湾 濕湿 潰溃 濺溅 漵溆 漊溇 潷滗 滯滞 灩滟 灄滠 滿满 " +
+	"瀅滢 濾滤 濫滥 灤滦 濱滨 灘滩 澦滪 瀠潆 瀟潇 瀲潋 濰潍 潛潜 瀦潴 瀾澜 瀨濑 瀕濒 灝灏 滅灭 燈灯 靈灵 " +
+	"災灾 燦灿 煬炀 爐炉 燉炖 煒炜 熗炝 點点 煉炼 熾炽 爍烁 爛烂 烴烃 燭烛 煙烟 煩烦 燒烧 燁烨 燴烩 燙烫 " +
+	"燼烬 熱热 煥焕 燜焖 燾焘 愛爱 爺爷 牘牍 犛牦 牽牵 犧牺 犢犊 狀状 獷犷 獁犸 猶犹 狽狈 獮狝 獰狞 獨独 " +
+	"狹狭 獅狮 獪狯 猙狰 獄狱 猻狲 獫猃 獵猎 獼猕 玀猡 豬猪 貓猫 蝟猬 獻献 獺獭 璣玑 瑪玛 瑋玮 環环 現现 " +
+	"瑲玱 璽玺 琺珐 瓏珑 璫珰 琿珲 璉琏 瑣琐 瓊琼 瑤瑶 璦瑷 瓔璎 瓚瓒 甌瓯 電电 畫画 暢畅 疇畴 癤疖 療疗 " +
+	"瘧疟 癘疠 瘍疡 癧疬 瘡疮 瘋疯 皰疱 痾疴 癰痈 痙痉 癢痒 瘂痖 癆痨 瘓痪 癇痫 癡痴 癉瘅 瘮瘆 瘞瘗 瘺瘘 " +
+	"癟瘪 癱瘫 癮瘾 癭瘿 癩癞 癬癣 癲癫 皚皑 皺皱 皸皲 盞盏 鹽盐 監监 蓋盖 盜盗 盤盘 瞘眍 眥眦 矚瞩 睜睁 " +
+	"睞睐 瞼睑 瞞瞒 礬矾 礦矿 碭砀 碼码 磚砖 硨砗 硯砚 碸砜 礪砺 礱砻 礫砾 礎础 硜硁 碩硕 硤硖 磽硗 磑硙 " +
+	"礄硚 確确 鹼硷 礙碍 磧碛 磣碜 鏇碹 禮礼 禕祎 禰祢 禎祯 禱祷 禍祸 祿禄 稟禀 禪禅 離离 禿秃 稈秆 種种 " +
+	"積积 稱称 穢秽 穠秾 穩稳 穀谷 窮穷 竊窃 竅窍 窯窑 竄窜 窩窝 窺窥 竇窦 豎竖 競竞 筆笔 筍笋 筧笕 箋笺 " +
+	"籠笼 籩笾 築筑 篳筚 篩筛 簹筜 箏筝 籌筹 簽签 簡简 籙箓 簀箦 篋箧 籜箨 籮箩 簞箪 簫箫 簣篑 簍篓 籃篮 " +
+	"籬篱 籪簖 籟籁 糴籴 類类 秈籼 糶粜 糲粝 粵粤 糞粪 糧粮 糝糁 餱糇 緊紧 縶絷 糾纠 紀纪 紂纣 約约 紅红 " +
+	"紆纡 紇纥 紈纨 紉纫 緯纬 紜纭 紘纮 純纯 紕纰 紗纱 綱纲 納纳 縱纵 綸纶 紛纷 紙纸 紋纹 紡纺 紐纽 紓纾 " +
+	"線线 紺绀 紲绁 紱绂 練练 組组 紳绅 細细 織织 終终 縐绉 紼绋 絀绌 紹绍 繹绎 經经 紿绐 綁绑 絨绒 結结 " +
+	"絝绔 繞绕 絎绗 繪绘 給给 絢绚 絳绛 絡络 絕绝 絞绞 統统 綆绠 綃绡 絹绢 繡绣 綌绤 綏绥 繼继 綈绨 績绩 " +
+	"緒绪 綾绫 緓绬 續续 綺绮 緋绯 綽绰 緄绲 繩绳 維维 綿绵 綬绶 繃绷 綢绸 綹绺 綣绻 綜综 綻绽 綰绾 綠绿 " +
+	"綴缀 緇缁 緙缂 緗缃 緘缄 緬缅 纜缆 緹缇 緲缈 緝缉 縕缊 繢缋 緦缌 綞缍 緞缎 緶缏 緱缑 縋缒 緩缓 締缔 " +
+	"縷缕 編编 緡缗 緣缘 縉缙 縛缚 縟缛 縝缜 縫缝 縗缞 縞缟 纏缠 縭缡 縊缢 縑缣 繽缤 縹缥 縵缦 縲缧 纓缨 " +
+	"縮缩 繆缪 繅缫 纈缬 繚缭 繕缮 繒缯 韁缰 繾缱 繰缲 繯缳 纘缵 罌罂 網网 羅罗 罰罚 罷罢 羆罴 羈羁 羥羟 " +
+	"翹翘 耮耢 耬耧 聳耸 恥耻 聶聂 聾聋 職职 聹聍 聯联 聵聩 聰聪 肅肃 腸肠 膚肤 骯肮 餚肴 腎肾 腫肿 脹胀 " +
+	"脅胁 膽胆 朧胧 腖胨 臚胪 脛胫 膠胶 脈脉 膾脍 髒脏 臍脐 腦脑 膿脓 臠脔 腳脚 脫脱 腡脶 臉脸 臘腊 醃腌 " +
+	"膕腘 齶腭 膩腻 靦腼 膃腽 騰腾 臏膑 臟脏 艦舰 艙舱 艤舣 艫舻 艱艰 豔艳 藝艺 節节 羋芈 薌芗 蕪芜 蘆芦 " +
+	"蓯苁 葦苇 藶苈 莧苋 萇苌 蒼苍 苧苎 蘇苏 蘋苹 莖茎 蘢茏 蔦茑 塋茔 煢茕 繭茧 荊荆 薦荐 薘荙 莢荚 蕘荛 " +
+	"蓽荜 蕎荞 薈荟 薺荠 蕩荡 榮荣 葷荤 滎荥 犖荦 熒荧 蕁荨 藎荩 蓀荪 蔭荫 蕒荬 葒荭 葤荮 藥药 蒞莅 蓧莜 " +
+	"萊莱 蓮莲 蒔莳 萵莴 薟莶 獲获 蕕莸 瑩莹 鶯莺 蓴莼 蘀萚 蘿萝 螢萤 營营 縈萦 蕭萧 薩萨 蔥葱 蕆蒇 蕢蒉 " +
+	"蔣蒋 蔞蒌 藍蓝 薊蓟 蘺蓠 蕷蓣 鎣蓥 驀蓦 薔蔷 蘞蔹 藺蔺 藹蔼 蘄蕲 蘊蕴 藪薮 槁藁 蘚藓 虜虏 慮虑 虛虚 " +
+	"蟲虫 虯虬 蟣虮 雖虽 蝦虾 蠆虿 蝕蚀 蟻蚁 螞蚂 蠶蚕 蠔蚝 蜆蚬 蠱蛊 蠣蛎 蟶蛏 蠻蛮 蟄蛰 蛺蛱 蟯蛲 螄蛳 " +
+	"蠐蛴 蛻蜕 蝸蜗 蠟蜡 蠅蝇 蟈蝈 蟬蝉 蠍蝎 螻蝼 蠑蝾 螿螀 蟎螨 蠨蟏 釁衅 銜衔 補补 襯衬 袞衮 襖袄 嫋袅 " +
+	"褘袆 襪袜 襲袭 襏袯 裝装 襠裆 褌裈 褳裢 襝裣 褲裤 襇裥 褸褛 襤褴 見见 觀观 覎觃 規规 覓觅 視视 覘觇 " +
+	"覽览 覺觉 覬觊 覡觋 覿觌 覥觍 覦觎 覯觏 覲觐 覷觑 觴觞 觸触 觶觯 讋詟 譽誉 謄誊 訁讠 計计 訂订 訃讣 " +
+	"認认 譏讥 訐讦 訌讧 討讨 讓让 訕讪 訖讫 訓训 議议 訊讯 記记 訒讱 講讲 諱讳 謳讴 詎讵 訝讶 訥讷 許许 " +
+	"訛讹 論论 訩讻 訟讼 諷讽 設设 訪访 訣诀 證证 詁诂 訶诃 評评 詛诅 識识 詗诇 詐诈 訴诉 診诊 詆诋 謅诌 " +
+	"詞词 詘诎 詔诏 詖诐 譯译 詒诒 誆诓 誄诔 試试 詿诖 詩诗 詰诘 詼诙 誠诚 誅诛 詵诜 話话 誕诞 詬诟 詮诠 " +
+	"詭诡 詢询 詣诣 諍诤 該该 詳详 詫诧 諢诨 詡诩 譸诪 誡诫 誣诬 語语 誚诮 誤误 誥诰 誘诱 誨诲 誑诳 說说 " +
+	"誦诵 誒诶 請请 諸诸 諏诹 諾诺 讀读 諑诼 誹诽 課课 諉诿 諛谀 誰谁 諗谂 調调 諂谄 諒谅 諄谆 誶谇 談谈 " +
+	"誼谊 謀谋 諶谌 諜谍 謊谎 諫谏 諧谐 謔谑 謁谒 謂谓 諤谔 諭谕 諼谖 讒谗 諮谘 諳谙 諺谚 諦谛 謎谜 諞谝 " +
+	"諝谞 謨谟 讜谠 謖谡 謝谢 謗谤 謚谥 謙谦 謐谧 謹谨 謾谩 謫谪 譾谫 謬谬 譚谭 譖谮 譙谯 讕谰 譜谱 譎谲 " +
+	"讞谳 譴谴 譫谵 讖谶 豶豮 貝贝 貞贞 負负 貟贠 貢贡 財财 責责 賢贤 敗败 賬账 貨货 質质 販贩 貪贪 貧贫 " +
+	"貶贬 購购 貯贮 貫贯 貳贰 賤贱 賁贲 貰贳 貼贴 貴贵 貺贶 貸贷 貿贸 費费 賀贺 貽贻 賊贼 贄贽 賈贾 賄贿 " +
+	"貲赀 賃赁 賂赂 贓赃 資资 賅赅 贐赆 賕赇 賑赈 賚赉 賒赊 賦赋 賭赌 齎赍 贖赎 賞赏 賜赐 贔赑 賙赒 賡赓 " +
+	"賠赔 賧赕 賴赖 賵赗 贅赘 賻赙 賺赚 賽赛 賾赜 贗赝 贊赞 贇赟 贈赠 贍赡 贏赢 贛赣 赬赪 趙赵 趕赶 趨趋 " +
+	"趲趱 躉趸 躍跃 蹌跄 跡迹 踐践 躂跶 蹺跷 蹕跸 躚跹 躋跻 踴踊 躊踌 蹤踪 躓踬 躑踯 躡蹑 蹣蹒 躕蹰 躥蹿 " +
+	"躪躏 躦躜 軀躯 車车 軋轧 軌轨 軒轩 軑轪 軔轫 轉转 軛轭 輪轮 軟软 轟轰 軲轱 軻轲 轤轳 軸轴 軹轵 軼轶 " +
+	"軤轷 軫轸 轢轹 軺轺 輕轻 軾轼 載载 輊轾 轎轿 輈辀 輇辁 輅辂 較较 輒辄 輔辅 輛辆 輦辇 輩辈 輝辉 輥辊 " +
+	"輞辋 輬辌 輟辍 輜辎 輳辏 輻辐 輯辑 轀辒 輸输 轡辔 轅辕 轄辖 輾辗 轆辘 轍辙 轔辚 辭辞 辯辩 邊边 遼辽 " +
+	"達达 遷迁 過过 邁迈 運运 還还 這这 進进 遠远 違违 連连 遲迟 邇迩 逕迳 適适 選选 遜逊 遞递 邐逦 邏逻 " +
+	"遺遗 遙遥 鄧邓 鄺邝 鄔邬 郵邮 鄒邹 鄴邺 鄰邻 鬱郁 郤郄 郟郏 鄶郐 鄭郑 鄆郓 酈郦 鄖郧 鄲郸 醞酝 醱酦 " +
+	"醬酱 釅酽 釃酾 釀酿 釋释 裏里 鑒鉴 鑾銮 鏨錾 釓钆 釔钇 針针 釘钉 釗钊 釙钋 釕钌 釷钍 釺钎 釧钏 釤钐 " +
+	"釩钒 釣钓 鍆钔 釹钕 鍚钖 釵钗 鈣钙 鈰铈 鋇钡 鈍钝 鈔钞 鍾钟 鈉钠 鋼钢 鈑钣 鈐钤 鑰钥 欽钦 鈞钧 鎢钨 " +
+	"鉤钩 鈧钪 鈁钫 鈥钬 鈄钭 鈕钮 鈀钯 鈺钰 錢钱 鉦钲 鉗钳 鈷钴 缽钵 鈳钶 鉕钷 鈽钸 鈸钹 鉞钺 鑽钻 鉬钼 " +
+	"鉭钽 鉀钾 鈿钿 鈾铀 鐵铁 鉑铂 鈴铃 鑠铄 鉛铅 鉚铆 鉉铉 鉈铊 鉍铋 鈮铌 鈹铍 鐸铎 鉶铏 銬铐 銠铑 鉺铒 " +
+	"銪铕 鋁铝 銱铞 銦铟 鎧铠 鍘铡 銖铢 銑铣 鋌铤 銩铥 銛铦 鏵铧 銓铨 鉿铪 鋮铖 鏷镤 銘铭 鐃铙 鋣铘 鐺铛 " +
+	"銅铜 銻锑 鋯锆 鋨锇 銹锈 鋰锂 銳锐 鋤锄 鍋锅 鏽锈 錐锥 錘锤 錯错 錨锚 錫锡 錦锦 鍵键 鋸锯 錳锰 錛锛 " +
+	"錮锢 鍛锻 鍍镀 鎂镁 鏈链 鎖锁 鎬镐 鏡镜 鐘钟 鐳镭 鏢镖 鑄铸 鑑鉴 長长 門门 閂闩 閃闪 閆闫 閉闭 問问 " +
+	"闖闯 閏闰 閑闲 間间 閔闵 閌闶 悶闷 閘闸 鬧闹 閨闺 聞闻 閩闽 閭闾 閥阀 閣阁 閡阂 閫阃 閬阆 閱阅 " +
+	"閶阊 閹阉 閻阎 閼阏 闊阔 闋阕 闌阑 闐阗 闈闱 闍阇 闕阙 闔阖 闡阐 闢辟 闥闼 隊队 陽阳 陰阴 陣阵 階阶 " +
+	"際际 陸陆 隴陇 陳陈 陘陉 陝陕 隉陧 隕陨 險险 隨随 隱隐 隸隶 難难 雛雏 讎雠 靂雳 霧雾 霽霁 靄霭 靚靓 " +
+	"靜静 靨靥 韃鞑 韉鞯 韋韦 韌韧 韓韩 韙韪 韜韬 韞韫 韻韵 頁页 頂顶 頃顷 項项 順顺 須须 頊顼 頑顽 顧顾 " +
+	"頓顿 頎颀 頒颁 頌颂 頏颃 預预 顱颅 領领 頗颇 頸颈 頡颉 頰颊 頜颌 潁颍 頦颏 頭头 頹颓 頻频 顆颗 題题 " +
+	"額额 顏颜 顎颚 顛颠 顢颟 顥颢 顫颤 顬颥 顰颦 顴颧 風风 颯飒 颱台 颳刮 颶飓 颼飕 飄飘 飆飙 飛飞 饑饥 " +
+	"飣饤 餳饧 飩饨 飯饭 飲饮 餞饯 飾饰 飽饱 飼饲 飴饴 餌饵 饒饶 餃饺 餅饼 館馆 餛馄 餡馅 餵喂 饅馒 饈馐 " +
+	"饉馑 饞馋 饋馈 馬马 馭驭 馱驮 馴驯 馳驰 驅驱 駁驳 驢驴 駔驵 駛驶 駟驷 駙驸 駒驹 騶驺 駐驻 駝驼 駑驽 " +
+	"駕驾 驛驿 駘骀 驍骁 駭骇 駢骈 驕骄 驗验 騫骞 駿骏 騎骑 騍骒 騾骡 騷骚 驂骖 騮骝 驃骠 驟骤 驥骥 驤骧 " +
+	"髏髅 鬢鬓 魘魇 魎魉 魚鱼 魯鲁 鮑鲍 鮮鲜 鯉鲤 鯨鲸 鰻鳗 鱷鳄 鳥鸟 鳩鸠 雞鸡 鴉鸦 鴨鸭 鴻鸿 鵝鹅 鵬鹏 " +
+	"鶴鹤 鷹鹰 鸚鹦 鹹咸 麥麦 黃黄 黌黉 黲黪 黷黩 黽黾 鼉鼍 鼴鼹 齊齐 齒齿 齡龄 龍龙 龔龚 龜龟 臺台 綫线 " +
+	"徵征 週周 錶表 髮发 鬆松 麵面 佔占 隻只 製制 範范 係系 繫系 乾干 幹干 醜丑 迴回 併并 噹当 " +
+	"裡里 讚赞 峯峰 檯台 囉啰 蔔卜 "
+
+// zhPreferred 为简转繁时一对多字的首选繁体
+const zhPreferred = "臺台 灣湾 裡里 發发 隻只 麵面 鐘钟 係系 製制 範范 後后 曆历 穀谷 餘余 幹干 週周 徵征 鬆松 錶表"
+
+var (
+	t2s = make(map[rune]rune)
+	s2t = make(map[rune]rune)
+)
+
+func init() {
+	for _, pair := range strings.Fields(zhPreferred) {
+		r := []rune(pair)
+		s2t[r[1]] = r[0]
+	}
+	for _, pair := range strings.Fields(zhPairs) {
+		r := []rune(pair)
+		t2s[r[0]] = r[1]
+		if _, ok := s2t[r[1]]; !ok {
+			s2t[r[1]] = r[0]
+		}
+	}
+}
+
+func validateZh(mode string) error {
+	switch mode {
+	case "", ZhSimplified, ZhTraditional:
+		return nil
+	}
+	return fmt.Errorf("invalid zh: %q (want simplified or traditional)", mode)
+}
+
+// convertZh 按字转换繁简体，表中没有的字保持不变
+func convertZh(s, mode string) string {
+	table := t2s
+	if mode == ZhTraditional {
+		table = s2t
+	}
+	return strings.Map(func(r rune) rune {
+		if v, ok := table[r]; ok {
+			return v
+		}
+		return r
+	}, s)
+}
+
+// normalizeZh 统一所有节点名称的繁简体，使以一种字形编写的过滤与重命名规则也能匹配另一种
+func normalizeZh(proxies []clash.Proxy, mode string) {
+	if mode == "" {
+		return
+	}
+	for i := range proxies {
+		proxies[i].Name = convertZh(proxies[i].Name, mode)
+	}
+}